@@ -0,0 +1,101 @@
+package main
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/jack-watts/empty-tt/pkg/tt"
+)
+
+// runBatch runs the generation jobs of a manifest on a worker pool and writes a JSON summary
+// report. It returns errFindings when any job fails.
+func runBatch(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	workers := fs.Int("workers", 0, "- set the number of concurrent jobs, Default is the manifest's value or the number of CPUs")
+	output := fs.String("o", "", "- set the output path, Default is the manifest's output")
+	wrapper := fs.String("w", "", "- set the track file wrapper, 'asdcp' or 'native', Default is the manifest's wrapper")
+	asdcpPath := fs.String("asdcp", "", "- path to the asdcp-wrap binary, Default is asdcp-wrap at $PATH")
+	reportPath := fs.String("report", "", "- set the summary report path, Default is report.json in the output path")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: empty-tt batch [flags] manifest.yaml|manifest.json")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	m, err := tt.LoadManifest(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *workers > 0 {
+		m.Workers = *workers
+	}
+	if *output != "" {
+		m.Output = *output
+	}
+	if *wrapper != "" {
+		m.Wrapper = *wrapper
+	}
+	if *asdcpPath != "" {
+		m.ASDCP = *asdcpPath
+	}
+	results, err := tt.RunBatch(m)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+			fmt.Printf("%s: error: %s\n", r.Name, r.Error)
+			continue
+		}
+		fmt.Printf("%s: %s\n", r.Name, r.XML)
+	}
+	fmt.Printf("%d jobs, %d failed\n", len(results), failed)
+
+	report, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	if *reportPath == "" {
+		*reportPath = filepath.Join(m.Output, "report.json")
+	}
+	if err := ioutil.WriteFile(*reportPath, append(report, '\n'), 0644); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return errFindings
+	}
+	return nil
+}
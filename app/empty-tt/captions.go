@@ -0,0 +1,91 @@
+package main
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+
+	"github.com/jack-watts/empty-tt/pkg/tt"
+)
+
+// runCaptions checks a closed caption document against the caption rules.
+// It returns errFindings when errors are found.
+func runCaptions(args []string) error {
+	fs := flag.NewFlagSet("captions", flag.ContinueOnError)
+	rules := tt.DefaultCaptionRules
+	fs.IntVar(&rules.MaxLines, "lines", rules.MaxLines, "- set the maximum number of lines per caption")
+	fs.IntVar(&rules.MaxLineLength, "length", rules.MaxLineLength, "- set the maximum number of characters per line")
+	fs.Float64Var(&rules.MaxVposition, "vposition", rules.MaxVposition, "- set the maximum Vposition in percent")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: empty-tt captions [flags] document.xml")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	s, err := tt.ParseXML(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	findings := tt.ValidateCaptions(s, rules)
+	for _, f := range findings {
+		fmt.Println(f)
+	}
+	if tt.HasErrors(findings) {
+		return errFindings
+	}
+	return nil
+}
+
+// runReelAsset prints the CPL reel asset of a timed text track file.
+func runReelAsset(args []string) error {
+	fs := flag.NewFlagSet("reel-asset", flag.ContinueOnError)
+	keyID := fs.String("j", "", "- set the KeyID of an encrypted track file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: empty-tt reel-asset [flags] trackfile.mxf")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	a, err := tt.NewReelAsset(fs.Arg(0), *keyID)
+	if err != nil {
+		return err
+	}
+	enc, err := xml.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\n", enc)
+	return nil
+}
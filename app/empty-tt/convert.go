@@ -0,0 +1,88 @@
+package main
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jack-watts/empty-tt/pkg/tt"
+)
+
+// runConvert converts an ST 428-7, SRT, WebVTT, IMSC or Interop document to the DCST 2010 or
+// 2014 namespace and reports the features dropped along the way.
+func runConvert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	version := fs.String("ns", "2014", "- set the target namespace, '2010' or '2014'")
+	output := fs.String("o", "", "- set the output path, Default is StdOut")
+	var opts tt.TextImportOptions
+	fs.StringVar(&opts.Framerate, "p", "24", "- set the frame rate of imported SRT, WebVTT, IMSC and Interop documents.")
+	fs.IntVar(&opts.Reel, "r", 1, "- set the ReelNumber of imported documents")
+	fs.IntVar(&opts.Display, "m", 0, "- set the DisplayType of imported documents.'0'=MainSubtitle,'1'=ClosedCaption.")
+	fs.StringVar(&opts.Language, "l", "", "- set the RFC 5646 Language subtag of imported documents, Default is the source language or 'en'")
+	fs.StringVar(&opts.Title, "t", "", "- set the ContentTitleText value of imported documents")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: empty-tt convert [flags] document.xml|.srt|.vtt|.ttml")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	var namespace string
+	switch *version {
+	case "2010":
+		namespace = tt.DCST2010
+	case "2014":
+		namespace = tt.DCST2014
+	default:
+		return fmt.Errorf("unsupported namespace version: %s", *version)
+	}
+	s, findings, err := tt.ConvertFile(fs.Arg(0), namespace, opts)
+	if err != nil {
+		return err
+	}
+	for _, f := range findings {
+		fmt.Fprintln(os.Stderr, f)
+	}
+	if *output == "" {
+		enc, err := xml.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s%s\n", xml.Header, enc)
+		return nil
+	}
+	filename, err := tt.WriteXML(s, *output)
+	if err != nil {
+		return err
+	}
+	fmt.Println(filename)
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/jack-watts/empty-tt/pkg/tt"
+)
+
+// runImages checks the PNG resources referenced by an image profile document.
+// It returns errFindings when errors are found that -fix did not resolve.
+func runImages(args []string) error {
+	fs := flag.NewFlagSet("images", flag.ContinueOnError)
+	fix := fs.Bool("fix", false, "- re-encode non-conforming images in place")
+	resources := fs.String("resources", "", "- path to image resources, Default is the document's directory")
+	maxFileSize := fs.Int64("s", 0, "- set the maximum file size in bytes, Default is no limit")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: empty-tt images [flags] document.xml")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	s, err := tt.ParseXML(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	opts := tt.ImageCheckOptions{
+		Resources:   *resources,
+		MaxFileSize: *maxFileSize,
+		Fix:         *fix,
+	}
+	if opts.Resources == "" {
+		opts.Resources = filepath.Dir(fs.Arg(0))
+	}
+	findings := tt.CheckImages(s, opts)
+	for _, f := range findings {
+		fmt.Println(f)
+	}
+	if tt.HasErrors(findings) {
+		return errFindings
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/jack-watts/empty-tt/pkg/tt"
+)
+
+// runImportPGS builds an image profile document from a Blu-ray PGS (.sup) stream.
+func runImportPGS(args []string) error {
+	return importBitmap("import-pgs", "stream.sup", tt.ImportPGS, nil, args)
+}
+
+// runImportVobSub builds an image profile document from a VobSub (.idx/.sub) stream.
+func runImportVobSub(args []string) error {
+	stream := func(fs *flag.FlagSet, opts *tt.BitmapImportOptions) {
+		fs.IntVar(&opts.Stream, "s", 0, "- select the language stream of the .idx file")
+	}
+	return importBitmap("import-vobsub", "subtitles.idx", tt.ImportVobSub, stream, args)
+}
+
+// importBitmap parses the flags shared by the bitmap subtitle importers and writes the resulting document.
+func importBitmap(name, input string, importer func(string, tt.BitmapImportOptions) (*tt.SubtitleReel, error),
+	extra func(*flag.FlagSet, *tt.BitmapImportOptions), args []string) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	opts := tt.BitmapImportOptions{}
+	if extra != nil {
+		extra(fs, &opts)
+	}
+	container := fs.String("c", "2k", "- set the target DCI container size, '2k' or '4k'")
+	fs.StringVar(&opts.Output, "o", "", "- set the output path")
+	fs.StringVar(&opts.Framerate, "p", "24", "- set the frame rate of the document.")
+	fs.IntVar(&opts.Reel, "r", 1, "- set the ReelNumber")
+	fs.IntVar(&opts.Display, "m", 0, "- set the DisplayType.'0'=MainSubtitle,'1'=ClosedCaption.")
+	fs.StringVar(&opts.Language, "l", "en", "- set the RFC 5646 Language subtag")
+	fs.StringVar(&opts.Title, "t", "No Title", "- set the ContentTitleText value.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: empty-tt %s [flags] -o <dir> %s\n", name, input)
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || opts.Output == "" {
+		fs.Usage()
+		return errUsage
+	}
+	switch *container {
+	case "2k", "2K":
+		opts.Container = tt.Container2K
+	case "4k", "4K":
+		opts.Container = tt.Container4K
+	default:
+		return fmt.Errorf("unsupported container size: %s", *container)
+	}
+	s, err := importer(fs.Arg(0), opts)
+	if err != nil {
+		return err
+	}
+	filename, err := tt.WriteXML(s, opts.Output)
+	if err != nil {
+		return err
+	}
+	fmt.Println(filename)
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/jack-watts/empty-tt/pkg/tt"
+)
+
+// runImportImages builds an image profile document from a directory of PNGs and a timing list.
+func runImportImages(args []string) error {
+	fs := flag.NewFlagSet("import-images", flag.ContinueOnError)
+	opts := tt.ImageImportOptions{}
+	fs.StringVar(&opts.Images, "i", ".", "- path to the directory of PNG images")
+	fs.StringVar(&opts.Output, "o", "", "- set the output path")
+	fs.StringVar(&opts.Framerate, "p", "24", "- set the frame rate of the timing list and document.")
+	fs.StringVar(&opts.StartTime, "s", "", "- set the timecode of the timing list at the start of the reel, Default is the hour of the first EDL event")
+	fs.IntVar(&opts.Reel, "r", 1, "- set the ReelNumber")
+	fs.IntVar(&opts.Display, "m", 0, "- set the DisplayType.'0'=MainSubtitle,'1'=ClosedCaption.")
+	fs.StringVar(&opts.Language, "l", "en", "- set the RFC 5646 Language subtag")
+	fs.StringVar(&opts.Title, "t", "No Title", "- set the ContentTitleText value.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: empty-tt import-images [flags] -o <dir> list.csv|list.edl")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || opts.Output == "" {
+		fs.Usage()
+		return errUsage
+	}
+	s, findings, err := tt.ImportImages(fs.Arg(0), opts)
+	if err != nil {
+		return err
+	}
+	for _, f := range findings {
+		fmt.Println(f)
+	}
+	filename, err := tt.WriteXML(s, opts.Output)
+	if err != nil {
+		return err
+	}
+	fmt.Println(filename)
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/jack-watts/empty-tt/pkg/kdm"
+)
+
+// runKDM builds a KDM for the content key of an encrypted track file.
+func runKDM(args []string) error {
+	fs := flag.NewFlagSet("kdm", flag.ContinueOnError)
+	signer := fs.String("signer", "", "- path to the PEM signer certificate chain, ordered from signer to root")
+	signerKey := fs.String("signer-key", "", "- path to the PEM signer private key")
+	recipient := fs.String("recipient", "", "- path to the PEM recipient certificate")
+	keyID := fs.String("keyid", "", "- set the KeyID of the content key")
+	keyHex := fs.String("key", "", "- set the hex encoded content key (KeyString)")
+	keyType := fs.String("type", kdm.KeyTypeSubtitle, "- set the key type")
+	cpl := fs.String("cpl", "", "- set the Id of the composition playlist")
+	title := fs.String("t", "", "- set the ContentTitleText")
+	start := fs.String("start", "", "- set ContentKeysNotValidBefore as RFC 3339 time or date, Default is now")
+	end := fs.String("end", "", "- set ContentKeysNotValidAfter as RFC 3339 time or date, Default is one week after start")
+	var tdl stringList
+	fs.Var(&tdl, "tdl", "- add a trusted device certificate thumbprint, may be repeated. Default is the recipient thumbprint")
+	assumeTrust := fs.Bool("assume-trust", false, "- use the assume trust thumbprint as the TDL")
+	output := fs.String("o", "", "- path to the output KDM file, Default is StdOut")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: empty-tt kdm [flags] -signer chain.pem -signer-key key.pem -recipient cert.pem -keyid <uuid> -key <hex> -cpl <uuid>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *signer == "" || *signerKey == "" || *recipient == "" || *keyID == "" || *keyHex == "" || *cpl == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	opts := kdm.Options{
+		CPLID:          *cpl,
+		ContentTitle:   *title,
+		AnnotationText: *title,
+		TrustedDevices: tdl,
+	}
+	if *assumeTrust {
+		opts.TrustedDevices = []string{kdm.AssumeTrust}
+	}
+	var err error
+	if opts.Signer, err = kdm.LoadCertificates(*signer); err != nil {
+		return err
+	}
+	if opts.SignerKey, err = kdm.LoadPrivateKey(*signerKey); err != nil {
+		return err
+	}
+	certs, err := kdm.LoadCertificates(*recipient)
+	if err != nil {
+		return err
+	}
+	opts.Recipient = certs[0]
+	key, err := hex.DecodeString(*keyHex)
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+	opts.Keys = []kdm.Key{{ID: *keyID, Type: *keyType, Key: key}}
+
+	opts.NotValidBefore = time.Now()
+	if *start != "" {
+		if opts.NotValidBefore, err = parseTime(*start); err != nil {
+			return err
+		}
+	}
+	opts.NotValidAfter = opts.NotValidBefore.AddDate(0, 0, 7)
+	if *end != "" {
+		if opts.NotValidAfter, err = parseTime(*end); err != nil {
+			return err
+		}
+	}
+
+	enc, err := kdm.Generate(opts)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		fmt.Printf("%s", enc)
+		return nil
+	}
+	return ioutil.WriteFile(*output, enc, 0644)
+}
+
+// parseTime parses an RFC 3339 time or a date.
+func parseTime(s string) (time.Time, error) {
+	if !strings.Contains(s, "T") {
+		return time.Parse("2006-01-02", s)
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -24,25 +24,95 @@ You should have received a copy of the GNU General Public License
 along with this program.If not, see <http://www.gnu.org/licenses/>.*/
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/jack-watts/empty-tt/pkg/tt"
 )
 
+// commands maps a sub-command name to its entry point.
+var commands = map[string]func(args []string) error{
+	"batch":         runBatch,
+	"captions":      runCaptions,
+	"convert":       runConvert,
+	"depth":         runDepth,
+	"diff":          runDiff,
+	"images":        runImages,
+	"import-images": runImportImages,
+	"import-pgs":    runImportPGS,
+	"import-vobsub": runImportVobSub,
+	"kdm":           runKDM,
+	"layout":        runLayout,
+	"matrix":        runMatrix,
+	"mxf":           runMXF,
+	"preview":       runPreview,
+	"reel-asset":    runReelAsset,
+	"serve":         runServe,
+	"verify":        runVerify,
+}
+
+// exitError is returned by a command that has already printed its usage or findings,
+// main exits with its value as the status.
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+const (
+	errFindings exitError = 1 // errors or differences were found and printed
+	errUsage    exitError = 2 // the command expression is invalid, usage was printed
+)
+
 func main() {
-	run()
+	var err error
+	if cmd, ok := commands[commandName()]; ok {
+		err = cmd(os.Args[2:])
+	} else {
+		err = run()
+	}
+	if err != nil {
+		var status exitError
+		if errors.As(err, &status) {
+			os.Exit(int(status))
+		}
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// commandName returns the sub-command named on the command line, if any.
+func commandName() string {
+	if len(os.Args) > 1 {
+		return os.Args[1]
+	}
+	return ""
+}
+
+// parseFlags parses the flags of a sub-command. A request for help returns exitError(0).
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitError(0)
+	}
+	if err != nil {
+		return errUsage
+	}
+	return nil
 }
 
 // run available command line flags.
-func run() {
+func run() error {
 
 	flag.BoolVar(&tt.Txt, "text", true, "- Inidcate that text profile is to be used.")
 	flag.BoolVar(&tt.Img, "image", false, "- Inidcate that image profile is to be used.")
 	flag.BoolVar(&tt.Track, "T", false, "- write MXF trackfile, requires '-d'")
 	flag.BoolVar(&tt.Encrypt, "e", false, "- encrypt trackfile")
-	flag.IntVar(&tt.Duration, "d", 24, "- set the duration of the track file.")
+	flag.BoolVar(&tt.Captions, "cc", false, "- use the closed caption mode, implies '-m 1' and the text profile")
+	flag.IntVar(&tt.Duration, "d", 0, "- set the duration of the track file, Default is the last TimeOut of the document.")
 	flag.StringVar(&tt.Framerate, "p", "24", "- set the frame rate of the track file.")
 	flag.IntVar(&tt.Display, "m", 0, "- set the DisplayType.'0'=MainSubtitle,'1'=ClosedCaption. (default '0')")
 	flag.IntVar(&tt.Reel, "r", 1, "- set the ReelNumber, Default ='1'")
@@ -50,15 +120,37 @@ func run() {
 	flag.StringVar(&tt.Title, "t", "No Title", "- set the ContentTitleText value.")
 	flag.StringVar(&tt.Template, "x", "", "- path to 428-7 XML to use as template")
 	flag.StringVar(&tt.Output, "o", "", "- set the output path, Default is StdOut")
+	flag.BoolVar(&tt.Checksums, "checksums", false, "- write a JSON and CSV manifest of the SHA-1, SHA-256 and MD5 checksums, sizes and UUIDs of the output, requires '-o'")
+	flag.BoolVar(&tt.Composition, "cpl", false, "- write a supplemental CPL, PKL, ASSETMAP and VOLINDEX of the track file, requires '-T' and '-o'")
+	flag.StringVar(&tt.Archive, "archive", "", "- package the output into a single 'zip' or 'tar.gz' archive with a checksum manifest, requires '-o'")
+	flag.Var((*stringList)(&tt.Fonts), "f", "- path to an OpenType/TrueType font resource, may be repeated")
+	flag.BoolVar(&tt.Subset, "subset", false, "- subset font resources to the glyphs used in the document")
+	flag.StringVar(&tt.Direction, "direction", "", "- set the Direction of the Text element, 'ltr', 'rtl', 'ttb' or 'btt'")
+	flag.StringVar(&tt.RubyText, "ruby", "", "- add a Ruby annotation to the Text element, given as 'base=annotation'")
+	flag.StringVar(&tt.Zposition, "z", "", "- set the Zposition of the Text or Image element in percent of the screen width")
+	flag.StringVar(&tt.VariableZ, "zvar", "", "- set a LoadVariableZ depth curve for the Text or Image element, e.g. '-1.0:24 -1.5:24'")
+	wrapper := flag.String("w", "asdcp", "- set the track file wrapper, 'asdcp' or 'native'")
+	asdcpPath := flag.String("asdcp", "", "- path to the asdcp-wrap binary, Default is asdcp-wrap at $PATH")
 	flag.Parse()
 	if len(flag.Args()) > 0 {
-		fmt.Println("check command expression")
-		os.Exit(1)
+		return errors.New("check command expression")
 	}
-	if err := tt.CreateXML(tt.Txt, tt.Img, tt.Track, tt.Encrypt, tt.Reel, tt.Display, tt.Duration, tt.Framerate, tt.Language, tt.Title, tt.Template, tt.Output); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	w, err := tt.NewWrapper(*wrapper, *asdcpPath)
+	if err != nil {
+		return err
 	}
+	tt.TrackWrapper = w
+	return tt.CreateXML(tt.Txt, tt.Img, tt.Track, tt.Encrypt, tt.Reel, tt.Display, tt.Duration, tt.Framerate, tt.Language, tt.Title, tt.Template, tt.Output)
+}
+
+// stringList is a flag.Value that collects repeated flags into a slice.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
 
-	return
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
 }
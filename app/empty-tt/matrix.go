@@ -0,0 +1,125 @@
+package main
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jack-watts/empty-tt/pkg/tt"
+)
+
+// runMatrix generates a server and player conformance test matrix.
+func runMatrix(args []string) error {
+	fs := flag.NewFlagSet("matrix", flag.ContinueOnError)
+	o := tt.DefaultMatrix
+	output := fs.String("o", "matrix", "- set the output path")
+	fs.IntVar(&o.Workers, "workers", 0, "- set the number of concurrent jobs, Default is the number of CPUs")
+	fs.StringVar(&o.Wrapper, "w", "asdcp", "- set the track file wrapper, 'asdcp' or 'native'")
+	fs.StringVar(&o.ASDCP, "asdcp", "", "- path to the asdcp-wrap binary, Default is asdcp-wrap at $PATH")
+	rates := fs.String("rates", strings.Join(o.EditRates, ","), "- set the comma separated edit rates")
+	namespaces := fs.String("ns", "2010,2014", "- set the comma separated namespace versions")
+	displays := fs.String("display", strings.Join(o.DisplayTypes, ","), "- set the comma separated display types")
+	profiles := fs.String("profiles", strings.Join(o.Profiles, ","), "- set the comma separated profiles")
+	encryption := fs.String("encryption", "clear,encrypted", "- set the comma separated encryption states")
+	reels := fs.String("reels", "1,2", "- set the comma separated reel numbers")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: empty-tt matrix [flags]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return errUsage
+	}
+	o.Output = *output
+	o.EditRates = split(*rates)
+	o.DisplayTypes = split(*displays)
+	o.Profiles = split(*profiles)
+	o.Namespaces = nil
+	for _, v := range split(*namespaces) {
+		switch v {
+		case "2010":
+			o.Namespaces = append(o.Namespaces, tt.DCST2010)
+		case "2014":
+			o.Namespaces = append(o.Namespaces, tt.DCST2014)
+		default:
+			return fmt.Errorf("unsupported namespace version: %s", v)
+		}
+	}
+	o.Encrypted = nil
+	for _, v := range split(*encryption) {
+		switch v {
+		case "clear":
+			o.Encrypted = append(o.Encrypted, false)
+		case "encrypted":
+			o.Encrypted = append(o.Encrypted, true)
+		default:
+			return fmt.Errorf("unsupported encryption state: %s", v)
+		}
+	}
+	o.Reels = nil
+	for _, v := range split(*reels) {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid reel number: %s", v)
+		}
+		o.Reels = append(o.Reels, n)
+	}
+	if err := os.MkdirAll(o.Output, 0755); err != nil {
+		return err
+	}
+	cases, err := tt.RunMatrix(o)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, c := range cases {
+		if c.Result.Error != "" {
+			failed++
+			fmt.Printf("%s: error: %s\n", c.Path, c.Result.Error)
+		}
+	}
+	fmt.Printf("%d cases, %d failed, index written to %s\n", len(cases), failed, o.Output)
+	if failed > 0 {
+		return errFindings
+	}
+	return nil
+}
+
+// split returns the non-empty comma separated values of s.
+func split(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,83 @@
+package main
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+
+	"github.com/jack-watts/empty-tt/pkg/mxf"
+)
+
+// runMXF reports the descriptor and ancillary resources of a timed text track file and
+// optionally extracts its XML document and resources.
+func runMXF(args []string) error {
+	fs := flag.NewFlagSet("mxf", flag.ContinueOnError)
+	keyHex := fs.String("k", "", "- set the hex encoded AES-128 key to decrypt an encrypted track file")
+	extract := fs.String("x", "", "- set the directory to extract the XML document and resources to")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: empty-tt mxf [flags] trackfile.mxf")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	var key []byte
+	if *keyHex != "" {
+		var err error
+		if key, err = hex.DecodeString(*keyHex); err != nil {
+			return fmt.Errorf("invalid key: %w", err)
+		}
+	}
+	t, err := mxf.Open(fs.Arg(0), key)
+	if err != nil {
+		return err
+	}
+	d := t.Descriptor
+	fmt.Printf("AssetUUID:         %s\n", t.AssetUUID)
+	fmt.Printf("ResourceID:        %s\n", d.ResourceID)
+	fmt.Printf("NamespaceURI:      %s\n", d.NamespaceURI)
+	fmt.Printf("UCSEncoding:       %s\n", d.UCSEncoding)
+	fmt.Printf("ContainerDuration: %d\n", d.ContainerDuration)
+	fmt.Printf("EditRate:          %s\n", d.EditRate)
+	fmt.Printf("Encrypted:         %t\n", t.Encrypted)
+	for _, r := range t.Resources {
+		fmt.Printf("Resource:          %s %s (%d bytes)\n", r.ID, r.MIMEType, len(r.Data))
+	}
+	if *extract == "" {
+		return nil
+	}
+	files, err := t.Extract(*extract)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		fmt.Println(f)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/jack-watts/empty-tt/pkg/tt"
+)
+
+// runPreview renders an ST 428-7 XML document into an HTML page and optional PNG frames.
+func runPreview(args []string) error {
+	fs := flag.NewFlagSet("preview", flag.ContinueOnError)
+	output := fs.String("o", "preview", "- set the output path")
+	frames := fs.Bool("png", false, "- render a PNG frame for every Subtitle event")
+	container := fs.String("c", "2k", "- set the DCI container size of PNG frames, '2k' or '4k'")
+	resources := fs.String("resources", "", "- path to font and image resources, Default is the document's directory")
+	stereo := fs.Bool("stereo", false, "- render PNG frames side by side for the left and right eye")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: empty-tt preview [flags] document.xml")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	s, err := tt.ParseXML(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	opts := tt.PreviewOptions{
+		Output:    *output,
+		Frames:    *frames,
+		Resources: *resources,
+		Stereo:    *stereo,
+	}
+	switch *container {
+	case "2k", "2K":
+		opts.Container = tt.Container2K
+	case "4k", "4K":
+		opts.Container = tt.Container4K
+	default:
+		return fmt.Errorf("unsupported container size: %s", *container)
+	}
+	if opts.Resources == "" {
+		opts.Resources = filepath.Dir(fs.Arg(0))
+	}
+	return tt.Preview(s, opts)
+}
@@ -0,0 +1,90 @@
+package main
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/jack-watts/empty-tt/pkg/server"
+	"github.com/jack-watts/empty-tt/pkg/tt"
+)
+
+// runServe serves the HTTP/JSON API of package server until interrupted.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "- set the listen address")
+	maxBytes := fs.Int64("max-bytes", server.DefaultMaxBytes, "- set the request body limit in bytes")
+	timeout := fs.Duration("timeout", server.DefaultTimeout, "- set the time limit of a request")
+	maxJobs := fs.Int("jobs", 0, "- set the number of create jobs run at once, Default is the number of CPUs")
+	wrapper := fs.String("w", "native", "- set the track file wrapper, 'asdcp' or 'native'")
+	asdcpPath := fs.String("asdcp", "", "- path to the asdcp-wrap binary, Default is asdcp-wrap at $PATH")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: empty-tt serve [flags]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return errUsage
+	}
+	w, err := tt.NewWrapper(*wrapper, *asdcpPath)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Addr: *addr,
+		Handler: server.Handler(server.Options{
+			MaxBytes: *maxBytes,
+			Timeout:  *timeout,
+			MaxJobs:  *maxJobs,
+			Wrapper:  w,
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       *timeout,
+		// leave room for the timeout response of the handler.
+		WriteTimeout: *timeout + 5*time.Second,
+	}
+	done := make(chan error, 1)
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt)
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		done <- srv.Shutdown(ctx)
+	}()
+	fmt.Fprintf(os.Stderr, "listening on %s\n", *addr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return <-done
+}
@@ -0,0 +1,102 @@
+package main
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/jack-watts/empty-tt/pkg/tt"
+)
+
+// trackFileName matches the file names written by tt.CreateMXF, capturing the AssetUUID.
+var trackFileName = regexp.MustCompile(`^([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})_r\d+_(sub|cap)\.mxf$`)
+
+// runVerify checks a timed text track file against the XML document and resources it was wrapped from,
+// or the files of a delivery manifest against their checksums. It returns errFindings when errors are found.
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	assetUUID := fs.String("a", "", "- set the expected AssetUUID, Default is taken from the track file name")
+	duration := fs.Int("d", 0, "- set the expected ContainerDuration, Default skips the check")
+	keyHex := fs.String("k", "", "- set the hex encoded AES-128 key of an encrypted track file")
+	resources := fs.String("resources", "", "- path to font and image resources, Default is the document's directory")
+	manifest := fs.String("manifest", "", "- check the files of a JSON or CSV delivery manifest written with '-checksums' instead")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: empty-tt verify [flags] trackfile.mxf document.xml")
+		fmt.Fprintln(fs.Output(), "       empty-tt verify -manifest manifest.json|manifest.csv")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *manifest != "" {
+		if fs.NArg() != 0 {
+			fs.Usage()
+			return errUsage
+		}
+		findings, err := tt.VerifyDeliveryManifest(*manifest)
+		if err != nil {
+			return err
+		}
+		for _, f := range findings {
+			fmt.Println(f)
+		}
+		if tt.HasErrors(findings) {
+			return errFindings
+		}
+		return nil
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errUsage
+	}
+	opts := tt.VerifyOptions{
+		Source:    fs.Arg(1),
+		Resources: *resources,
+		AssetUUID: *assetUUID,
+		Duration:  *duration,
+	}
+	if opts.AssetUUID == "" {
+		if m := trackFileName.FindStringSubmatch(filepath.Base(fs.Arg(0))); m != nil {
+			opts.AssetUUID = m[1]
+		}
+	}
+	if *keyHex != "" {
+		key, err := hex.DecodeString(*keyHex)
+		if err != nil {
+			return fmt.Errorf("invalid key: %w", err)
+		}
+		opts.Key = key
+	}
+	findings := tt.VerifyTrackFile(fs.Arg(0), opts)
+	for _, f := range findings {
+		fmt.Println(f)
+	}
+	if tt.HasErrors(findings) {
+		return errFindings
+	}
+	return nil
+}
@@ -23,11 +23,4 @@ else
   printf "Issues encountered. Build failed\n"
 fi
 
-mkdir -p resources/font
-printf "Copying resources..."
-# Copy resources
-if cp -rf ../resources/font/* ./resources/font/; then
-printf "\rCopying resources: done\n"
-fi
-
 exit $EXIT_STATUS
\ No newline at end of file
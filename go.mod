@@ -4,5 +4,10 @@ go 1.19
 
 require (
 	github.com/satori/go.uuid v1.2.0
+	golang.org/x/image v0.18.0
+)
+
+require (
+	golang.org/x/text v0.16.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 )
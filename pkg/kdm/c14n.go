@@ -0,0 +1,132 @@
+package kdm
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// attr is an XML attribute or namespace declaration.
+type attr struct {
+	name, value string
+}
+
+// element is a minimal XML element tree that serializes to its canonical form, so that the
+// document carries the exact bytes that are digested and signed.
+type element struct {
+	name     string
+	ns       []attr
+	attrs    []attr
+	text     string
+	children []*element
+}
+
+// newElement returns an element holding the given children.
+func newElement(name string, children ...*element) *element {
+	return &element{name: name, children: children}
+}
+
+// newText returns an element holding character data.
+func newText(name, text string) *element {
+	return &element{name: name, text: text}
+}
+
+// add appends children to an element.
+func (e *element) add(children ...*element) *element {
+	e.children = append(e.children, children...)
+	return e
+}
+
+// attr sets an attribute of an element.
+func (e *element) attr(name, value string) *element {
+	e.attrs = append(e.attrs, attr{name, value})
+	return e
+}
+
+// xmlns declares a namespace on an element. An empty prefix declares the default namespace.
+func (e *element) xmlns(prefix, uri string) *element {
+	e.ns = append(e.ns, attr{prefix, uri})
+	return e
+}
+
+// attrValue returns the value of an attribute.
+func (e *element) attrValue(name string) string {
+	for _, a := range e.attrs {
+		if a.name == name {
+			return a.value
+		}
+	}
+	return ""
+}
+
+// canonical writes the inclusive C14N 1.0 form of an element indented at depth. The namespaces
+// inherited from its ancestors are declared on the element itself, as required when a document
+// subset is canonicalized for digesting.
+func (e *element) canonical(b *bytes.Buffer, depth int, inherited []attr) {
+	ns := append(append([]attr(nil), inherited...), e.ns...)
+	sort.SliceStable(ns, func(i, j int) bool { return ns[i].name < ns[j].name })
+	attrs := append([]attr(nil), e.attrs...)
+	sort.SliceStable(attrs, func(i, j int) bool { return attrs[i].name < attrs[j].name })
+
+	b.WriteString("<" + e.name)
+	for _, n := range ns {
+		if n.name == "" {
+			b.WriteString(` xmlns="` + escapeAttr(n.value) + `"`)
+		} else {
+			b.WriteString(" xmlns:" + n.name + `="` + escapeAttr(n.value) + `"`)
+		}
+	}
+	for _, a := range attrs {
+		b.WriteString(" " + a.name + `="` + escapeAttr(a.value) + `"`)
+	}
+	b.WriteString(">")
+	if len(e.children) == 0 {
+		b.WriteString(escapeText(e.text))
+	}
+	for _, c := range e.children {
+		b.WriteString("\n" + strings.Repeat("  ", depth+1))
+		c.canonical(b, depth+1, nil)
+	}
+	if len(e.children) > 0 {
+		b.WriteString("\n" + strings.Repeat("  ", depth))
+	}
+	b.WriteString("</" + e.name + ">")
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+// escapeText escapes character data as required by C14N.
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// escapeAttr escapes an attribute value as required by C14N.
+func escapeAttr(s string) string {
+	return attrEscaper.Replace(s)
+}
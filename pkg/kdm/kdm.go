@@ -0,0 +1,325 @@
+// Package kdm builds SMPTE ST 430-1 Key Delivery Messages for encrypted D-Cinema track files.
+//
+/* Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see <http://www.gnu.org/licenses/>.*/
+package kdm
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Namespaces and algorithm identifiers of ST 430-1 and ST 430-3.
+const (
+	nsETM = "http://www.smpte-ra.org/schemas/430-3/2006/ETM"
+	nsKDM = "http://www.smpte-ra.org/schemas/430-1/2006/KDM"
+	nsDS  = "http://www.w3.org/2000/09/xmldsig#"
+	nsEnc = "http://www.w3.org/2001/04/xmlenc#"
+
+	messageType  = "http://www.smpte-ra.org/430-1/2006/KDM#kdm-key-type"
+	c14nMethod   = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315#WithComments"
+	signMethod   = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	digestMethod = "http://www.w3.org/2001/04/xmlenc#sha256"
+	oaepMethod   = "http://www.w3.org/2001/04/xmlenc#rsa-oaep-mgf1p"
+	oaepDigest   = "http://www.w3.org/2000/09/xmldsig#sha1"
+
+	idPublic  = "ID_AuthenticatedPublic"
+	idPrivate = "ID_AuthenticatedPrivate"
+	urn       = "urn:uuid:"
+	// timeLayout is the fixed 25 character time format of the RSA protected key block.
+	timeLayout = "2006-01-02T15:04:05-07:00"
+)
+
+// Key types of the subtitle and caption essence.
+const (
+	KeyTypeSubtitle = "MDSK"
+	KeyTypeAudio    = "MDAK"
+	KeyTypePicture  = "MDIK"
+)
+
+// AssumeTrust is the certificate thumbprint that authorizes any device of the recipient's security manager.
+const AssumeTrust = "2jmj7l5rSw0yVb/vlWAYkK/YBwk="
+
+// structureID identifies the layout of the RSA protected key block.
+var structureID = []byte{0xf1, 0xdc, 0x12, 0x44, 0x60, 0x16, 0x9a, 0x0e, 0x85, 0xbc, 0x30, 0x06, 0x42, 0xf8, 0x66, 0xab}
+
+// Key is a content key with its key ID.
+type Key struct {
+	// ID is the key ID as a UUID.
+	ID string
+	// Type is the key type, e.g. KeyTypeSubtitle.
+	Type string
+	// Key is the 16-byte AES content key.
+	Key []byte
+}
+
+// Options configures Generate.
+type Options struct {
+	// Signer is the signer certificate chain ordered from the signer certificate to the root.
+	Signer []*x509.Certificate
+	// SignerKey is the private key of the signer certificate.
+	SignerKey *rsa.PrivateKey
+	// Recipient is the certificate of the security manager the content keys are encrypted for.
+	Recipient *x509.Certificate
+	// TrustedDevices is the list of certificate thumbprints of the TDL. Defaults to the thumbprint
+	// of the recipient certificate.
+	TrustedDevices []string
+	// CPLID is the Id of the composition playlist the KDM is issued for.
+	CPLID          string
+	ContentTitle   string
+	AnnotationText string
+	NotValidBefore time.Time
+	NotValidAfter  time.Time
+	// IssueDate defaults to the current time.
+	IssueDate time.Time
+	Keys      []Key
+}
+
+// Generate builds and signs a KDM carrying the content keys encrypted for the recipient certificate.
+func Generate(opts Options) ([]byte, error) {
+	if err := checkOptions(&opts); err != nil {
+		return nil, err
+	}
+	signer := opts.Signer[0]
+	recipientKey, ok := opts.Recipient.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("kdm: recipient certificate does not hold an RSA public key")
+	}
+	cplID, err := uuidBytes(opts.CPLID)
+	if err != nil {
+		return nil, fmt.Errorf("kdm: invalid CPL Id: %w", err)
+	}
+
+	// AuthenticatedPublic
+	devices := newElement("DeviceList")
+	for _, t := range opts.TrustedDevices {
+		devices.add(newText("CertificateThumbprint", t))
+	}
+	keyIDs := newElement("KeyIdList")
+	for _, k := range opts.Keys {
+		keyIDs.add(newElement("TypedKeyId",
+			newText("KeyType", k.Type).attr("scope", messageType),
+			newText("KeyId", urn+k.ID),
+		))
+	}
+	ext := newElement("KDMRequiredExtensions",
+		newElement("Recipient",
+			newElement("X509IssuerSerial",
+				newText("ds:X509IssuerName", distinguishedName(opts.Recipient.RawIssuer)),
+				newText("ds:X509SerialNumber", opts.Recipient.SerialNumber.String()),
+			),
+			newText("X509SubjectName", distinguishedName(opts.Recipient.RawSubject)),
+		),
+		newText("CompositionPlaylistId", urn+opts.CPLID),
+		newText("ContentTitleText", opts.ContentTitle),
+		newText("ContentKeysNotValidBefore", opts.NotValidBefore.Format(timeLayout)),
+		newText("ContentKeysNotValidAfter", opts.NotValidAfter.Format(timeLayout)),
+		newElement("AuthorizedDeviceInfo",
+			newText("DeviceListIdentifier", urn+uuid.NewV4().String()),
+			devices,
+		),
+		keyIDs,
+	).xmlns("", nsKDM)
+	public := newElement("AuthenticatedPublic",
+		newText("MessageId", urn+uuid.NewV4().String()),
+		newText("MessageType", messageType),
+		newText("AnnotationText", opts.AnnotationText),
+		newText("IssueDate", opts.IssueDate.Format(timeLayout)),
+		newElement("Signer",
+			newText("ds:X509IssuerName", distinguishedName(signer.RawIssuer)),
+			newText("ds:X509SerialNumber", signer.SerialNumber.String()),
+		),
+		newElement("RequiredExtensions", ext),
+		newElement("NonCriticalExtensions"),
+	).attr("Id", idPublic)
+
+	// AuthenticatedPrivate
+	private := newElement("AuthenticatedPrivate").attr("Id", idPrivate)
+	for _, k := range opts.Keys {
+		block, err := keyBlock(signer, cplID, k, opts.NotValidBefore, opts.NotValidAfter)
+		if err != nil {
+			return nil, err
+		}
+		cipherValue, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, recipientKey, block, nil)
+		if err != nil {
+			return nil, fmt.Errorf("kdm: %w", err)
+		}
+		private.add(newElement("enc:EncryptedKey",
+			newElement("enc:EncryptionMethod",
+				newElement("ds:DigestMethod").attr("Algorithm", oaepDigest),
+			).attr("Algorithm", oaepMethod),
+			newElement("enc:CipherData",
+				newText("enc:CipherValue", base64.StdEncoding.EncodeToString(cipherValue)),
+			),
+		))
+	}
+
+	root := newElement("DCinemaSecurityMessage", public, private).
+		xmlns("", nsETM).xmlns("ds", nsDS).xmlns("enc", nsEnc)
+	signature, err := sign(root, opts.Signer, opts.SignerKey)
+	if err != nil {
+		return nil, err
+	}
+	root.add(signature)
+
+	var b bytes.Buffer
+	b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"no\"?>\n")
+	root.canonical(&b, 0, nil)
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// checkOptions validates the options and fills in their defaults.
+func checkOptions(opts *Options) error {
+	switch {
+	case len(opts.Signer) == 0:
+		return errors.New("kdm: a signer certificate is required")
+	case opts.SignerKey == nil:
+		return errors.New("kdm: a signer private key is required")
+	case opts.Recipient == nil:
+		return errors.New("kdm: a recipient certificate is required")
+	case len(opts.Keys) == 0:
+		return errors.New("kdm: at least one content key is required")
+	case !opts.NotValidAfter.After(opts.NotValidBefore):
+		return errors.New("kdm: ContentKeysNotValidAfter must be after ContentKeysNotValidBefore")
+	}
+	if pub, ok := opts.Signer[0].PublicKey.(*rsa.PublicKey); !ok || pub.N.Cmp(opts.SignerKey.N) != 0 {
+		return errors.New("kdm: signer private key does not match the signer certificate")
+	}
+	for i := 0; i+1 < len(opts.Signer); i++ {
+		if err := opts.Signer[i].CheckSignatureFrom(opts.Signer[i+1]); err != nil {
+			return fmt.Errorf("kdm: signer chain is not ordered from signer to root: %w", err)
+		}
+	}
+	opts.CPLID = strings.TrimPrefix(opts.CPLID, urn)
+	for i, k := range opts.Keys {
+		opts.Keys[i].ID = strings.TrimPrefix(k.ID, urn)
+		if len(k.Key) != 16 {
+			return fmt.Errorf("kdm: key %s must be 16 bytes, got %d", k.ID, len(k.Key))
+		}
+		if _, err := uuidBytes(k.ID); err != nil {
+			return fmt.Errorf("kdm: invalid key ID: %w", err)
+		}
+		if k.Type == "" {
+			opts.Keys[i].Type = KeyTypeSubtitle
+		}
+	}
+	if len(opts.TrustedDevices) == 0 {
+		opts.TrustedDevices = []string{Thumbprint(opts.Recipient)}
+	}
+	if opts.IssueDate.IsZero() {
+		opts.IssueDate = time.Now()
+	}
+	opts.NotValidBefore = opts.NotValidBefore.UTC()
+	opts.NotValidAfter = opts.NotValidAfter.UTC()
+	opts.IssueDate = opts.IssueDate.UTC()
+	return nil
+}
+
+// keyBlock returns the 138-byte plaintext of an RSA protected content key.
+func keyBlock(signer *x509.Certificate, cplID []byte, k Key, notBefore, notAfter time.Time) ([]byte, error) {
+	keyID, err := uuidBytes(k.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(k.Type) != 4 {
+		return nil, fmt.Errorf("kdm: invalid key type %q", k.Type)
+	}
+	thumb := sha1.Sum(signer.RawTBSCertificate)
+	var b bytes.Buffer
+	b.Write(structureID)
+	b.Write(thumb[:])
+	b.Write(cplID)
+	b.WriteString(k.Type)
+	b.Write(keyID)
+	b.WriteString(notBefore.Format(timeLayout))
+	b.WriteString(notAfter.Format(timeLayout))
+	b.Write(k.Key)
+	return b.Bytes(), nil
+}
+
+// sign returns the enveloped XML-DSig Signature over AuthenticatedPublic and AuthenticatedPrivate.
+func sign(root *element, chain []*x509.Certificate, key *rsa.PrivateKey) (*element, error) {
+	signedInfo := newElement("ds:SignedInfo",
+		newElement("ds:CanonicalizationMethod").attr("Algorithm", c14nMethod),
+		newElement("ds:SignatureMethod").attr("Algorithm", signMethod),
+	)
+	for _, e := range root.children {
+		var b bytes.Buffer
+		e.canonical(&b, 1, root.ns)
+		sum := sha256.Sum256(b.Bytes())
+		signedInfo.add(newElement("ds:Reference",
+			newElement("ds:DigestMethod").attr("Algorithm", digestMethod),
+			newText("ds:DigestValue", base64.StdEncoding.EncodeToString(sum[:])),
+		).attr("URI", "#"+e.attrValue("Id")))
+	}
+	var b bytes.Buffer
+	signedInfo.canonical(&b, 2, root.ns)
+	sum := sha256.Sum256(b.Bytes())
+	value, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return nil, fmt.Errorf("kdm: %w", err)
+	}
+
+	keyInfo := newElement("ds:KeyInfo")
+	for _, c := range chain {
+		keyInfo.add(newElement("ds:X509Data",
+			newElement("ds:X509IssuerSerial",
+				newText("ds:X509IssuerName", distinguishedName(c.RawIssuer)),
+				newText("ds:X509SerialNumber", c.SerialNumber.String()),
+			),
+			newText("ds:X509Certificate", base64.StdEncoding.EncodeToString(c.Raw)),
+		))
+	}
+	return newElement("ds:Signature",
+		signedInfo,
+		newText("ds:SignatureValue", base64.StdEncoding.EncodeToString(value)),
+		keyInfo,
+	), nil
+}
+
+// Thumbprint returns the base64 encoded SHA-1 digest of a certificate's TBSCertificate.
+func Thumbprint(c *x509.Certificate) string {
+	sum := sha1.Sum(c.RawTBSCertificate)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// uuidBytes decodes a UUID with or without its urn:uuid: prefix.
+func uuidBytes(s string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.Replace(strings.TrimPrefix(s, urn), "-", "", -1))
+	if err != nil || len(b) != 16 {
+		return nil, fmt.Errorf("%q is not a UUID", s)
+	}
+	return b, nil
+}
@@ -0,0 +1,364 @@
+package kdm
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testCertificate returns a certificate for a new RSA key, signed by parent or self-signed.
+func testCertificate(t *testing.T, name string, bits int, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{Organization: []string{"empty-tt"}, CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, key
+}
+
+// testPKI holds the certificates of the tests, generated once.
+var testPKI struct {
+	root, signer, recipient *x509.Certificate
+	signerKey, recipientKey *rsa.PrivateKey
+}
+
+// testOptions returns the options of a KDM for a single subtitle key. The recipient key must be
+// large enough to hold the 138-byte key block with OAEP padding.
+func testOptions(t *testing.T) (Options, *rsa.PrivateKey) {
+	p := &testPKI
+	if p.root == nil {
+		root, rootKey := testCertificate(t, "root", 1024, nil, nil)
+		p.signer, p.signerKey = testCertificate(t, "signer", 1024, root, rootKey)
+		p.recipient, p.recipientKey = testCertificate(t, "recipient", 2048, root, rootKey)
+		p.root = root
+	}
+	root, signer, signerKey, recipient, recipientKey := p.root, p.signer, p.signerKey, p.recipient, p.recipientKey
+	start := time.Date(2020, 11, 3, 0, 0, 0, 0, time.UTC)
+	return Options{
+		Signer:         []*x509.Certificate{signer, root},
+		SignerKey:      signerKey,
+		Recipient:      recipient,
+		CPLID:          "urn:uuid:6a1c1b3e-1c36-4a36-9b1e-2d3c4b5a6978",
+		ContentTitle:   "No Title",
+		NotValidBefore: start,
+		NotValidAfter:  start.Add(7 * 24 * time.Hour),
+		Keys: []Key{{
+			ID:  "0d0b1d8a-7e8f-4a0b-8c5d-6e7f8a9b0c1d",
+			Key: []byte("0123456789abcdef"),
+		}},
+	}, recipientKey
+}
+
+// testKDM holds the parts of a KDM checked by the tests.
+type testKDM struct {
+	KeyIDs      []string `xml:"AuthenticatedPublic>RequiredExtensions>KDMRequiredExtensions>KeyIdList>TypedKeyId>KeyId"`
+	Devices     []string `xml:"AuthenticatedPublic>RequiredExtensions>KDMRequiredExtensions>AuthorizedDeviceInfo>DeviceList>CertificateThumbprint"`
+	CPLID       string   `xml:"AuthenticatedPublic>RequiredExtensions>KDMRequiredExtensions>CompositionPlaylistId"`
+	CipherValue []string `xml:"AuthenticatedPrivate>EncryptedKey>CipherData>CipherValue"`
+	References  []struct {
+		URI         string `xml:"URI,attr"`
+		DigestValue string
+	} `xml:"Signature>SignedInfo>Reference"`
+	SignatureValue string   `xml:"Signature>SignatureValue"`
+	Certs          []string `xml:"Signature>KeyInfo>X509Data>X509Certificate"`
+}
+
+// canonicalize returns the inclusive C14N 1.0 form, with comments, of the first element of an
+// XML document for which match returns true. It decodes the document itself rather than using the
+// element tree, so that the digests and signature of a KDM are checked against its actual bytes.
+func canonicalize(t *testing.T, data []byte, match func(xml.StartElement) bool) []byte {
+	t.Helper()
+	name := func(n xml.Name) string {
+		if n.Space == "" {
+			return n.Local
+		}
+		return n.Space + ":" + n.Local
+	}
+	var (
+		b        bytes.Buffer
+		declared []map[string]string // namespaces declared by the open elements
+		rendered []map[string]string // namespaces in scope of the output, from the matched element
+	)
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			t.Fatal("no element to canonicalize")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			ns := map[string]string{}
+			var attrs []string
+			for _, a := range tok.Attr {
+				switch {
+				case a.Name.Space == "" && a.Name.Local == "xmlns":
+					ns[""] = a.Value
+				case a.Name.Space == "xmlns":
+					ns[a.Name.Local] = a.Value
+				default:
+					attrs = append(attrs, " "+name(a.Name)+`="`+escapeAttr(a.Value)+`"`)
+				}
+			}
+			declared = append(declared, ns)
+			if rendered == nil && !match(tok) {
+				continue
+			}
+			// the matched element declares every namespace in scope, its descendants only those
+			// that differ from the output.
+			inScope, parent := ns, map[string]string{"": ""}
+			if rendered == nil {
+				inScope = map[string]string{}
+				for _, m := range declared {
+					for p, uri := range m {
+						inScope[p] = uri
+					}
+				}
+			} else {
+				parent = rendered[len(rendered)-1]
+			}
+			out := map[string]string{}
+			for p, uri := range parent {
+				out[p] = uri
+			}
+			var prefixes []string
+			for p, uri := range inScope {
+				if v, ok := parent[p]; !ok || v != uri {
+					out[p] = uri
+					prefixes = append(prefixes, p)
+				}
+			}
+			rendered = append(rendered, out)
+			sort.Strings(prefixes)
+			sort.Strings(attrs)
+			b.WriteString("<" + name(tok.Name))
+			for _, p := range prefixes {
+				if p == "" {
+					b.WriteString(` xmlns="` + escapeAttr(out[p]) + `"`)
+				} else {
+					b.WriteString(" xmlns:" + p + `="` + escapeAttr(out[p]) + `"`)
+				}
+			}
+			for _, a := range attrs {
+				b.WriteString(a)
+			}
+			b.WriteString(">")
+		case xml.EndElement:
+			declared = declared[:len(declared)-1]
+			if rendered == nil {
+				continue
+			}
+			b.WriteString("</" + name(tok.Name) + ">")
+			if rendered = rendered[:len(rendered)-1]; len(rendered) == 0 {
+				return b.Bytes()
+			}
+		case xml.CharData:
+			if rendered != nil {
+				b.WriteString(escapeText(string(tok)))
+			}
+		case xml.Comment:
+			if rendered != nil {
+				b.WriteString("<!--" + string(tok) + "-->")
+			}
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	opts, recipientKey := testOptions(t)
+	data, err := Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var k testKDM
+	if err := xml.Unmarshal(data, &k); err != nil {
+		t.Fatal(err)
+	}
+	if len(k.KeyIDs) != 1 || k.KeyIDs[0] != urn+opts.Keys[0].ID || k.CPLID != opts.CPLID {
+		t.Errorf("got key IDs %v and CPL %s", k.KeyIDs, k.CPLID)
+	}
+	if len(k.Devices) != 1 || k.Devices[0] != Thumbprint(opts.Recipient) {
+		t.Errorf("got trusted devices %v, want the recipient thumbprint", k.Devices)
+	}
+	if len(k.References) != 2 || len(k.Certs) != 2 {
+		t.Fatalf("got %d signature references and %d certificates, want 2 and 2", len(k.References), len(k.Certs))
+	}
+
+	// the references digest the canonical AuthenticatedPublic and AuthenticatedPrivate, and the
+	// signer certificate verifies the signature over the canonical SignedInfo.
+	for i, id := range []string{idPublic, idPrivate} {
+		r := k.References[i]
+		if r.URI != "#"+id {
+			t.Errorf("reference %d: got URI %s, want #%s", i, r.URI, id)
+		}
+		c := canonicalize(t, data, func(e xml.StartElement) bool {
+			for _, a := range e.Attr {
+				if a.Name.Local == "Id" && "#"+a.Value == r.URI {
+					return true
+				}
+			}
+			return false
+		})
+		sum := sha256.Sum256(c)
+		if got := base64.StdEncoding.EncodeToString(sum[:]); got != r.DigestValue {
+			t.Errorf("reference %s: got digest %s, want %s", r.URI, r.DigestValue, got)
+		}
+	}
+	cert, err := base64.StdEncoding.DecodeString(k.Certs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cert, opts.Signer[0].Raw) {
+		t.Fatal("the first certificate of the signature is not the signer")
+	}
+	value, err := base64.StdEncoding.DecodeString(k.SignatureValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(canonicalize(t, data, func(e xml.StartElement) bool {
+		return e.Name.Space == "ds" && e.Name.Local == "SignedInfo"
+	}))
+	if err := rsa.VerifyPKCS1v15(opts.Signer[0].PublicKey.(*rsa.PublicKey), crypto.SHA256, sum[:], value); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+	if len(k.CipherValue) != 1 {
+		t.Fatalf("got %d encrypted keys, want 1", len(k.CipherValue))
+	}
+
+	// the recipient recovers the content key and its constraints.
+	cipherValue, err := base64.StdEncoding.DecodeString(k.CipherValue[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := rsa.DecryptOAEP(sha1.New(), nil, recipientKey, cipherValue, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block) != 138 {
+		t.Fatalf("got a key block of %d bytes, want 138", len(block))
+	}
+	thumb := sha1.Sum(opts.Signer[0].RawTBSCertificate)
+	cplID, _ := uuidBytes(opts.CPLID)
+	keyID, _ := uuidBytes(opts.Keys[0].ID)
+	for _, f := range []struct {
+		name      string
+		got, want []byte
+	}{
+		{"structure ID", block[:16], structureID},
+		{"signer thumbprint", block[16:36], thumb[:]},
+		{"CPL Id", block[36:52], cplID},
+		{"key type", block[52:56], []byte(KeyTypeSubtitle)},
+		{"key ID", block[56:72], keyID},
+		{"not valid before", block[72:97], []byte("2020-11-03T00:00:00+00:00")},
+		{"not valid after", block[97:122], []byte("2020-11-10T00:00:00+00:00")},
+		{"key", block[122:], opts.Keys[0].Key},
+	} {
+		if !bytes.Equal(f.got, f.want) {
+			t.Errorf("%s: got %x, want %x", f.name, f.got, f.want)
+		}
+	}
+}
+
+func TestGenerateInvalidOptions(t *testing.T) {
+	other, otherKey := testCertificate(t, "other", 1024, nil, nil)
+	for _, tc := range []struct {
+		name   string
+		modify func(*Options)
+	}{
+		{"no signer", func(o *Options) { o.Signer = nil }},
+		{"no signer key", func(o *Options) { o.SignerKey = nil }},
+		{"signer key mismatch", func(o *Options) { o.SignerKey = otherKey }},
+		{"unordered chain", func(o *Options) { o.Signer = []*x509.Certificate{o.Signer[1], o.Signer[0]} }},
+		{"unrelated chain", func(o *Options) { o.Signer = append(o.Signer[:1], other) }},
+		{"no recipient", func(o *Options) { o.Recipient = nil }},
+		{"no keys", func(o *Options) { o.Keys = nil }},
+		{"short key", func(o *Options) { o.Keys[0].Key = o.Keys[0].Key[:8] }},
+		{"invalid key ID", func(o *Options) { o.Keys[0].ID = "not-a-uuid" }},
+		{"invalid key type", func(o *Options) { o.Keys[0].Type = "SUBTITLE" }},
+		{"invalid CPL Id", func(o *Options) { o.CPLID = "urn:uuid:1234" }},
+		{"empty validity period", func(o *Options) { o.NotValidAfter = o.NotValidBefore }},
+	} {
+		opts, _ := testOptions(t)
+		tc.modify(&opts)
+		if _, err := Generate(opts); err == nil {
+			t.Errorf("%s: Generate returned no error", tc.name)
+		}
+	}
+}
+
+func TestLoadMalformed(t *testing.T) {
+	dir := t.TempDir()
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("not DER")})
+	key := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("not DER")})
+	for i, data := range [][]byte{nil, []byte("not PEM"), cert, key, cert[:len(cert)/2]} {
+		name := filepath.Join(dir, "input.pem")
+		if err := ioutil.WriteFile(name, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadCertificates(name); err == nil {
+			t.Errorf("input %d: LoadCertificates returned no error", i)
+		}
+		if _, err := LoadPrivateKey(name); err == nil {
+			t.Errorf("input %d: LoadPrivateKey returned no error", i)
+		}
+	}
+}
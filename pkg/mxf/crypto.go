@@ -0,0 +1,88 @@
+package mxf
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	aesKeySize = 16
+	// checkValue is the plaintext of the ST 429-6 encrypted check value.
+	checkValue = "CHUKCHUKCHUKCHUK"
+)
+
+// decryptTriplet decodes an ST 429-6 encrypted triplet, returning the source key and plaintext value.
+func decryptTriplet(value, key []byte) (UL, []byte, error) {
+	var (
+		src    UL
+		fields [5][]byte
+	)
+	p := 0
+	for i := range fields {
+		length, n, err := readBER(value[p:])
+		if err != nil {
+			return src, nil, err
+		}
+		p += n
+		if uint64(len(value)-p) < length {
+			return src, nil, errors.New("mxf: truncated encrypted triplet")
+		}
+		fields[i] = value[p : p+int(length)]
+		p += int(length)
+	}
+	offset, srcKey, srcLength, esv := fields[1], fields[2], fields[3], fields[4]
+	if len(offset) != 8 || len(srcKey) != 16 || len(srcLength) != 8 {
+		return src, nil, errors.New("mxf: invalid encrypted triplet")
+	}
+	copy(src[:], srcKey)
+	if binary.BigEndian.Uint64(offset) > uint64(len(esv)) || binary.BigEndian.Uint64(srcLength) > uint64(len(esv)) {
+		return src, nil, errors.New("mxf: invalid encrypted triplet")
+	}
+	plain := int(binary.BigEndian.Uint64(offset))
+	length := int(binary.BigEndian.Uint64(srcLength))
+	if len(esv) < 2*aes.BlockSize+plain || (len(esv)-2*aes.BlockSize-plain)%aes.BlockSize != 0 {
+		return src, nil, errors.New("mxf: invalid encrypted source value")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return src, nil, err
+	}
+	iv, check := esv[:aes.BlockSize], esv[aes.BlockSize:2*aes.BlockSize]
+	sum := make([]byte, aes.BlockSize)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(sum, check)
+	if string(sum) != checkValue {
+		return src, nil, errors.New("mxf: decryption key does not match the track file")
+	}
+	out := append([]byte(nil), esv[2*aes.BlockSize:]...)
+	cipher.NewCBCDecrypter(block, check).CryptBlocks(out[plain:], out[plain:])
+	if length > len(out) {
+		return src, nil, errors.New("mxf: invalid encrypted source length")
+	}
+	return src, out[:length], nil
+}
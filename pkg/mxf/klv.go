@@ -0,0 +1,224 @@
+package mxf
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+// UL is a SMPTE Universal Label.
+type UL [16]byte
+
+// Universal labels of the structural metadata and essence elements of a timed text track file.
+var (
+	partitionPack           = UL{0x06, 0x0e, 0x2b, 0x34, 0x02, 0x05, 0x01, 0x01, 0x0d, 0x01, 0x02, 0x01, 0x01, 0x00, 0x00, 0x00}
+	primerPack              = UL{0x06, 0x0e, 0x2b, 0x34, 0x02, 0x05, 0x01, 0x01, 0x0d, 0x01, 0x02, 0x01, 0x01, 0x05, 0x01, 0x00}
+	sourcePackage           = UL{0x06, 0x0e, 0x2b, 0x34, 0x02, 0x53, 0x01, 0x01, 0x0d, 0x01, 0x01, 0x01, 0x01, 0x01, 0x37, 0x00}
+	timedTextDescriptor     = UL{0x06, 0x0e, 0x2b, 0x34, 0x02, 0x53, 0x01, 0x01, 0x0d, 0x01, 0x01, 0x01, 0x01, 0x01, 0x64, 0x00}
+	timedTextResourceSubDsc = UL{0x06, 0x0e, 0x2b, 0x34, 0x02, 0x53, 0x01, 0x01, 0x0d, 0x01, 0x01, 0x01, 0x01, 0x01, 0x65, 0x00}
+	timedTextEssence        = UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x02, 0x01, 0x01, 0x0d, 0x01, 0x03, 0x01, 0x17, 0x01, 0x0b, 0x01}
+	genericStreamData       = UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x0c, 0x0d, 0x01, 0x05, 0x09, 0x01, 0x00, 0x00, 0x00}
+	encryptedTriplet        = UL{0x06, 0x0e, 0x2b, 0x34, 0x02, 0x04, 0x01, 0x07, 0x0d, 0x01, 0x03, 0x01, 0x02, 0x7e, 0x01, 0x00}
+
+	ulResourceID          = UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x0c, 0x01, 0x01, 0x15, 0x12, 0x00, 0x00, 0x00, 0x00}
+	ulUCSEncoding         = UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x08, 0x04, 0x09, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00}
+	ulNamespaceURI        = UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x08, 0x01, 0x02, 0x01, 0x05, 0x01, 0x00, 0x00, 0x00}
+	ulAncillaryResourceID = UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x0c, 0x01, 0x01, 0x15, 0x13, 0x00, 0x00, 0x00, 0x00}
+	ulMIMEMediaType       = UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x07, 0x04, 0x09, 0x07, 0x00, 0x00, 0x00, 0x00, 0x00}
+	ulEssenceStreamID     = UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x0a, 0x01, 0x03, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00}
+)
+
+// static local tags of ST 377-1.
+const (
+	tagSampleRate        = 0x3001
+	tagContainerDuration = 0x3002
+	tagPackageUID        = 0x4401
+)
+
+// partition kinds, byte 13 of a partition pack key.
+const (
+	partitionHeader = 0x02
+	partitionBody   = 0x03
+	partitionFooter = 0x04
+)
+
+// offset of the BodySID within a partition pack.
+const partitionBodySID = 60
+
+// equal compares two ULs ignoring the version byte.
+func (u UL) equal(v UL) bool {
+	return bytes.Equal(u[:7], v[:7]) && bytes.Equal(u[8:], v[8:])
+}
+
+// isPartition reports whether the key is a partition pack.
+func (u UL) isPartition() bool {
+	return bytes.Equal(u[:7], partitionPack[:7]) && bytes.Equal(u[8:13], partitionPack[8:13]) && u[13] >= partitionHeader && u[13] <= partitionFooter
+}
+
+// isSet compares a local set key ignoring the registry designator and version bytes.
+func (u UL) isSet(v UL) bool {
+	return bytes.Equal(u[:5], v[:5]) && bytes.Equal(u[8:], v[8:])
+}
+
+// String returns the dotted hex representation of a UL.
+func (u UL) String() string {
+	var b bytes.Buffer
+	for i, v := range u {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		fmt.Fprintf(&b, "%02x", v)
+	}
+	return b.String()
+}
+
+// klv is a single key, length, value triplet.
+type klv struct {
+	key   UL
+	value []byte
+}
+
+// readKLV reads the triplet at the start of data and returns the number of bytes consumed.
+func readKLV(data []byte) (*klv, int, error) {
+	if len(data) < 17 {
+		return nil, 0, errors.New("mxf: truncated KLV key")
+	}
+	k := &klv{}
+	copy(k.key[:], data)
+	length, n, err := readBER(data[16:])
+	if err != nil {
+		return nil, 0, err
+	}
+	start := 16 + n
+	if uint64(len(data)-start) < length {
+		return nil, 0, fmt.Errorf("mxf: truncated KLV value for %s", k.key)
+	}
+	end := start + int(length)
+	k.value = data[start:end]
+	return k, end, nil
+}
+
+// readBER reads a BER encoded length and returns the number of bytes consumed.
+func readBER(data []byte) (uint64, int, error) {
+	if len(data) == 0 {
+		return 0, 0, errors.New("mxf: truncated BER length")
+	}
+	if data[0] < 0x80 {
+		return uint64(data[0]), 1, nil
+	}
+	n := int(data[0] & 0x7f)
+	if n > 8 || len(data) < 1+n {
+		return 0, 0, errors.New("mxf: invalid BER length")
+	}
+	var v uint64
+	for _, b := range data[1 : 1+n] {
+		v = v<<8 | uint64(b)
+	}
+	return v, 1 + n, nil
+}
+
+// localSet is a decoded local set keyed by the UL of each item.
+type localSet struct {
+	static  map[uint16][]byte
+	dynamic map[UL][]byte
+}
+
+// readLocalSet decodes a local set using the primer pack tag mapping.
+func readLocalSet(value []byte, primer map[uint16]UL) (*localSet, error) {
+	s := &localSet{static: make(map[uint16][]byte), dynamic: make(map[UL][]byte)}
+	for p := 0; p < len(value); {
+		if p+4 > len(value) {
+			return nil, errors.New("mxf: truncated local set")
+		}
+		tag := binary.BigEndian.Uint16(value[p:])
+		length := int(binary.BigEndian.Uint16(value[p+2:]))
+		p += 4
+		if p+length > len(value) {
+			return nil, errors.New("mxf: truncated local set item")
+		}
+		v := value[p : p+length]
+		p += length
+		s.static[tag] = v
+		if ul, ok := primer[tag]; ok {
+			s.dynamic[ul] = v
+		}
+	}
+	return s, nil
+}
+
+// get returns the value of an item identified by its UL.
+func (s *localSet) get(ul UL) []byte {
+	for k, v := range s.dynamic {
+		if k.equal(ul) {
+			return v
+		}
+	}
+	return nil
+}
+
+// readPrimer decodes a primer pack into a local tag to UL mapping.
+func readPrimer(value []byte) (map[uint16]UL, error) {
+	if len(value) < 8 {
+		return nil, errors.New("mxf: truncated primer pack")
+	}
+	n := int(binary.BigEndian.Uint32(value))
+	size := int(binary.BigEndian.Uint32(value[4:]))
+	if size != 18 || len(value) < 8+n*size {
+		return nil, errors.New("mxf: invalid primer pack")
+	}
+	primer := make(map[uint16]UL, n)
+	for i := 0; i < n; i++ {
+		e := value[8+i*size:]
+		var ul UL
+		copy(ul[:], e[2:18])
+		primer[binary.BigEndian.Uint16(e)] = ul
+	}
+	return primer, nil
+}
+
+// uuidString formats 16 bytes as a canonical UUID.
+func uuidString(b []byte) string {
+	if len(b) != 16 {
+		return ""
+	}
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// utf16String decodes a UTF-16BE string, dropping any trailing NUL characters.
+func utf16String(b []byte) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		u = append(u, binary.BigEndian.Uint16(b[i:]))
+	}
+	for len(u) > 0 && u[len(u)-1] == 0 {
+		u = u[:len(u)-1]
+	}
+	return string(utf16.Decode(u))
+}
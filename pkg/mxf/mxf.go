@@ -0,0 +1,215 @@
+// Package mxf reads SMPTE ST 429-5 D-Cinema timed text track files, recovering the
+// Subtitle XML Document and its ancillary font and image resources.
+//
+/* Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see <http://www.gnu.org/licenses/>.*/
+package mxf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// TrackFile is a parsed timed text track file.
+type TrackFile struct {
+	// AssetUUID is the material number of the top-level source package UMID.
+	AssetUUID  string
+	Descriptor TimedTextDescriptor
+	Resources  []*AncillaryResource
+	// XML is the Subtitle XML Document carried as the track file essence.
+	XML []byte
+	// Encrypted signals that the essence is encrypted and no key was supplied.
+	Encrypted bool
+}
+
+// TimedTextDescriptor holds the essence descriptor fields of a timed text track file.
+type TimedTextDescriptor struct {
+	ResourceID        string
+	NamespaceURI      string
+	UCSEncoding       string
+	ContainerDuration int64
+	// EditRate is the rational sample rate as "numerator denominator", e.g. "24 1".
+	EditRate string
+}
+
+// AncillaryResource is a font or image resource carried in a generic stream partition.
+type AncillaryResource struct {
+	ID       string
+	MIMEType string
+	StreamID uint32
+	Data     []byte
+}
+
+// Open reads the timed text track file filename, see Read.
+func Open(filename string, key []byte) (*TrackFile, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t, err := Read(f, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return t, nil
+}
+
+// Read parses a timed text track file. Encrypted essence is decrypted with the 16-byte AES key
+// when one is supplied; otherwise the descriptor is reported and TrackFile.Encrypted is set.
+func Read(r io.Reader, key []byte) (*TrackFile, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if key != nil && len(key) != aesKeySize {
+		return nil, fmt.Errorf("mxf: key must be %d bytes, got %d", aesKeySize, len(key))
+	}
+	var (
+		t       = &TrackFile{}
+		primer  map[uint16]UL
+		bodySID uint32
+		header  bool
+		seen    = make(map[string]bool)
+		streams = make(map[uint32][]byte)
+	)
+	for p := 0; p < len(data); {
+		k, n, err := readKLV(data[p:])
+		if err != nil {
+			return nil, err
+		}
+		if p == 0 && !k.key.isPartition() {
+			return nil, errors.New("mxf: not an MXF file")
+		}
+		p += n
+		switch {
+		case k.key.isPartition():
+			if len(k.value) < partitionBodySID+4 {
+				return nil, errors.New("mxf: truncated partition pack")
+			}
+			header = k.key[13] == partitionHeader
+			bodySID = binary.BigEndian.Uint32(k.value[partitionBodySID:])
+		case !header:
+		case k.key.equal(primerPack):
+			if primer, err = readPrimer(k.value); err != nil {
+				return nil, err
+			}
+		case k.key.isSet(sourcePackage):
+			s, err := readLocalSet(k.value, primer)
+			if err != nil {
+				return nil, err
+			}
+			if umid := s.static[tagPackageUID]; len(umid) == 32 {
+				t.AssetUUID = uuidString(umid[16:])
+			}
+		case k.key.isSet(timedTextDescriptor):
+			s, err := readLocalSet(k.value, primer)
+			if err != nil {
+				return nil, err
+			}
+			t.Descriptor = TimedTextDescriptor{
+				ResourceID:   uuidString(s.get(ulResourceID)),
+				NamespaceURI: utf16String(s.get(ulNamespaceURI)),
+				UCSEncoding:  utf16String(s.get(ulUCSEncoding)),
+			}
+			if v := s.static[tagContainerDuration]; len(v) == 8 {
+				t.Descriptor.ContainerDuration = int64(binary.BigEndian.Uint64(v))
+			}
+			if v := s.static[tagSampleRate]; len(v) == 8 {
+				t.Descriptor.EditRate = fmt.Sprintf("%d %d", int32(binary.BigEndian.Uint32(v)), int32(binary.BigEndian.Uint32(v[4:])))
+			}
+		case k.key.isSet(timedTextResourceSubDsc):
+			s, err := readLocalSet(k.value, primer)
+			if err != nil {
+				return nil, err
+			}
+			res := &AncillaryResource{
+				ID:       uuidString(s.get(ulAncillaryResourceID)),
+				MIMEType: utf16String(s.get(ulMIMEMediaType)),
+			}
+			if v := s.get(ulEssenceStreamID); len(v) == 4 {
+				res.StreamID = binary.BigEndian.Uint32(v)
+			}
+			if !seen[res.ID] {
+				seen[res.ID] = true
+				t.Resources = append(t.Resources, res)
+			}
+		}
+
+		value, inner := k.value, k.key
+		if k.key.equal(encryptedTriplet) {
+			if key == nil {
+				t.Encrypted = true
+				continue
+			}
+			if inner, value, err = decryptTriplet(k.value, key); err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case inner.equal(timedTextEssence):
+			t.XML = value
+		case inner.equal(genericStreamData):
+			streams[bodySID] = append(streams[bodySID], value...)
+		}
+	}
+	if t.Descriptor.ResourceID == "" {
+		return nil, errors.New("mxf: no timed text descriptor found")
+	}
+	if t.XML == nil && !t.Encrypted {
+		return nil, errors.New("mxf: no timed text essence found")
+	}
+	for _, res := range t.Resources {
+		res.Data = streams[res.StreamID]
+		if res.Data == nil && !t.Encrypted {
+			return nil, fmt.Errorf("mxf: no generic stream found for resource %s", res.ID)
+		}
+	}
+	return t, nil
+}
+
+// Extract writes the XML document as <ResourceID>.xml and every ancillary resource under its UUID
+// to the directory dir, returning the written file names.
+func (t *TrackFile) Extract(dir string) ([]string, error) {
+	if t.Encrypted {
+		return nil, errors.New("mxf: track file is encrypted, a key is required")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	name := filepath.Join(dir, t.Descriptor.ResourceID+".xml")
+	if err := ioutil.WriteFile(name, t.XML, 0644); err != nil {
+		return nil, err
+	}
+	files := []string{name}
+	for _, res := range t.Resources {
+		name := filepath.Join(dir, res.ID)
+		if err := ioutil.WriteFile(name, res.Data, 0644); err != nil {
+			return files, err
+		}
+		files = append(files, name)
+	}
+	return files, nil
+}
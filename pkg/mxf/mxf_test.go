@@ -0,0 +1,258 @@
+package mxf
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var testKey = []byte("0123456789abcdef")
+
+// testTrackFile returns a track file with a single font resource.
+func testTrackFile() *TrackFile {
+	return &TrackFile{
+		AssetUUID: "6a1c1b3e-1c36-4a36-9b1e-2d3c4b5a6978",
+		Descriptor: TimedTextDescriptor{
+			ResourceID:        "0d0b1d8a-7e8f-4a0b-8c5d-6e7f8a9b0c1d",
+			NamespaceURI:      "http://www.smpte-ra.org/schemas/428-7/2014/DCST",
+			UCSEncoding:       "UTF-8",
+			ContainerDuration: 240,
+			EditRate:          "24 1",
+		},
+		Resources: []*AncillaryResource{{
+			ID:       "232c45d8-fde8-4e5e-86b9-86e96354daf3",
+			MIMEType: "application/x-font-opentype",
+			Data:     bytes.Repeat([]byte("font"), 100),
+		}},
+		XML: []byte(`<?xml version="1.0" encoding="UTF-8"?><SubtitleReel/>`),
+	}
+}
+
+// testBER returns v as a 4-byte BER length.
+func testBER(v int) []byte {
+	return []byte{0x83, byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+// testTriplet returns the value of an ST 429-6 encrypted triplet holding src with the given
+// plaintext offset, as written by an encrypting wrapper.
+func testTriplet(src UL, value []byte, offset uint64, key []byte) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	iv := bytes.Repeat([]byte{0x5a}, aes.BlockSize)
+	check := make([]byte, aes.BlockSize)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(check, []byte(checkValue))
+	padded := append([]byte(nil), value...)
+	padded = append(padded, make([]byte, aes.BlockSize-(len(value)-int(offset))%aes.BlockSize)...)
+	cipher.NewCBCEncrypter(block, check).CryptBlocks(padded[offset:], padded[offset:])
+	esv := append(append(append([]byte(nil), iv...), check...), padded...)
+
+	var off, length [8]byte
+	binary.BigEndian.PutUint64(off[:], offset)
+	binary.BigEndian.PutUint64(length[:], uint64(len(value)))
+	var out []byte
+	for _, f := range [][]byte{make([]byte, 16), off[:], src[:], length[:], esv} {
+		out = append(append(out, testBER(len(f))...), f...)
+	}
+	return out
+}
+
+// encryptEssence replaces the essence of an unencrypted track file with an encrypted triplet.
+func encryptEssence(t testing.TB, data []byte, triplet []byte) []byte {
+	i := bytes.Index(data, timedTextEssence[:])
+	if i < 0 {
+		t.Fatal("no essence found")
+	}
+	_, n, err := readKLV(data[i:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := append([]byte(nil), data[:i]...)
+	out = appendKLV(out, encryptedTriplet, triplet)
+	return append(out, data[i+n:]...)
+}
+
+func TestWriteRead(t *testing.T) {
+	want := testTrackFile()
+	var b bytes.Buffer
+	if err := Write(&b, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(bytes.NewReader(b.Bytes()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("track file changed in round trip:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestPrimerResolvesEveryItem(t *testing.T) {
+	var b bytes.Buffer
+	if err := Write(&b, testTrackFile()); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		primer     map[uint16]UL
+		partitions int
+		sets       int
+	)
+	for data := b.Bytes(); len(data) > 0; {
+		k, n, err := readKLV(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+		switch {
+		case k.key.isPartition():
+			partitions++
+		case partitions != 1:
+			// only the header partition holds header metadata.
+		case k.key.equal(primerPack):
+			if primer, err = readPrimer(k.value); err != nil {
+				t.Fatal(err)
+			}
+			if n := int(binary.BigEndian.Uint32(k.value)); n != len(primer) {
+				t.Errorf("primer lists %d entries for %d tags", n, len(primer))
+			}
+		case bytes.Equal(k.key[:6], prefaceSet[:6]):
+			sets++
+			for p := 0; p < len(k.value); {
+				tag := binary.BigEndian.Uint16(k.value[p:])
+				if _, ok := primer[tag]; !ok {
+					t.Errorf("set %s: local tag %04x is not in the primer", k.key, tag)
+				}
+				p += 4 + int(binary.BigEndian.Uint16(k.value[p+2:]))
+			}
+		}
+	}
+	if sets == 0 {
+		t.Fatal("no header metadata sets")
+	}
+	for _, s := range staticTags {
+		if primer[s.tag] != s.ul {
+			t.Errorf("primer maps %04x to %s, want %s", s.tag, primer[s.tag], s.ul)
+		}
+	}
+	for _, ul := range []UL{ulResourceID, ulUCSEncoding, ulNamespaceURI, ulAncillaryResourceID, ulMIMEMediaType, ulEssenceStreamID, ulSubDescriptors} {
+		found := false
+		for _, v := range primer {
+			found = found || v == ul
+		}
+		if !found {
+			t.Errorf("primer lacks %s", ul)
+		}
+	}
+}
+
+func TestReadEncrypted(t *testing.T) {
+	tf := testTrackFile()
+	var b bytes.Buffer
+	if err := Write(&b, tf); err != nil {
+		t.Fatal(err)
+	}
+	for _, offset := range []uint64{0, 16} {
+		data := encryptEssence(t, b.Bytes(), testTriplet(timedTextEssence, tf.XML, offset, testKey))
+
+		got, err := Read(bytes.NewReader(data), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Encrypted || got.XML != nil || got.Descriptor != tf.Descriptor {
+			t.Errorf("offset %d: without a key got Encrypted %v, XML %q, descriptor %+v", offset, got.Encrypted, got.XML, got.Descriptor)
+		}
+		if got, err = Read(bytes.NewReader(data), testKey); err != nil {
+			t.Fatal(err)
+		}
+		if got.Encrypted || !bytes.Equal(got.XML, tf.XML) {
+			t.Errorf("offset %d: got XML %q, want %q", offset, got.XML, tf.XML)
+		}
+		if _, err := Read(bytes.NewReader(data), []byte("fedcba9876543210")); err == nil {
+			t.Errorf("offset %d: Read accepted the wrong key", offset)
+		}
+		if _, err := Read(bytes.NewReader(data), testKey[:8]); err == nil {
+			t.Errorf("offset %d: Read accepted a short key", offset)
+		}
+	}
+}
+
+// testTrackFiles returns a clear and an encrypted track file, and encrypted track files with
+// out of range triplet offsets and lengths that Read must reject.
+func testTrackFiles(t testing.TB) (plain, encrypted []byte, malformed map[string][]byte) {
+	tf := testTrackFile()
+	var b bytes.Buffer
+	if err := Write(&b, tf); err != nil {
+		t.Fatal(err)
+	}
+	plain = b.Bytes()
+	encrypted = encryptEssence(t, plain, testTriplet(timedTextEssence, tf.XML, 0, testKey))
+	malformed = map[string][]byte{
+		"empty":           nil,
+		"not an MXF file": []byte("not an MXF file at all"),
+	}
+	for _, offset := range []uint64{1 << 63, 1<<64 - 1, uint64(len(tf.XML)) + 64} {
+		triplet := testTriplet(timedTextEssence, tf.XML, 0, testKey)
+		// the plaintext offset is the second field, after a 16-byte context ID.
+		binary.BigEndian.PutUint64(triplet[4+16+4:], offset)
+		malformed[fmt.Sprintf("plaintext offset %d", offset)] = encryptEssence(t, plain, triplet)
+	}
+	triplet := testTriplet(timedTextEssence, tf.XML, 0, testKey)
+	// the source length is the fourth field.
+	binary.BigEndian.PutUint64(triplet[4+16+4+8+4+16+4:], 1<<63)
+	malformed["source length"] = encryptEssence(t, plain, triplet)
+	return plain, encrypted, malformed
+}
+
+func TestReadMalformed(t *testing.T) {
+	_, _, malformed := testTrackFiles(t)
+	for name, in := range malformed {
+		if _, err := Read(bytes.NewReader(in), testKey); err == nil {
+			t.Errorf("%s: Read accepted a malformed track file", name)
+		}
+	}
+}
+
+func FuzzRead(f *testing.F) {
+	plain, encrypted, malformed := testTrackFiles(f)
+	for _, data := range [][]byte{plain, encrypted} {
+		f.Add(data)
+		for n := 1; n < len(data); n += 61 {
+			f.Add(data[:n])
+		}
+	}
+	for _, in := range malformed {
+		f.Add(in)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Read(bytes.NewReader(data), testKey)
+		Read(bytes.NewReader(data), nil)
+	})
+}
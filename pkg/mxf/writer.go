@@ -0,0 +1,612 @@
+package mxf
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Universal labels only needed when writing a track file.
+var (
+	genericStreamPartition = UL{0x06, 0x0e, 0x2b, 0x34, 0x02, 0x05, 0x01, 0x01, 0x0d, 0x01, 0x02, 0x01, 0x01, 0x03, 0x11, 0x00}
+	randomIndexPack        = UL{0x06, 0x0e, 0x2b, 0x34, 0x02, 0x05, 0x01, 0x01, 0x0d, 0x01, 0x02, 0x01, 0x01, 0x11, 0x01, 0x00}
+	indexTableSegment      = UL{0x06, 0x0e, 0x2b, 0x34, 0x02, 0x53, 0x01, 0x01, 0x0d, 0x01, 0x02, 0x01, 0x01, 0x10, 0x01, 0x00}
+
+	prefaceSet              = setKey(0x2f)
+	identificationSet       = setKey(0x30)
+	contentStorageSet       = setKey(0x18)
+	essenceContainerDataSet = setKey(0x23)
+	materialPackageSet      = setKey(0x36)
+	trackSet                = setKey(0x3b)
+	sequenceSet             = setKey(0x0f)
+	sourceClipSet           = setKey(0x11)
+	timecodeComponentSet    = setKey(0x14)
+
+	opAtom             = UL{0x06, 0x0e, 0x2b, 0x34, 0x04, 0x01, 0x01, 0x02, 0x0d, 0x01, 0x02, 0x01, 0x10, 0x00, 0x00, 0x00}
+	timedTextContainer = UL{0x06, 0x0e, 0x2b, 0x34, 0x04, 0x01, 0x01, 0x0a, 0x0d, 0x01, 0x03, 0x01, 0x02, 0x13, 0x01, 0x01}
+	timecodeDataDef    = UL{0x06, 0x0e, 0x2b, 0x34, 0x04, 0x01, 0x01, 0x01, 0x01, 0x03, 0x02, 0x01, 0x01, 0x00, 0x00, 0x00}
+	dataDataDef        = UL{0x06, 0x0e, 0x2b, 0x34, 0x04, 0x01, 0x01, 0x01, 0x01, 0x03, 0x02, 0x03, 0x00, 0x00, 0x00, 0x00}
+	ulSubDescriptors   = UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x09, 0x06, 0x01, 0x01, 0x04, 0x06, 0x10, 0x00, 0x00}
+
+	umidPrefix = []byte{0x06, 0x0a, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x05, 0x01, 0x01, 0x0f, 0x20, 0x13, 0x00, 0x00, 0x00}
+)
+
+// static local tags used when writing structural metadata.
+const (
+	tagInstanceUID          = 0x3c0a
+	tagLastModifiedDate     = 0x3b02
+	tagContentStorage       = 0x3b03
+	tagVersion              = 0x3b05
+	tagIdentifications      = 0x3b06
+	tagOperationalPattern   = 0x3b09
+	tagEssenceContainers    = 0x3b0a
+	tagDMSchemes            = 0x3b0b
+	tagCompanyName          = 0x3c01
+	tagProductName          = 0x3c02
+	tagVersionString        = 0x3c04
+	tagProductUID           = 0x3c05
+	tagModificationDate     = 0x3c06
+	tagThisGenerationUID    = 0x3c09
+	tagPackages             = 0x1901
+	tagEssenceContainerData = 0x1902
+	tagLinkedPackageUID     = 0x2701
+	tagIndexSID             = 0x3f06
+	tagBodySID              = 0x3f07
+	tagPackageName          = 0x4402
+	tagTracks               = 0x4403
+	tagPackageModifiedDate  = 0x4404
+	tagPackageCreationDate  = 0x4405
+	tagDescriptor           = 0x4701
+	tagTrackID              = 0x4801
+	tagSequence             = 0x4803
+	tagTrackNumber          = 0x4804
+	tagEditRate             = 0x4b01
+	tagOrigin               = 0x4b02
+	tagDataDefinition       = 0x0201
+	tagDuration             = 0x0202
+	tagComponents           = 0x1001
+	tagSourcePackageID      = 0x1101
+	tagSourceTrackID        = 0x1102
+	tagStartPosition        = 0x1201
+	tagStartTimecode        = 0x1501
+	tagRoundedTimecodeBase  = 0x1502
+	tagDropFrame            = 0x1503
+	tagLinkedTrackID        = 0x3006
+	tagEssenceContainer     = 0x3004
+	tagIndexEditRate        = 0x3f0b
+	tagIndexStartPosition   = 0x3f0c
+	tagIndexDuration        = 0x3f0d
+	tagEditUnitByteCount    = 0x3f05
+)
+
+// staticTags are the ULs of the static local tags used in the header metadata, all of which
+// are listed in the primer pack.
+var staticTags = []struct {
+	tag uint16
+	ul  UL
+}{
+	{tagInstanceUID, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x15, 0x02, 0x00, 0x00, 0x00, 0x00}},
+	{tagLastModifiedDate, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x07, 0x02, 0x01, 0x10, 0x02, 0x04, 0x00, 0x00}},
+	{tagContentStorage, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x06, 0x01, 0x01, 0x04, 0x02, 0x01, 0x00, 0x00}},
+	{tagVersion, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x03, 0x01, 0x02, 0x01, 0x05, 0x00, 0x00, 0x00}},
+	{tagIdentifications, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x06, 0x01, 0x01, 0x04, 0x06, 0x04, 0x00, 0x00}},
+	{tagOperationalPattern, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x05, 0x01, 0x02, 0x02, 0x03, 0x00, 0x00, 0x00, 0x00}},
+	{tagEssenceContainers, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x05, 0x01, 0x02, 0x02, 0x10, 0x02, 0x01, 0x00, 0x00}},
+	{tagDMSchemes, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x05, 0x01, 0x02, 0x02, 0x10, 0x02, 0x02, 0x00, 0x00}},
+	{tagCompanyName, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x05, 0x20, 0x07, 0x01, 0x02, 0x01, 0x00, 0x00}},
+	{tagProductName, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x05, 0x20, 0x07, 0x01, 0x03, 0x01, 0x00, 0x00}},
+	{tagVersionString, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x05, 0x20, 0x07, 0x01, 0x05, 0x01, 0x00, 0x00}},
+	{tagProductUID, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x05, 0x20, 0x07, 0x01, 0x07, 0x00, 0x00, 0x00}},
+	{tagModificationDate, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x07, 0x02, 0x01, 0x10, 0x02, 0x03, 0x00, 0x00}},
+	{tagThisGenerationUID, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x05, 0x20, 0x07, 0x01, 0x01, 0x00, 0x00, 0x00}},
+	{tagPackages, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x06, 0x01, 0x01, 0x04, 0x05, 0x01, 0x00, 0x00}},
+	{tagEssenceContainerData, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x06, 0x01, 0x01, 0x04, 0x05, 0x02, 0x00, 0x00}},
+	{tagLinkedPackageUID, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x06, 0x01, 0x01, 0x06, 0x01, 0x00, 0x00, 0x00}},
+	{tagIndexSID, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x04, 0x01, 0x03, 0x04, 0x05, 0x00, 0x00, 0x00, 0x00}},
+	{tagBodySID, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x04, 0x01, 0x03, 0x04, 0x04, 0x00, 0x00, 0x00, 0x00}},
+	{tagPackageUID, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x15, 0x10, 0x00, 0x00, 0x00, 0x00}},
+	{tagPackageName, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x01, 0x01, 0x03, 0x03, 0x02, 0x01, 0x00, 0x00, 0x00}},
+	{tagTracks, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x06, 0x01, 0x01, 0x04, 0x06, 0x05, 0x00, 0x00}},
+	{tagPackageModifiedDate, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x07, 0x02, 0x01, 0x10, 0x02, 0x05, 0x00, 0x00}},
+	{tagPackageCreationDate, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x07, 0x02, 0x01, 0x10, 0x01, 0x03, 0x00, 0x00}},
+	{tagDescriptor, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x06, 0x01, 0x01, 0x04, 0x02, 0x03, 0x00, 0x00}},
+	{tagTrackID, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x01, 0x07, 0x01, 0x01, 0x00, 0x00, 0x00, 0x00}},
+	{tagSequence, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x06, 0x01, 0x01, 0x04, 0x02, 0x04, 0x00, 0x00}},
+	{tagTrackNumber, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x01, 0x04, 0x01, 0x03, 0x00, 0x00, 0x00, 0x00}},
+	{tagEditRate, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x05, 0x30, 0x04, 0x05, 0x00, 0x00, 0x00, 0x00}},
+	{tagOrigin, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x07, 0x02, 0x01, 0x03, 0x01, 0x03, 0x00, 0x00}},
+	{tagDataDefinition, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x04, 0x07, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00}},
+	{tagDuration, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x07, 0x02, 0x02, 0x01, 0x01, 0x03, 0x00, 0x00}},
+	{tagComponents, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x06, 0x01, 0x01, 0x04, 0x06, 0x09, 0x00, 0x00}},
+	{tagSourcePackageID, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x06, 0x01, 0x01, 0x03, 0x01, 0x00, 0x00, 0x00}},
+	{tagSourceTrackID, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x06, 0x01, 0x01, 0x03, 0x02, 0x00, 0x00, 0x00}},
+	{tagStartPosition, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x07, 0x02, 0x01, 0x03, 0x01, 0x04, 0x00, 0x00}},
+	{tagStartTimecode, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x07, 0x02, 0x01, 0x03, 0x01, 0x05, 0x00, 0x00}},
+	{tagRoundedTimecodeBase, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x01, 0x04, 0x04, 0x01, 0x01, 0x02, 0x06, 0x00, 0x00}},
+	{tagDropFrame, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x01, 0x04, 0x04, 0x01, 0x01, 0x05, 0x00, 0x00, 0x00}},
+	{tagLinkedTrackID, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x05, 0x06, 0x01, 0x01, 0x03, 0x05, 0x00, 0x00, 0x00}},
+	{tagSampleRate, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x01, 0x04, 0x06, 0x01, 0x01, 0x00, 0x00, 0x00, 0x00}},
+	{tagContainerDuration, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x01, 0x04, 0x06, 0x01, 0x02, 0x00, 0x00, 0x00, 0x00}},
+	{tagEssenceContainer, UL{0x06, 0x0e, 0x2b, 0x34, 0x01, 0x01, 0x01, 0x02, 0x06, 0x01, 0x01, 0x04, 0x01, 0x02, 0x00, 0x00}},
+}
+
+const (
+	productName = "empty-tt"
+	essenceSID  = 1
+	indexSID    = 129
+	// dataTrackNumber is the track number of the timed text essence element, bytes 12 to 15 of its key.
+	dataTrackNumber = 0x17010b01
+)
+
+// productUID identifies empty-tt as the writing application.
+var productUID = uuid.NewV5(uuid.NamespaceURL, "https://github.com/jack-watts/empty-tt").Bytes()
+
+// setKey returns the key of the structural metadata set with the given item designator.
+func setKey(b byte) UL {
+	return UL{0x06, 0x0e, 0x2b, 0x34, 0x02, 0x53, 0x01, 0x01, 0x0d, 0x01, 0x01, 0x01, 0x01, 0x01, b, 0x00}
+}
+
+// Create writes a timed text track file to filename, see Write.
+func Create(filename string, t *TrackFile) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := Write(f, t); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Write writes an unencrypted ST 429-5 timed text track file holding the XML document of t as its
+// essence and every ancillary resource in its own generic stream partition. Resources without a
+// StreamID are numbered from 2 in list order. The Encrypted flag is not supported.
+func Write(w io.Writer, t *TrackFile) error {
+	if t.Encrypted {
+		return errors.New("mxf: writing encrypted track files is not supported")
+	}
+	asset, err := uuidBytes(t.AssetUUID)
+	if err != nil {
+		return fmt.Errorf("mxf: invalid AssetUUID: %w", err)
+	}
+	rate, err := parseRational(t.Descriptor.EditRate)
+	if err != nil {
+		return err
+	}
+	streams := make(map[uint32]bool)
+	for i, res := range t.Resources {
+		if res.StreamID == 0 {
+			res.StreamID = uint32(i + 2)
+		}
+		if res.StreamID == essenceSID || res.StreamID == indexSID || streams[res.StreamID] {
+			return fmt.Errorf("mxf: invalid stream ID %d for resource %s", res.StreamID, res.ID)
+		}
+		streams[res.StreamID] = true
+	}
+
+	metadata, err := headerMetadata(t, asset, rate)
+	if err != nil {
+		return err
+	}
+	essence := appendKLV(nil, timedTextEssence, t.XML)
+	index := indexSegment(rate)
+
+	type partition struct {
+		key       UL
+		payload   []byte
+		headerLen int
+		indexLen  int
+		indexSID  uint32
+		bodySID   uint32
+	}
+	parts := []*partition{
+		{key: partitionKey(partitionHeader), payload: metadata, headerLen: len(metadata)},
+		{key: partitionKey(partitionBody), payload: essence, bodySID: essenceSID},
+	}
+	for _, res := range t.Resources {
+		parts = append(parts, &partition{
+			key:     genericStreamPartition,
+			payload: appendKLV(nil, genericStreamData, res.Data),
+			bodySID: res.StreamID,
+		})
+	}
+	parts = append(parts, &partition{key: partitionKey(partitionFooter), payload: index, indexLen: len(index), indexSID: indexSID})
+
+	// partition packs are of a fixed size, so every offset is known before they are encoded.
+	packSize := len(appendKLV(nil, UL{}, make([]byte, partitionPackSize)))
+	offsets := make([]uint64, len(parts))
+	var offset uint64
+	for i, p := range parts {
+		offsets[i] = offset
+		offset += uint64(packSize + len(p.payload))
+	}
+	footer := offsets[len(offsets)-1]
+
+	var out bytes.Buffer
+	rip := make([]byte, 0, 12*len(parts)+4)
+	for i, p := range parts {
+		v := make([]byte, partitionPackSize)
+		binary.BigEndian.PutUint16(v[0:], 1)
+		binary.BigEndian.PutUint16(v[2:], 3)
+		binary.BigEndian.PutUint32(v[4:], 1)
+		binary.BigEndian.PutUint64(v[8:], offsets[i])
+		if i > 0 {
+			binary.BigEndian.PutUint64(v[16:], offsets[i-1])
+		}
+		binary.BigEndian.PutUint64(v[24:], footer)
+		binary.BigEndian.PutUint64(v[32:], uint64(p.headerLen))
+		binary.BigEndian.PutUint64(v[40:], uint64(p.indexLen))
+		binary.BigEndian.PutUint32(v[48:], p.indexSID)
+		binary.BigEndian.PutUint32(v[partitionBodySID:], p.bodySID)
+		copy(v[64:], opAtom[:])
+		binary.BigEndian.PutUint32(v[80:], 1)
+		binary.BigEndian.PutUint32(v[84:], 16)
+		copy(v[88:], timedTextContainer[:])
+		out.Write(appendKLV(nil, p.key, v))
+		out.Write(p.payload)
+		rip = appendUint32(rip, p.bodySID)
+		rip = appendUint64(rip, offsets[i])
+	}
+	ripKLV := appendKLV(nil, randomIndexPack, append(rip, 0, 0, 0, 0))
+	binary.BigEndian.PutUint32(ripKLV[len(ripKLV)-4:], uint32(len(ripKLV)))
+	out.Write(ripKLV)
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+// partitionPackSize is the size of a partition pack value with a single essence container label.
+const partitionPackSize = 88 + 16
+
+// partitionKey returns the key of a closed and complete partition of the given kind.
+func partitionKey(kind byte) UL {
+	k := partitionPack
+	k[13], k[14] = kind, 0x04
+	return k
+}
+
+// headerMetadata returns the primer pack and structural metadata sets of a track file.
+func headerMetadata(t *TrackFile, asset []byte, rate [2]int32) ([]byte, error) {
+	resourceID, err := uuidBytes(t.Descriptor.ResourceID)
+	if err != nil {
+		return nil, fmt.Errorf("mxf: invalid ResourceID: %w", err)
+	}
+	now := timestamp(time.Now())
+	duration := t.Descriptor.ContainerDuration
+	sourceUMID := append(append([]byte(nil), umidPrefix...), asset...)
+	materialUMID := append(append([]byte(nil), umidPrefix...), newUUID()...)
+	editRate := appendRational(nil, rate)
+	primer := newPrimer()
+
+	var (
+		preface, identification, storage, ecd = newUUID(), newUUID(), newUUID(), newUUID()
+		material, source, descriptor          = newUUID(), newUUID(), newUUID()
+		sets                                  [][]byte
+	)
+	add := func(key UL, s *setWriter) {
+		sets = append(sets, appendKLV(nil, key, s.Bytes()))
+	}
+	// track returns the InstanceUID of a track holding a single component.
+	track := func(id, number uint32, dataDef UL, component []byte, componentKey UL) []byte {
+		trackUID, sequenceUID := newUUID(), newUUID()
+		s := newSet(trackUID)
+		s.item(tagTrackID, appendUint32(nil, id))
+		s.item(tagTrackNumber, appendUint32(nil, number))
+		s.item(tagEditRate, editRate)
+		s.item(tagOrigin, appendUint64(nil, 0))
+		s.item(tagSequence, sequenceUID)
+		add(trackSet, s)
+		s = newSet(sequenceUID)
+		s.item(tagDataDefinition, dataDef[:])
+		s.item(tagDuration, appendUint64(nil, uint64(duration)))
+		s.item(tagComponents, batch(component[4:20]))
+		add(sequenceSet, s)
+		c := &setWriter{}
+		c.Write(component)
+		add(componentKey, c)
+		return trackUID
+	}
+	// clip returns a SourceClip set body referencing the given package track.
+	clip := func(umid []byte, trackID uint32) []byte {
+		s := newSet(newUUID())
+		s.item(tagDataDefinition, dataDataDef[:])
+		s.item(tagDuration, appendUint64(nil, uint64(duration)))
+		s.item(tagStartPosition, appendUint64(nil, 0))
+		s.item(tagSourcePackageID, umid)
+		s.item(tagSourceTrackID, appendUint32(nil, trackID))
+		return s.Bytes()
+	}
+	timecode := func() []byte {
+		s := newSet(newUUID())
+		s.item(tagDataDefinition, timecodeDataDef[:])
+		s.item(tagDuration, appendUint64(nil, uint64(duration)))
+		s.item(tagRoundedTimecodeBase, appendUint16(nil, uint16((int64(rate[0])+int64(rate[1])-1)/int64(rate[1]))))
+		s.item(tagStartTimecode, appendUint64(nil, 0))
+		s.item(tagDropFrame, []byte{0})
+		return s.Bytes()
+	}
+
+	s := newSet(preface)
+	s.item(tagLastModifiedDate, now)
+	s.item(tagVersion, appendUint16(nil, 0x0103))
+	s.item(tagContentStorage, storage)
+	s.item(tagIdentifications, batch(identification))
+	s.item(tagOperationalPattern, opAtom[:])
+	s.item(tagEssenceContainers, batch(timedTextContainer[:]))
+	s.item(tagDMSchemes, batch())
+	add(prefaceSet, s)
+
+	s = newSet(identification)
+	s.item(tagThisGenerationUID, newUUID())
+	s.item(tagCompanyName, utf16Bytes(productName))
+	s.item(tagProductName, utf16Bytes(productName))
+	s.item(tagVersionString, utf16Bytes(productName))
+	s.item(tagProductUID, productUID)
+	s.item(tagModificationDate, now)
+	add(identificationSet, s)
+
+	s = newSet(storage)
+	s.item(tagPackages, batch(material, source))
+	s.item(tagEssenceContainerData, batch(ecd))
+	add(contentStorageSet, s)
+
+	s = newSet(ecd)
+	s.item(tagLinkedPackageUID, sourceUMID)
+	s.item(tagIndexSID, appendUint32(nil, indexSID))
+	s.item(tagBodySID, appendUint32(nil, essenceSID))
+	add(essenceContainerDataSet, s)
+
+	mpTracks := [][]byte{
+		track(1, 0, timecodeDataDef, timecode(), timecodeComponentSet),
+		track(2, 0, dataDataDef, clip(sourceUMID, 2), sourceClipSet),
+	}
+	s = newSet(material)
+	s.item(tagPackageUID, materialUMID)
+	s.item(tagPackageName, utf16Bytes(productName))
+	s.item(tagTracks, batch(mpTracks...))
+	s.item(tagPackageModifiedDate, now)
+	s.item(tagPackageCreationDate, now)
+	add(materialPackageSet, s)
+
+	spTracks := [][]byte{
+		track(1, 0, timecodeDataDef, timecode(), timecodeComponentSet),
+		track(2, dataTrackNumber, dataDataDef, clip(make([]byte, 32), 0), sourceClipSet),
+	}
+	s = newSet(source)
+	s.item(tagPackageUID, sourceUMID)
+	s.item(tagTracks, batch(spTracks...))
+	s.item(tagPackageModifiedDate, now)
+	s.item(tagPackageCreationDate, now)
+	s.item(tagDescriptor, descriptor)
+	add(sourcePackage, s)
+
+	var subDescriptors [][]byte
+	for _, res := range t.Resources {
+		id, err := uuidBytes(res.ID)
+		if err != nil {
+			return nil, fmt.Errorf("mxf: invalid resource ID: %w", err)
+		}
+		uid := newUUID()
+		subDescriptors = append(subDescriptors, uid)
+		s := newSet(uid)
+		s.item(primer.tag(ulAncillaryResourceID), id)
+		s.item(primer.tag(ulMIMEMediaType), utf16Bytes(res.MIMEType))
+		s.item(primer.tag(ulEssenceStreamID), appendUint32(nil, res.StreamID))
+		add(timedTextResourceSubDsc, s)
+	}
+	s = newSet(descriptor)
+	s.item(tagLinkedTrackID, appendUint32(nil, 2))
+	s.item(tagSampleRate, editRate)
+	s.item(tagContainerDuration, appendUint64(nil, uint64(duration)))
+	s.item(tagEssenceContainer, timedTextContainer[:])
+	s.item(primer.tag(ulResourceID), resourceID)
+	s.item(primer.tag(ulUCSEncoding), utf16Bytes(t.Descriptor.UCSEncoding))
+	s.item(primer.tag(ulNamespaceURI), utf16Bytes(t.Descriptor.NamespaceURI))
+	if len(subDescriptors) > 0 {
+		s.item(primer.tag(ulSubDescriptors), batch(subDescriptors...))
+	}
+	add(timedTextDescriptor, s)
+
+	out := appendKLV(nil, primerPack, primer.Bytes())
+	for _, set := range sets {
+		out = append(out, set...)
+	}
+	return out, nil
+}
+
+// indexSegment returns an index table segment for the single essence element of the body.
+func indexSegment(rate [2]int32) []byte {
+	s := newSet(newUUID())
+	s.item(tagIndexEditRate, appendRational(nil, rate))
+	s.item(tagIndexStartPosition, appendUint64(nil, 0))
+	s.item(tagIndexDuration, appendUint64(nil, 1))
+	s.item(tagEditUnitByteCount, appendUint32(nil, 0))
+	s.item(tagIndexSID, appendUint32(nil, indexSID))
+	s.item(tagBodySID, appendUint32(nil, essenceSID))
+	return appendKLV(nil, indexTableSegment, s.Bytes())
+}
+
+// setWriter encodes the items of a local set.
+type setWriter struct {
+	bytes.Buffer
+}
+
+// newSet returns a local set holding its InstanceUID.
+func newSet(instanceUID []byte) *setWriter {
+	s := &setWriter{}
+	s.item(tagInstanceUID, instanceUID)
+	return s
+}
+
+// item appends a local tag, length and value.
+func (s *setWriter) item(tag uint16, v []byte) {
+	s.Write(appendUint16(nil, tag))
+	s.Write(appendUint16(nil, uint16(len(v))))
+	s.Write(v)
+}
+
+// primerWriter maps the static local tags and assigns dynamic local tags to ULs.
+type primerWriter struct {
+	next uint16
+	tags map[UL]uint16
+	uls  []UL
+}
+
+// newPrimer returns a primer holding the static tags and assigning dynamic tags downwards
+// from 0xffff.
+func newPrimer() *primerWriter {
+	p := &primerWriter{next: 0xffff, tags: make(map[UL]uint16)}
+	for _, s := range staticTags {
+		p.tags[s.ul] = s.tag
+		p.uls = append(p.uls, s.ul)
+	}
+	return p
+}
+
+// tag returns the dynamic local tag of a UL.
+func (p *primerWriter) tag(ul UL) uint16 {
+	if t, ok := p.tags[ul]; ok {
+		return t
+	}
+	t := p.next
+	p.next--
+	p.tags[ul] = t
+	p.uls = append(p.uls, ul)
+	return t
+}
+
+// Bytes returns the encoded primer pack value.
+func (p *primerWriter) Bytes() []byte {
+	b := appendUint32(nil, uint32(len(p.uls)))
+	b = appendUint32(b, 18)
+	for _, ul := range p.uls {
+		b = appendUint16(b, p.tags[ul])
+		b = append(b, ul[:]...)
+	}
+	return b
+}
+
+// appendKLV appends a key, a four byte BER length and a value.
+func appendKLV(b []byte, key UL, value []byte) []byte {
+	b = append(b, key[:]...)
+	if len(value) < 1<<24 {
+		b = append(b, 0x83, byte(len(value)>>16), byte(len(value)>>8), byte(len(value)))
+	} else {
+		b = append(b, 0x88)
+		b = appendUint64(b, uint64(len(value)))
+	}
+	return append(b, value...)
+}
+
+// batch encodes a batch of equally sized items.
+func batch(items ...[]byte) []byte {
+	size := 0
+	if len(items) > 0 {
+		size = len(items[0])
+	}
+	b := appendUint32(nil, uint32(len(items)))
+	b = appendUint32(b, uint32(size))
+	for _, i := range items {
+		b = append(b, i...)
+	}
+	return b
+}
+
+// appendUint16 appends a big-endian uint16.
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// appendUint32 appends a big-endian uint32.
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// appendUint64 appends a big-endian uint64.
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
+
+// appendRational appends a rational as two big-endian int32 values.
+func appendRational(b []byte, r [2]int32) []byte {
+	return appendUint32(appendUint32(b, uint32(r[0])), uint32(r[1]))
+}
+
+// parseRational parses an edit rate given as "numerator denominator".
+func parseRational(s string) ([2]int32, error) {
+	var r [2]int32
+	f := strings.Fields(s)
+	if len(f) != 2 {
+		return r, fmt.Errorf("mxf: invalid EditRate %q", s)
+	}
+	for i := range f {
+		v, err := strconv.ParseInt(f[i], 10, 32)
+		if err != nil || v <= 0 {
+			return r, fmt.Errorf("mxf: invalid EditRate %q", s)
+		}
+		r[i] = int32(v)
+	}
+	return r, nil
+}
+
+// timestamp encodes an MXF timestamp.
+func timestamp(t time.Time) []byte {
+	t = t.UTC()
+	b := appendUint16(nil, uint16(t.Year()))
+	return append(b, byte(t.Month()), byte(t.Day()), byte(t.Hour()), byte(t.Minute()), byte(t.Second()), byte(t.Nanosecond()/4e6))
+}
+
+// utf16Bytes encodes a string as UTF-16BE.
+func utf16Bytes(s string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = appendUint16(b, c)
+	}
+	return b
+}
+
+// newUUID returns a new random UUID.
+func newUUID() []byte {
+	return uuid.NewV4().Bytes()
+}
+
+// uuidBytes decodes a UUID with or without its urn:uuid: prefix.
+func uuidBytes(s string) ([]byte, error) {
+	u, err := uuid.FromString(strings.TrimPrefix(s, "urn:uuid:"))
+	if err != nil {
+		return nil, err
+	}
+	return u.Bytes(), nil
+}
@@ -0,0 +1,450 @@
+// Package server exposes document generation, validation and conversion as an HTTP/JSON API.
+//
+/* Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see <http://www.gnu.org/licenses/>.*/
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jack-watts/empty-tt/pkg/tt"
+)
+
+const (
+	// DefaultMaxBytes is the default request body limit.
+	DefaultMaxBytes = 8 << 20
+	// DefaultTimeout is the default time limit of a request.
+	DefaultTimeout = time.Minute
+)
+
+// Options configures the Handler.
+type Options struct {
+	// MaxBytes limits the size of request bodies. Defaults to DefaultMaxBytes.
+	MaxBytes int64
+	// Timeout limits the time spent on a request. Defaults to DefaultTimeout.
+	Timeout time.Duration
+	// MaxJobs limits the number of create jobs run at once. Defaults to the number of CPUs.
+	MaxJobs int
+	// Wrapper writes the track files of create requests. Defaults to tt.NativeWrapper.
+	Wrapper tt.Wrapper
+	// Log receives one JSON object per request. Defaults to os.Stderr.
+	Log io.Writer
+}
+
+// server holds the settings shared by all requests.
+type server struct {
+	opts Options
+	// jobs holds a token for every running create job.
+	jobs chan struct{}
+	mu   sync.Mutex
+	log  *json.Encoder
+}
+
+// Handler returns the HTTP handler of the API:
+//
+//	GET  /health    reports that the service is up.
+//	POST /create    runs a job given as a JSON batch manifest job and returns a zip of the XML
+//	                document, its resources, its track file, a result.json and a checksum manifest.
+//	POST /validate  validates the ST 428-7 document in the body and returns its findings.
+//	POST /convert   converts the ST 428-7, SRT, WebVTT, IMSC or Interop document in the body.
+//
+// Errors are returned as a JSON object with an "error" member.
+func Handler(opts Options) http.Handler {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxJobs <= 0 {
+		opts.MaxJobs = runtime.NumCPU()
+	}
+	if opts.Wrapper == nil {
+		opts.Wrapper = tt.NativeWrapper{}
+	}
+	if opts.Log == nil {
+		opts.Log = os.Stderr
+	}
+	s := &server{opts: opts, jobs: make(chan struct{}, opts.MaxJobs), log: json.NewEncoder(opts.Log)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.method(http.MethodGet, s.health))
+	mux.HandleFunc("/create", s.method(http.MethodPost, s.create))
+	mux.HandleFunc("/validate", s.method(http.MethodPost, s.validate))
+	mux.HandleFunc("/convert", s.method(http.MethodPost, s.convert))
+	timeout, _ := json.Marshal(errorResponse{Error: "request timed out"})
+	return s.logRequests(http.TimeoutHandler(mux, opts.Timeout, string(timeout)))
+}
+
+// errorResponse is the body of a failed request.
+type errorResponse struct {
+	Error    string       `json:"error"`
+	Messages []string     `json:"messages,omitempty"`
+	Findings []tt.Finding `json:"findings,omitempty"`
+}
+
+// validateResponse is the body of a validate request.
+type validateResponse struct {
+	Valid    bool         `json:"valid"`
+	Findings []tt.Finding `json:"findings"`
+}
+
+// convertResponse is the JSON body of a convert request.
+type convertResponse struct {
+	Document string       `json:"document"`
+	Findings []tt.Finding `json:"findings"`
+}
+
+// createResult is the result.json of a create request. Paths are relative to the zip.
+type createResult struct {
+	*tt.Result
+	Messages []string `json:"messages,omitempty"`
+}
+
+// logEntry is a single structured log line.
+type logEntry struct {
+	Time     string  `json:"time"`
+	Method   string  `json:"method"`
+	Path     string  `json:"path"`
+	Status   int     `json:"status"`
+	Bytes    int     `json:"bytes"`
+	Duration float64 `json:"durationMs"`
+	Remote   string  `json:"remote"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// recorder captures the status, size and error of a response.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+	// err is set by the handler, which outlives the request when it times out.
+	mu  sync.Mutex
+	err string
+}
+
+// recorderKey is the context key of the recorder of a request.
+type recorderKey struct{}
+
+// withRecorder returns the context of r carrying rec.
+func withRecorder(r *http.Request, rec *recorder) context.Context {
+	return context.WithValue(r.Context(), recorderKey{}, rec)
+}
+
+// recorderOf returns the recorder of a request, nil outside of logRequests.
+func recorderOf(r *http.Request) *recorder {
+	rec, _ := r.Context().Value(recorderKey{}).(*recorder)
+	return rec
+}
+
+// setError records the error of a response.
+func (r *recorder) setError(err string) {
+	r.mu.Lock()
+	r.err = err
+	r.mu.Unlock()
+}
+
+// error returns the recorded error of a response.
+func (r *recorder) error() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// WriteHeader records the status code.
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the number of bytes written.
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// logRequests writes a log entry for every request once it is served.
+func (s *server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &recorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(withRecorder(r, rec)))
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.log.Encode(logEntry{
+			Time:     start.UTC().Format(time.RFC3339Nano),
+			Method:   r.Method,
+			Path:     r.URL.Path,
+			Status:   rec.status,
+			Bytes:    rec.bytes,
+			Duration: float64(time.Since(start).Microseconds()) / 1000,
+			Remote:   r.RemoteAddr,
+			Error:    rec.error(),
+		})
+	})
+}
+
+// method rejects requests of any other method than m and limits the size of request bodies.
+func (s *server) method(m string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != m {
+			w.Header().Set("Allow", m)
+			s.fail(w, r, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxBytes)
+		h(w, r)
+	}
+}
+
+// health reports that the service is up.
+func (s *server) health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// create runs a job into a temporary directory and returns its files as a zip.
+func (s *server) create(w http.ResponseWriter, r *http.Request) {
+	body, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+	job, err := tt.ParseJob(body)
+	if err != nil {
+		s.fail(w, r, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	if job.Template != "" || len(job.Fonts) > 0 {
+		s.fail(w, r, http.StatusBadRequest, errorResponse{Error: "template and fonts are not permitted"})
+		return
+	}
+	// wait for a free job slot, giving up when the request times out or is cancelled.
+	select {
+	case s.jobs <- struct{}{}:
+		defer func() { <-s.jobs }()
+	case <-r.Context().Done():
+		s.fail(w, r, http.StatusServiceUnavailable, errorResponse{Error: "too many concurrent jobs"})
+		return
+	}
+	dir, err := ioutil.TempDir("", "empty-tt")
+	if err != nil {
+		s.fail(w, r, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	defer os.RemoveAll(dir)
+	var log bytes.Buffer
+	job.Output = dir
+	job.Archive = ""
+	job.Wrapper = s.opts.Wrapper
+	job.Log = &log
+	// the request context ends with the timeout, which stops the job at its next step.
+	res, err := job.RunContext(r.Context())
+	messages := lines(log.String())
+	if err != nil {
+		s.fail(w, r, http.StatusUnprocessableEntity, errorResponse{Error: err.Error(), Messages: messages})
+		return
+	}
+
+	var files []tt.ArchiveFile
+	for _, p := range res.Files() {
+		files = append(files, tt.ArchiveFile{Name: filepath.Base(p), Path: p})
+	}
+	rel := *res
+	rel.XML = filepath.Base(res.XML)
+	rel.Resources, rel.Composition, rel.Manifest = nil, nil, nil
+	for _, p := range res.Resources {
+		rel.Resources = append(rel.Resources, filepath.Base(p))
+	}
+	if res.MXF != "" {
+		rel.MXF = filepath.Base(res.MXF)
+	}
+	for _, p := range res.Composition {
+		rel.Composition = append(rel.Composition, filepath.Base(p))
+	}
+	for _, p := range res.Manifest {
+		rel.Manifest = append(rel.Manifest, filepath.Base(p))
+	}
+	result, err := json.MarshalIndent(createResult{Result: &rel, Messages: messages}, "", "  ")
+	if err != nil {
+		s.fail(w, r, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	files = append(files, tt.ArchiveFile{Name: "result.json", Data: append(result, '\n')})
+	var b bytes.Buffer
+	if err := tt.WriteArchive(&b, tt.ArchiveZip, files); err != nil {
+		s.fail(w, r, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", strings.TrimPrefix(res.DocumentID, "urn:uuid:")+".zip"))
+	w.Header().Set("Content-Length", strconv.Itoa(b.Len()))
+	w.WriteHeader(http.StatusOK)
+	w.Write(b.Bytes())
+}
+
+// validate returns the findings of tt.Validate for the document in the request body.
+func (s *server) validate(w http.ResponseWriter, r *http.Request) {
+	body, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+	var doc tt.SubtitleReel
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		s.fail(w, r, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	findings := tt.Validate(&doc)
+	if findings == nil {
+		findings = []tt.Finding{}
+	}
+	writeJSON(w, http.StatusOK, validateResponse{Valid: !tt.HasErrors(findings), Findings: findings})
+}
+
+// convert converts the document in the request body with tt.ConvertDocument. The query sets the
+// target namespace "ns", 2010 or 2014, the source "format" when it cannot be detected from the
+// content, and the "framerate", "reel", "display", "language" and "title" of imported
+// documents. The XML document is returned, or a JSON object with its findings when the request
+// accepts application/json.
+func (s *server) convert(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	namespace := tt.DCST2014
+	switch q.Get("ns") {
+	case "", "2014":
+	case "2010":
+		namespace = tt.DCST2010
+	default:
+		s.fail(w, r, http.StatusBadRequest, errorResponse{Error: "unsupported namespace version: " + q.Get("ns")})
+		return
+	}
+	opts := tt.TextImportOptions{
+		Framerate: q.Get("framerate"),
+		Language:  q.Get("language"),
+		Title:     q.Get("title"),
+	}
+	for name, v := range map[string]*int{"reel": &opts.Reel, "display": &opts.Display} {
+		if q.Get(name) == "" {
+			continue
+		}
+		n, err := strconv.Atoi(q.Get(name))
+		if err != nil || n < 0 {
+			s.fail(w, r, http.StatusBadRequest, errorResponse{Error: "invalid " + name + ": " + q.Get(name)})
+			return
+		}
+		*v = n
+	}
+	body, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+	// the format is detected from the extension of the document name first.
+	name := "document"
+	if f := q.Get("format"); f != "" {
+		name += "." + f
+	}
+	doc, findings, err := tt.ConvertDocument(name, body, namespace, opts)
+	if err != nil {
+		s.fail(w, r, http.StatusUnprocessableEntity, errorResponse{Error: err.Error(), Findings: findings})
+		return
+	}
+	var b bytes.Buffer
+	if err := tt.EncodeReel(&b, doc); err != nil {
+		s.fail(w, r, http.StatusUnprocessableEntity, errorResponse{Error: err.Error(), Findings: findings})
+		return
+	}
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		if findings == nil {
+			findings = []tt.Finding{}
+		}
+		writeJSON(w, http.StatusOK, convertResponse{Document: b.String(), Findings: findings})
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b.Bytes())
+}
+
+// readBody reads the request body and fails the request when it exceeds the size limit.
+func (s *server) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.fail(w, r, http.StatusRequestEntityTooLarge, errorResponse{Error: fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit)})
+			return nil, false
+		}
+		s.fail(w, r, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return nil, false
+	}
+	return body, true
+}
+
+// fail writes an error response and records the error for the request log.
+func (s *server) fail(w http.ResponseWriter, r *http.Request, status int, resp errorResponse) {
+	if rec := recorderOf(r); rec != nil {
+		rec.setError(resp.Error)
+	}
+	writeJSON(w, status, resp)
+}
+
+// writeJSON writes v as an indented JSON response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b.Bytes())
+}
+
+// lines returns the non-empty lines of s.
+func lines(s string) []string {
+	var l []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			l = append(l, line)
+		}
+	}
+	return l
+}
@@ -0,0 +1,295 @@
+package server
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jack-watts/empty-tt/pkg/tt"
+)
+
+// newTestServer returns a test server of the API logging to a buffer.
+func newTestServer(t *testing.T, opts Options) (*httptest.Server, *bytes.Buffer) {
+	t.Helper()
+	var log bytes.Buffer
+	opts.Log = &log
+	ts := httptest.NewServer(Handler(opts))
+	t.Cleanup(ts.Close)
+	return ts, &log
+}
+
+func TestHealth(t *testing.T) {
+	ts, log := newTestServer(t, Options{})
+	resp, err := http.Get(ts.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	// the log is written once the handler returns, Close waits for it.
+	ts.Close()
+	var entry logEntry
+	if err := json.Unmarshal(log.Bytes(), &entry); err != nil {
+		t.Fatalf("log is not JSON: %v: %s", err, log)
+	}
+	if entry.Path != "/health" || entry.Status != http.StatusOK {
+		t.Errorf("log entry = %+v", entry)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	ts, _ := newTestServer(t, Options{})
+	resp, err := http.Post(ts.URL+"/health", "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	ts, _ := newTestServer(t, Options{})
+	resp, err := http.Post(ts.URL+"/create", "application/json", strings.NewReader(`{"title": "Test", "framerate": 25, "track": true, "checksums": true, "composition": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.StatusCode, body)
+	}
+	z, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var xmlDocs, mxfs, cpls int
+	names := make(map[string]bool)
+	for _, f := range z.File {
+		names[f.Name] = true
+		switch {
+		case strings.HasSuffix(f.Name, "_r1.xml"):
+			xmlDocs++
+		case strings.HasSuffix(f.Name, "_sub.mxf"):
+			mxfs++
+		case strings.HasPrefix(f.Name, "CPL_"):
+			cpls++
+		}
+	}
+	if xmlDocs != 1 || mxfs != 1 || cpls != 1 || !names["ASSETMAP.xml"] || !names["result.json"] || !names["checksums.sha256"] {
+		t.Errorf("unexpected zip content %v", names)
+	}
+}
+
+func TestCreateRejectsInvalidJobs(t *testing.T) {
+	ts, _ := newTestServer(t, Options{})
+	for _, body := range []string{
+		`{"profile": "video"}`,
+		`{"template": "/etc/passwd"}`,
+		`not json`,
+		`{"framerate": -5}`,
+		`{"framerate": "abc"}`,
+		`{"framerate": "0"}`,
+		`{"framerate": "24/0"}`,
+		`{"reel": -1, "track": true}`,
+		`{"duration": -1, "track": true}`,
+	} {
+		resp, err := http.Post(ts.URL+"/create", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var e errorResponse
+		json.NewDecoder(resp.Body).Decode(&e)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest || e.Error == "" {
+			t.Errorf("%s: status = %d, error %q", body, resp.StatusCode, e.Error)
+		}
+	}
+}
+
+// rubyDocument is a 2014 document using Ruby, which is not permitted in a 2010 document.
+const rubyDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<SubtitleReel xmlns="http://www.smpte-ra.org/schemas/428-7/2014/DCST">
+  <Id>urn:uuid:7f9d1c2e-3b4a-4c5d-8e6f-0a1b2c3d4e5f</Id>
+  <ContentTitleText>Ruby</ContentTitleText>
+  <IssueDate>2020-11-03T11:07:39-00:00</IssueDate>
+  <ReelNumber>1</ReelNumber>
+  <Language>ja</Language>
+  <EditRate>24 1</EditRate>
+  <TimeCodeRate>24</TimeCodeRate>
+  <StartTime>00:00:00:00</StartTime>
+  <DisplayType>MainSubtitle</DisplayType>
+  <SubtitleList>
+    <Font>
+      <Subtitle SpotNumber="1" TimeIn="00:00:04:00" TimeOut="00:00:06:00">
+        <Text Valign="bottom" Vposition="10"><Ruby><Rb>漢字</Rb><Rt>かんじ</Rt></Ruby></Text>
+      </Subtitle>
+    </Font>
+  </SubtitleList>
+</SubtitleReel>
+`
+
+func TestValidate(t *testing.T) {
+	ts, _ := newTestServer(t, Options{})
+	for _, tc := range []struct {
+		doc   string
+		valid bool
+	}{
+		{rubyDocument, true},
+		{strings.Replace(rubyDocument, tt.DCST2014, tt.DCST2010, 1), false},
+	} {
+		resp, err := http.Post(ts.URL+"/validate", "application/xml", strings.NewReader(tc.doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v validateResponse
+		if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if v.Valid != tc.valid {
+			t.Errorf("valid = %v, want %v: %v", v.Valid, tc.valid, v.Findings)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	ts, _ := newTestServer(t, Options{})
+	srt := "1\n00:00:01,000 --> 00:00:02,000\nHello <i>world</i>\n"
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/convert?format=srt&framerate=25&ns=2010", strings.NewReader(srt))
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c convertResponse
+	if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	for _, want := range []string{tt.DCST2010, `TimeIn="00:00:01:00"`, `TimeOut="00:00:02:00"`, `<Font Italic="yes">world</Font>`} {
+		if !strings.Contains(c.Document, want) {
+			t.Errorf("document lacks %s:\n%s", want, c.Document)
+		}
+	}
+}
+
+func TestRequestSizeLimit(t *testing.T) {
+	ts, log := newTestServer(t, Options{MaxBytes: 16})
+	resp, err := http.Post(ts.URL+"/validate", "application/xml", strings.NewReader(strings.Repeat("x", 17)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+	ts.Close()
+	if !strings.Contains(log.String(), `"status":413`) {
+		t.Errorf("log lacks the request: %s", log)
+	}
+}
+
+func TestConvertRejectsInvalidFramerate(t *testing.T) {
+	ts, _ := newTestServer(t, Options{})
+	srt := "1\n00:00:01,000 --> 00:00:02,000\nHello\n"
+	for _, rate := range []string{"abc", "0", "24/0"} {
+		resp, err := http.Post(ts.URL+"/convert?format=srt&framerate="+rate, "text/plain", strings.NewReader(srt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Errorf("framerate %s: status = %d", rate, resp.StatusCode)
+		}
+	}
+}
+
+// blockingWrapper blocks every Wrap call until release is closed.
+type blockingWrapper struct {
+	calls   int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingWrapper) Wrap(source, output string, opts tt.WrapOptions) error {
+	atomic.AddInt32(&b.calls, 1)
+	b.started <- struct{}{}
+	<-b.release
+	return tt.NativeWrapper{}.Wrap(source, output, opts)
+}
+
+func TestCreateLimitsConcurrentJobs(t *testing.T) {
+	w := &blockingWrapper{started: make(chan struct{}, 2), release: make(chan struct{})}
+	ts, _ := newTestServer(t, Options{Timeout: 200 * time.Millisecond, MaxJobs: 1, Wrapper: w})
+	create := func(body string) int {
+		resp, err := http.Post(ts.URL+"/create", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Error(err)
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	first := make(chan int)
+	go func() { first <- create(`{"track": true}`) }()
+	<-w.started
+	// the second job waits for the running one and gives up with the timeout.
+	if status := create(`{"track": true}`); status != http.StatusServiceUnavailable {
+		t.Errorf("second job: status = %d, want %d", status, http.StatusServiceUnavailable)
+	}
+	if status := <-first; status != http.StatusServiceUnavailable {
+		t.Errorf("first job: status = %d, want %d", status, http.StatusServiceUnavailable)
+	}
+	if n := atomic.LoadInt32(&w.calls); n != 1 {
+		t.Errorf("%d jobs wrapped a track file, want 1", n)
+	}
+	// the slot of the timed out job is freed once its wrapper returns.
+	close(w.release)
+	for i := 0; ; i++ {
+		status := create(`{}`)
+		if status == http.StatusOK {
+			break
+		}
+		if i == 20 {
+			t.Fatalf("job slot not freed, status = %d", status)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
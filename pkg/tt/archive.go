@@ -0,0 +1,191 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Archive formats of a packaged Job.
+const (
+	ArchiveZip   = "zip"
+	ArchiveTarGz = "tar.gz"
+)
+
+// checksumsName is the name of the SHA-256 checksum manifest of an archive. It is written in
+// the format of sha256sum, so that it can be checked with "sha256sum -c".
+const checksumsName = "checksums.sha256"
+
+// ArchiveFile is a file written to an archive.
+type ArchiveFile struct {
+	// Name is the name of the file within the archive.
+	Name string
+	// Path is the source file. Data is used when it is empty.
+	Path string
+	Data []byte
+}
+
+// WriteArchive writes files to w as a zip or tar.gz archive followed by a checksums.sha256
+// manifest of their SHA-256 checksums.
+func WriteArchive(w io.Writer, format string, files []ArchiveFile) error {
+	var add func(name string, data []byte, modified time.Time) error
+	var close func() error
+	switch format {
+	case ArchiveZip:
+		z := zip.NewWriter(w)
+		add = func(name string, data []byte, modified time.Time) error {
+			f, err := z.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+			if err != nil {
+				return err
+			}
+			_, err = f.Write(data)
+			return err
+		}
+		close = z.Close
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		t := tar.NewWriter(gz)
+		add = func(name string, data []byte, modified time.Time) error {
+			if err := t.WriteHeader(&tar.Header{
+				Name:     name,
+				Mode:     0644,
+				Size:     int64(len(data)),
+				ModTime:  modified,
+				Typeflag: tar.TypeReg,
+			}); err != nil {
+				return err
+			}
+			_, err := t.Write(data)
+			return err
+		}
+		close = func() error {
+			if err := t.Close(); err != nil {
+				return err
+			}
+			return gz.Close()
+		}
+	default:
+		return fmt.Errorf("unknown archive format %q, expected zip or tar.gz", format)
+	}
+
+	var sums strings.Builder
+	now := time.Now()
+	for _, f := range files {
+		data, modified := f.Data, now
+		if f.Path != "" {
+			info, err := os.Stat(f.Path)
+			if err != nil {
+				return err
+			}
+			if data, err = ioutil.ReadFile(f.Path); err != nil {
+				return err
+			}
+			modified = info.ModTime()
+		}
+		if err := add(f.Name, data, modified); err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(&sums, "%s  %s\n", hex.EncodeToString(sum[:]), f.Name)
+	}
+	if err := add(checksumsName, []byte(sums.String()), now); err != nil {
+		return err
+	}
+	return close()
+}
+
+// packageJob runs a Job into a temporary directory beside its output and writes the XML
+// document, its resources, its track file, its CPL, PKL and ASSETMAP, its delivery manifest
+// and, when encrypted, a key file into a single archive named after the document. The paths of
+// the Result are replaced by the names of the files within the archive.
+func (j Job) packageJob(ctx context.Context) (*Result, error) {
+	if j.Archive != ArchiveZip && j.Archive != ArchiveTarGz {
+		return nil, fmt.Errorf("unknown archive format %q, expected zip or tar.gz", j.Archive)
+	}
+	output := j.Output
+	tmp, err := ioutil.TempDir(output, ".package")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	j.Output = tmp
+	res, err := j.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var files []ArchiveFile
+	for _, p := range res.Files() {
+		files = append(files, ArchiveFile{Name: filepath.Base(p), Path: p})
+	}
+	res.XML = filepath.Base(res.XML)
+	for i, p := range res.Resources {
+		res.Resources[i] = filepath.Base(p)
+	}
+	if res.MXF != "" {
+		res.MXF = filepath.Base(res.MXF)
+	}
+	for i, p := range res.Composition {
+		res.Composition[i] = filepath.Base(p)
+	}
+	for i, p := range res.Manifest {
+		res.Manifest[i] = filepath.Base(p)
+	}
+	if res.KeyID != "" {
+		files = append(files, ArchiveFile{
+			Name: res.AssetUUID + ".key",
+			Data: []byte(fmt.Sprintf("KeyID: %s\nKeyString: %s\n", res.KeyID, res.Key)),
+		})
+	}
+	name := strings.TrimSuffix(res.XML, xmlFileExt) + "." + j.Archive
+	f, err := os.Create(filepath.Join(output, name))
+	if err != nil {
+		return nil, err
+	}
+	if err := WriteArchive(f, j.Archive, files); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	res.Archive = f.Name()
+	return res, nil
+}
@@ -0,0 +1,273 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// readArchive returns the files of a zip or tar.gz archive by name.
+func readArchive(t *testing.T, format string, data []byte) map[string][]byte {
+	t.Helper()
+	files := make(map[string][]byte)
+	switch format {
+	case ArchiveZip:
+		z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range z.File {
+			r, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[f.Name], err = ioutil.ReadAll(r)
+			r.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	case ArchiveTarGz:
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := tar.NewReader(gz)
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if files[h.Name], err = ioutil.ReadAll(tr); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return files
+}
+
+// checkChecksums verifies that checksums.sha256 lists the SHA-256 checksum of every other file
+// of an archive.
+func checkChecksums(t *testing.T, files map[string][]byte) {
+	t.Helper()
+	sums, ok := files[checksumsName]
+	if !ok {
+		t.Fatalf("archive lacks %s", checksumsName)
+	}
+	listed := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(string(sums)), "\n") {
+		parts := strings.SplitN(line, "  ", 2)
+		if len(parts) != 2 {
+			t.Fatalf("invalid checksum line %q", line)
+		}
+		data, ok := files[parts[1]]
+		if !ok {
+			t.Errorf("%s lists missing file %s", checksumsName, parts[1])
+			continue
+		}
+		listed[parts[1]] = true
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != parts[0] {
+			t.Errorf("%s: checksum mismatch", parts[1])
+		}
+	}
+	if len(listed) != len(files)-1 {
+		t.Errorf("%s lists %d of %d files", checksumsName, len(listed), len(files)-1)
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "source.xml")
+	if err := ioutil.WriteFile(src, []byte("<SubtitleReel/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []string{ArchiveZip, ArchiveTarGz} {
+		var b bytes.Buffer
+		err := WriteArchive(&b, format, []ArchiveFile{
+			{Name: "doc.xml", Path: src},
+			{Name: "notes.txt", Data: []byte("notes\n")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := readArchive(t, format, b.Bytes())
+		if string(files["doc.xml"]) != "<SubtitleReel/>" || string(files["notes.txt"]) != "notes\n" || len(files) != 3 {
+			t.Errorf("%s: unexpected content %q", format, files)
+		}
+		checkChecksums(t, files)
+	}
+	if err := WriteArchive(ioutil.Discard, "rar", nil); err == nil {
+		t.Error("WriteArchive accepted an unknown format")
+	}
+	if err := WriteArchive(ioutil.Discard, ArchiveZip, []ArchiveFile{{Name: "x", Path: filepath.Join(t.TempDir(), "missing")}}); err == nil {
+		t.Error("WriteArchive accepted a missing file")
+	}
+}
+
+func TestPackageJob(t *testing.T) {
+	for _, format := range []string{ArchiveZip, ArchiveTarGz} {
+		output := t.TempDir()
+		j := Job{Text: true, Track: true, Encrypt: false, Reel: 1, Framerate: "24", Language: "en", Title: "Package",
+			Output: output, Checksums: true, Archive: format, Composition: true, Wrapper: NativeWrapper{}, Log: ioutil.Discard}
+		res, err := j.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, _ := ioutil.ReadDir(output)
+		if len(entries) != 1 || filepath.Join(output, entries[0].Name()) != res.Archive {
+			t.Fatalf("%s: output holds %d files, want the archive %s", format, len(entries), res.Archive)
+		}
+		data, err := ioutil.ReadFile(res.Archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := readArchive(t, format, data)
+		checkChecksums(t, files)
+		var names []string
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		want := append(res.Files(), checksumsName)
+		sort.Strings(want)
+		if fmt.Sprint(names) != fmt.Sprint(want) {
+			t.Errorf("%s: archive holds %v, want %v", format, names, want)
+		}
+		if len(res.Composition) != 4 || len(res.Manifest) != 2 {
+			t.Errorf("%s: composition %v, manifest %v", format, res.Composition, res.Manifest)
+		}
+	}
+}
+
+// stubWrapper writes a placeholder track file.
+type stubWrapper struct{}
+
+func (stubWrapper) Wrap(source, output string, opts WrapOptions) error {
+	return ioutil.WriteFile(output, []byte("track file"), 0644)
+}
+
+func TestPackageJobEncrypted(t *testing.T) {
+	output := t.TempDir()
+	j := Job{Text: true, Track: true, Encrypt: true, Reel: 1, Framerate: "24", Language: "en", Title: "Package",
+		Output: output, Archive: ArchiveZip, Wrapper: stubWrapper{}, Log: ioutil.Discard}
+	res, err := j.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(res.Archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := readArchive(t, ArchiveZip, data)
+	checkChecksums(t, files)
+	key := fmt.Sprintf("KeyID: %s\nKeyString: %s\n", res.KeyID, res.Key)
+	if got := string(files[res.AssetUUID+".key"]); got != key {
+		t.Errorf("key file = %q, want %q", got, key)
+	}
+	j.Archive = "rar"
+	if _, err := j.Run(); err == nil {
+		t.Error("Run accepted an unknown archive format")
+	}
+	if entries, _ := ioutil.ReadDir(output); len(entries) != 1 {
+		t.Errorf("output holds %d files, want the archive only", len(entries))
+	}
+}
+
+func TestComposition(t *testing.T) {
+	output := t.TempDir()
+	j := Job{Text: true, Track: true, Reel: 1, Framerate: "24", Language: "en", Title: "Composition",
+		Output: output, Composition: true, Wrapper: NativeWrapper{}, Log: ioutil.Discard}
+	res, err := j.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func(name string, v interface{}) {
+		data, err := ioutil.ReadFile(filepath.Join(output, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := xml.Unmarshal(data, v); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	var am assetMap
+	read(assetMapName, &am)
+	var cplID, pklName string
+	paths := make(map[string]string)
+	for _, a := range am.Assets {
+		paths[a.ID] = a.Path
+		switch {
+		case a.PackingList == "true":
+			pklName = a.Path
+		case strings.HasPrefix(a.Path, cplPrefix):
+			cplID = a.ID
+		}
+		if fi, err := os.Stat(filepath.Join(output, a.Path)); err != nil || fi.Size() != a.Length {
+			t.Errorf("ASSETMAP %s: length %d, stat %v", a.Path, a.Length, err)
+		}
+	}
+	if len(am.Assets) != 3 || paths[urn+res.AssetUUID] != filepath.Base(res.MXF) || pklName == "" || cplID == "" {
+		t.Fatalf("ASSETMAP lists %v", paths)
+	}
+	var pkl packingList
+	read(pklName, &pkl)
+	if len(pkl.Assets) != 2 {
+		t.Fatalf("PKL lists %d assets, want 2", len(pkl.Assets))
+	}
+	for _, a := range pkl.Assets {
+		h, err := HashFile(filepath.Join(output, paths[a.ID]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.SHA1 != a.Hash || h.Size != a.Size {
+			t.Errorf("PKL %s: hash %s size %d, want %s %d", paths[a.ID], a.Hash, a.Size, h.SHA1, h.Size)
+		}
+	}
+	var cpl compositionPlaylist
+	read(paths[cplID], &cpl)
+	if cpl.ID != cplID || cpl.ContentTitleText != "Composition" || len(cpl.AssetList.Assets) != 1 || cpl.AssetList.Assets[0].ID != urn+res.AssetUUID {
+		t.Errorf("CPL = %+v", cpl)
+	}
+
+	j.Track = false
+	if _, err := j.Run(); err == nil {
+		t.Error("Run accepted a composition without a track file")
+	}
+}
@@ -0,0 +1,307 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Manifest describes a batch of generation jobs.
+type Manifest struct {
+	// Workers is the number of jobs run concurrently. Defaults to the number of CPUs.
+	Workers int
+	// Output is the directory every job writes into a sub-directory of its name.
+	Output string
+	// Wrapper and ASDCP select the track file wrapper as per NewWrapper.
+	Wrapper string
+	ASDCP   string
+	Jobs    []BatchJob
+}
+
+// BatchJob is a named Job of a Manifest.
+type BatchJob struct {
+	Name string
+	Job  Job
+}
+
+// BatchResult is the outcome of a single BatchJob.
+type BatchResult struct {
+	Name string `json:"name"`
+	*Result
+	// Messages are the findings and messages the job logged.
+	Messages []string `json:"messages,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// scalar is a manifest value given as a string or a number, e.g. a frame rate or Zposition.
+type scalar string
+
+// UnmarshalJSON accepts a JSON string, number or boolean.
+func (s *scalar) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case nil:
+		*s = ""
+	case string:
+		*s = scalar(v)
+	default:
+		*s = scalar(strings.TrimSpace(string(b)))
+	}
+	return nil
+}
+
+// manifestFile is the JSON and YAML encoding of a Manifest.
+type manifestFile struct {
+	Workers int       `json:"workers"`
+	Output  string    `json:"output"`
+	Wrapper string    `json:"wrapper"`
+	ASDCP   string    `json:"asdcp"`
+	Jobs    []jobFile `json:"jobs"`
+}
+
+// jobFile is the JSON and YAML encoding of a Job.
+type jobFile struct {
+	Name        string   `json:"name"`
+	Profile     string   `json:"profile"`
+	Framerate   scalar   `json:"framerate"`
+	Reel        *int     `json:"reel"`
+	Display     string   `json:"display"`
+	Language    string   `json:"language"`
+	Title       string   `json:"title"`
+	Template    string   `json:"template"`
+	Duration    *int     `json:"duration"`
+	Track       bool     `json:"track"`
+	Encrypt     bool     `json:"encrypt"`
+	Fonts       []string `json:"fonts"`
+	Subset      bool     `json:"subset"`
+	Captions    bool     `json:"captions"`
+	Direction   string   `json:"direction"`
+	Ruby        string   `json:"ruby"`
+	Zposition   scalar   `json:"zposition"`
+	VariableZ   scalar   `json:"variablez"`
+	Checksums   bool     `json:"checksums"`
+	Archive     string   `json:"archive"`
+	Composition bool     `json:"composition"`
+}
+
+// LoadManifest reads a batch manifest in JSON or, with a .yaml or .yml extension, YAML. Job
+// settings default as per ParseJob. Relative output, template and font paths are resolved
+// against the manifest's directory.
+func LoadManifest(filename string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		v, err := parseYAML(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	var f manifestFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if len(f.Jobs) == 0 {
+		return nil, fmt.Errorf("%s: no jobs", filename)
+	}
+	dir := filepath.Dir(filename)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	m := &Manifest{Workers: f.Workers, Output: resolve(f.Output), Wrapper: f.Wrapper, ASDCP: f.ASDCP}
+	names := make(map[string]bool)
+	for i, j := range f.Jobs {
+		name := j.Name
+		if name == "" {
+			name = fmt.Sprintf("job-%03d", i+1)
+		}
+		if names[name] || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("%s: job %d: invalid or duplicate name %q", filename, i+1, name)
+		}
+		names[name] = true
+		job, err := j.job(resolve)
+		if err != nil {
+			return nil, fmt.Errorf("%s: job %s: %w", filename, name, err)
+		}
+		m.Jobs = append(m.Jobs, BatchJob{Name: name, Job: job})
+	}
+	return m, nil
+}
+
+// ParseJob decodes a single job of a batch manifest in JSON. Paths are used as given. Settings
+// default to the text profile, reel 1, a frame rate of 24, language "en" and title "No Title",
+// and the duration is derived from the last TimeOut of the document.
+func ParseJob(data []byte) (Job, error) {
+	var j jobFile
+	if err := json.Unmarshal(data, &j); err != nil {
+		return Job{}, err
+	}
+	return j.job(func(p string) string { return p })
+}
+
+// job returns the Job of a manifest entry with its paths passed through resolve.
+func (j jobFile) job(resolve func(string) string) (Job, error) {
+	job := Job{
+		Text:        true,
+		Reel:        1,
+		Framerate:   string(j.Framerate),
+		Language:    j.Language,
+		Title:       j.Title,
+		Template:    resolve(j.Template),
+		Track:       j.Track,
+		Encrypt:     j.Encrypt,
+		Subset:      j.Subset,
+		Captions:    j.Captions,
+		Direction:   j.Direction,
+		RubyText:    j.Ruby,
+		Zposition:   string(j.Zposition),
+		VariableZ:   string(j.VariableZ),
+		Checksums:   j.Checksums,
+		Archive:     j.Archive,
+		Composition: j.Composition,
+	}
+	for _, p := range j.Fonts {
+		job.Fonts = append(job.Fonts, resolve(p))
+	}
+	switch strings.ToLower(j.Profile) {
+	case "", "text":
+	case "image":
+		job.Text, job.Image = false, true
+	default:
+		return Job{}, fmt.Errorf("unknown profile %q", j.Profile)
+	}
+	switch j.Display {
+	case "", "MainSubtitle":
+	case "ClosedCaption":
+		job.Display = 1
+	default:
+		return Job{}, fmt.Errorf("unknown display type %q", j.Display)
+	}
+	if j.Reel != nil {
+		job.Reel = *j.Reel
+	}
+	if j.Duration != nil {
+		if *j.Duration < 1 {
+			return Job{}, fmt.Errorf("invalid duration %d", *j.Duration)
+		}
+		job.Duration = *j.Duration
+	}
+	if job.Framerate == "" {
+		job.Framerate = "24"
+	}
+	if job.Language == "" {
+		job.Language = "en"
+	}
+	if job.Title == "" {
+		job.Title = "No Title"
+	}
+	if err := job.validate(); err != nil {
+		return Job{}, err
+	}
+	return job, nil
+}
+
+// RunBatch runs the jobs of a Manifest on a pool of Manifest.Workers goroutines. Every job
+// writes to its own sub-directory of Manifest.Output. Results are returned in job order.
+func RunBatch(m *Manifest) ([]BatchResult, error) {
+	if m.Output == "" {
+		return nil, fmt.Errorf("no output directory")
+	}
+	w, err := NewWrapper(m.Wrapper, m.ASDCP)
+	if err != nil {
+		return nil, err
+	}
+	workers := m.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	results := make([]BatchResult, len(m.Jobs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = runBatchJob(m.Jobs[i], filepath.Join(m.Output, m.Jobs[i].Name), w)
+			}
+		}()
+	}
+	for i := range m.Jobs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results, nil
+}
+
+// runBatchJob runs a single job into the output directory and collects its log. A panic of the
+// job is recovered and reported as its error.
+func runBatchJob(b BatchJob, output string, w Wrapper) (r BatchResult) {
+	r = BatchResult{Name: b.Name}
+	defer func() {
+		if v := recover(); v != nil {
+			r.Error = fmt.Sprintf("panic: %v", v)
+		}
+	}()
+	var log bytes.Buffer
+	job := b.Job
+	job.Output = output
+	job.Wrapper = w
+	job.Log = &log
+	if err := os.MkdirAll(output, 0755); err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	res, err := job.Run()
+	r.Result = res
+	if err != nil {
+		r.Error = err.Error()
+	}
+	for _, line := range strings.Split(log.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			r.Messages = append(r.Messages, line)
+		}
+	}
+	return r
+}
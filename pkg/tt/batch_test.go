@@ -0,0 +1,85 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseJobRejectsInvalidSettings(t *testing.T) {
+	for _, data := range []string{
+		`{"framerate": -5}`,
+		`{"framerate": 0}`,
+		`{"framerate": "abc"}`,
+		`{"framerate": "24/0"}`,
+		`{"framerate": "23.976"}`,
+		`{"reel": 0}`,
+		`{"reel": -1}`,
+		`{"duration": 0}`,
+		`{"duration": -24}`,
+	} {
+		if _, err := ParseJob([]byte(data)); err == nil {
+			t.Errorf("ParseJob(%s) returned no error", data)
+		}
+	}
+	j, err := ParseJob([]byte(`{"framerate": "24000/1001", "reel": 2, "duration": 120}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Framerate != "24000/1001" || j.Reel != 2 || j.Duration != 120 {
+		t.Errorf("got %+v", j)
+	}
+}
+
+func TestLoadManifestRejectsInvalidNames(t *testing.T) {
+	for _, name := range []string{".", "..", "a/b", `a\b`, "dup"} {
+		filename := filepath.Join(t.TempDir(), "batch.json")
+		data := fmt.Sprintf(`{"jobs": [{"name": "dup"}, {"name": %q}]}`, name)
+		if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadManifest(filename); err == nil {
+			t.Errorf("LoadManifest accepted job name %q", name)
+		}
+	}
+}
+
+// panicWrapper panics when wrapping a track file.
+type panicWrapper struct{}
+
+func (panicWrapper) Wrap(source, output string, opts WrapOptions) error {
+	panic("wrap failed")
+}
+
+func TestRunBatchJobRecoversPanics(t *testing.T) {
+	b := BatchJob{Name: "panic", Job: Job{Text: true, Track: true, Reel: 1, Framerate: "24", Language: "en", Title: "No Title"}}
+	r := runBatchJob(b, t.TempDir(), panicWrapper{})
+	if !strings.Contains(r.Error, "wrap failed") {
+		t.Errorf("Error = %q, want the recovered panic", r.Error)
+	}
+}
@@ -0,0 +1,210 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see <http://www.gnu.org/licenses/>.*/
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// pts90kHz is the clock rate of MPEG presentation timestamps.
+const pts90kHz = 90000
+
+// BitmapImportOptions configures the import of bitmap subtitle streams such as Blu-ray PGS and VobSub.
+type BitmapImportOptions struct {
+	// Output is the target output directory for the PNG image resources.
+	Output string
+	// Framerate is the EditRate of the resulting document, e.g. "24" or "24000/1001".
+	Framerate string
+	// Container is the target DCI container size, e.g. Container2K. Defaults to Container2K.
+	Container image.Point
+	// Stream selects the language stream of inputs carrying several, e.g. VobSub.
+	Stream   int
+	Reel     int
+	Display  int
+	Title    string
+	Language string
+}
+
+// bitmapPlacer maps bitmaps positioned on a video frame onto a DCI container.
+type bitmapPlacer struct {
+	opts      BitmapImportOptions
+	reel      *SubtitleReel
+	rate      float64
+	tc        *Timecode
+	scale     float64
+	pixel     float64
+	offset    image.Point
+	container image.Point
+}
+
+// newBitmapPlacer returns a placer for the given video frame size. The video frame is fit into the
+// container preserving its aspect ratio. Images are written at 2K resolution.
+func newBitmapPlacer(opts BitmapImportOptions, video image.Point) (*bitmapPlacer, error) {
+	if err := checkFrameRate(opts.Framerate); err != nil {
+		return nil, err
+	}
+	if opts.Container == (image.Point{}) {
+		opts.Container = Container2K
+	}
+	c := opts.Container
+	scale := math.Min(float64(c.X)/float64(video.X), float64(c.Y)/float64(video.Y))
+	s := newSubtitleReel(opts.Title, opts.Language, opts.Framerate, opts.Reel, opts.Display)
+	rate := getEditRate(s.EditRate)
+	tc, err := NewTimecode(rate)
+	if err != nil {
+		return nil, err
+	}
+	return &bitmapPlacer{
+		opts:  opts,
+		reel:  s,
+		rate:  rate,
+		tc:    tc,
+		scale: scale,
+		pixel: scale * math.Min(1, float64(Container2K.X)/float64(c.X)),
+		offset: image.Pt(
+			int((float64(c.X)-float64(video.X)*scale)/2),
+			int((float64(c.Y)-float64(video.Y)*scale)/2),
+		),
+		container: c,
+	}, nil
+}
+
+// timecode converts a 90 kHz presentation timestamp to a timecode at the document EditRate.
+func (p *bitmapPlacer) timecode(pts int64) string {
+	tc := *p.tc
+	tc.SetFrames(int(math.Round(float64(pts) * p.rate / pts90kHz)))
+	return tc.GetTimeCode()
+}
+
+// image crops a bitmap to its alpha bounding box, writes it as a UUID named PNG and returns the
+// Image element positioned at the given video frame coordinates.
+func (p *bitmapPlacer) image(img *image.NRGBA, at image.Point) (*Image, error) {
+	bbox := alphaBounds(img)
+	if bbox.Empty() {
+		return nil, nil
+	}
+	at = at.Add(bbox.Min.Sub(img.Bounds().Min))
+	sub := img.SubImage(bbox).(*image.NRGBA)
+	w := int(math.Round(float64(bbox.Dx()) * p.pixel))
+	h := int(math.Round(float64(bbox.Dy()) * p.pixel))
+	out := scaleNearest(sub, w, h)
+
+	ID := uuidType4()
+	f, err := os.Create(filepath.Join(p.opts.Output, ID))
+	if err != nil {
+		return nil, err
+	}
+	if err := encodeRGBA(f, out); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	x := float64(p.offset.X) + float64(at.X)*p.scale
+	y := float64(p.offset.Y) + float64(at.Y)*p.scale
+	return &Image{
+		Image:     urn + ID,
+		Halign:    "left",
+		Hposition: percent(x, p.container.X),
+		Valign:    "top",
+		Vposition: percent(y, p.container.Y),
+	}, nil
+}
+
+// add appends a Subtitle event holding the given images.
+func (p *bitmapPlacer) add(in, out int64, images []*Image) {
+	if len(images) == 0 || out <= in {
+		return
+	}
+	sub := &Subtitle{
+		SpotNumber: strconv.Itoa(len(p.reel.listFont().Subtitle) + 1),
+		TimeIn:     p.timecode(in),
+		TimeOut:    p.timecode(out),
+		Image:      images,
+	}
+	if sub.TimeIn == sub.TimeOut {
+		return
+	}
+	p.reel.listFont().Subtitle = append(p.reel.listFont().Subtitle, sub)
+}
+
+// percent formats v as a percentage of total with up to two decimal places.
+func percent(v float64, total int) string {
+	return strconv.FormatFloat(math.Round(v*10000/float64(total))/100, 'f', -1, 64)
+}
+
+// scaleNearest returns img resized to w by h using nearest neighbour sampling.
+func scaleNearest(img *image.NRGBA, w, h int) *image.NRGBA {
+	b := img.Bounds()
+	if w == b.Dx() && h == b.Dy() {
+		out := image.NewNRGBA(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			copy(out.Pix[y*out.Stride:], img.Pix[img.PixOffset(b.Min.X, b.Min.Y+y):img.PixOffset(b.Max.X, b.Min.Y+y)])
+		}
+		return out
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			out.SetNRGBA(x, y, img.NRGBAAt(b.Min.X+x*b.Dx()/w, b.Min.Y+y*b.Dy()/h))
+		}
+	}
+	return out
+}
+
+// ycbcrToNRGBA converts a limited range BT.709 Y'CbCr value with alpha to NRGBA.
+func ycbcrToNRGBA(y, cb, cr, a uint8) color.NRGBA {
+	yf := (float64(y) - 16) * 255 / 219
+	cbf := (float64(cb) - 128) * 255 / 224
+	crf := (float64(cr) - 128) * 255 / 224
+	return color.NRGBA{
+		R: clamp8(yf + 1.5748*crf),
+		G: clamp8(yf - 0.1873*cbf - 0.4681*crf),
+		B: clamp8(yf + 1.8556*cbf),
+		A: a,
+	}
+}
+
+// clamp8 rounds and clamps v to the range of a uint8.
+func clamp8(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+	return uint8(math.Round(v))
+}
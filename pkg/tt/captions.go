@@ -0,0 +1,148 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CaptionRules holds the limits applied by ValidateCaptions.
+type CaptionRules struct {
+	// MaxLines is the largest number of lines of a single caption event.
+	MaxLines int
+	// MaxLineLength is the largest number of characters of a single line.
+	MaxLineLength int
+	// MaxVposition is the largest Vposition, in percent, from the top or bottom edge.
+	MaxVposition float64
+}
+
+// DefaultCaptionRules reflects common delivery practice for closed caption assistive devices.
+var DefaultCaptionRules = CaptionRules{
+	MaxLines:      3,
+	MaxLineLength: 32,
+	MaxVposition:  15,
+}
+
+// caption defaults applied to generated closed caption documents.
+const (
+	captionHalign    = "center"
+	captionValign    = "bottom"
+	captionVposition = "10"
+)
+
+// speakerRegexp matches a speaker identification label at the start of a line, e.g. "JOHN:".
+var speakerRegexp = regexp.MustCompile(`^-?\s*([^\[\]:]{1,32}):\s`)
+
+// ValidateCaptions checks a closed caption document against the given rules. Closed captions are
+// text only, limited in line count, line length and position, and use upper case speaker
+// identification labels and square brackets for sound effects.
+func ValidateCaptions(s *SubtitleReel, rules CaptionRules) []Finding {
+	var findings findingList
+	if s.DisplayType != "ClosedCaption" {
+		findings.add(SeverityError, "DisplayType", "DisplayType is %q, expected ClosedCaption", s.DisplayType)
+	}
+	for i, sub := range subtitles(s) {
+		report := findings.at(subtitleLocation(i, sub))
+		if len(sub.images()) > 0 {
+			report(SeverityError, "Image elements are not permitted in closed captions")
+		}
+		var lines []string
+		// edge is the Vposition of the line nearest to the edge, stacked lines are above it.
+		edge := -1.0
+		for _, t := range sub.texts() {
+			checkCaptionPosition(t, report)
+			if v, err := strconv.ParseFloat(t.Vposition, 64); err == nil && v >= 0 && (edge < 0 || v < edge) {
+				edge = v
+			}
+			lines = append(lines, strings.Split(textContent(t), "\n")...)
+		}
+		if edge > rules.MaxVposition {
+			report(SeverityError, "Vposition %g is outside the range 0 to %g", edge, rules.MaxVposition)
+		}
+		if len(lines) > rules.MaxLines {
+			report(SeverityError, "%d lines exceed the limit of %d", len(lines), rules.MaxLines)
+		}
+		for _, l := range lines {
+			l = strings.TrimSpace(l)
+			if n := utf8.RuneCountInString(l); n > rules.MaxLineLength {
+				report(SeverityError, "line %q has %d characters, exceeding the limit of %d", l, n, rules.MaxLineLength)
+			}
+			if msg := checkBrackets(l); msg != "" {
+				report(SeverityError, "line %q: %s", l, msg)
+			}
+			if m := speakerRegexp.FindStringSubmatch(l); m != nil && strings.ToUpper(m[1]) != m[1] {
+				report(SeverityWarning, "speaker identification %q should be upper case", m[1])
+			}
+		}
+	}
+	return findings
+}
+
+// checkCaptionPosition reports Text alignments outside the caption positional limits and invalid
+// Vpositions. The Vposition limit applies to the line nearest to the edge, see ValidateCaptions.
+func checkCaptionPosition(t *Text, report func(severity, format string, a ...interface{})) {
+	switch t.Valign {
+	case "", "top", "bottom":
+	default:
+		report(SeverityError, "Valign %q is not permitted, expected top or bottom", t.Valign)
+	}
+	if t.Vposition != "" {
+		if v, err := strconv.ParseFloat(t.Vposition, 64); err != nil || v < 0 {
+			report(SeverityError, "invalid Vposition %q", t.Vposition)
+		}
+	}
+	if (t.Halign != "" && t.Halign != captionHalign) || (t.Hposition != "" && t.Hposition != "0") {
+		report(SeverityWarning, "captions are expected to be horizontally centered")
+	}
+}
+
+// checkBrackets reports unbalanced, nested or empty sound effect brackets in a line.
+func checkBrackets(line string) string {
+	open := -1
+	for i, r := range line {
+		switch r {
+		case '[':
+			if open >= 0 {
+				return "nested sound effect brackets"
+			}
+			open = i
+		case ']':
+			if open < 0 {
+				return "unbalanced sound effect brackets"
+			}
+			if strings.IndexFunc(line[open+1:i], func(r rune) bool { return !unicode.IsSpace(r) }) < 0 {
+				return "empty sound effect brackets"
+			}
+			open = -1
+		}
+	}
+	if open >= 0 {
+		return "unbalanced sound effect brackets"
+	}
+	return ""
+}
@@ -0,0 +1,127 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestValidateCaptions(t *testing.T) {
+	caption := func(text string) string {
+		return diffSub("1", "00:00:01:00", "00:00:02:00", text)
+	}
+	for _, tc := range []struct {
+		name, subtitles string
+		want            []string
+	}{
+		{"valid", caption("JOHN: Hello [door slams]"), nil},
+		{"stacked lines", `<Subtitle SpotNumber="1" TimeIn="00:00:01:00" TimeOut="00:00:02:00">` +
+			`<Text Valign="bottom" Vposition="16.5">First line</Text><Text Valign="bottom" Vposition="10">Second line</Text></Subtitle>`, nil},
+		{"no SpotNumber", diffSub("", "00:00:01:00", "00:00:02:00", "[]"),
+			[]string{`error: Subtitle #1: line "[]": empty sound effect brackets`}},
+		{"lines", caption("One\nTwo\nThree\nFour"), []string{"error: Subtitle 1: 4 lines exceed the limit of 3"}},
+		{"line length", caption("This line is far too long for a caption"),
+			[]string{`error: Subtitle 1: line "This line is far too long for a caption" has 39 characters, exceeding the limit of 32`}},
+		{"unbalanced brackets", caption("[door"), []string{`error: Subtitle 1: line "[door": unbalanced sound effect brackets`}},
+		{"nested brackets", caption("[[door]]"), []string{`error: Subtitle 1: line "[[door]]": nested sound effect brackets`}},
+		{"speaker", caption("John: Hello"), []string{`warning: Subtitle 1: speaker identification "John" should be upper case`}},
+		{"image", `<Subtitle SpotNumber="1" TimeIn="00:00:01:00" TimeOut="00:00:02:00"><Image>urn:uuid:0b1c2d3e-4f5a-4b6c-9d7e-8f9a0b1c2d3e</Image></Subtitle>`,
+			[]string{"error: Subtitle 1: Image elements are not permitted in closed captions"}},
+		{"Vposition", `<Subtitle SpotNumber="1" TimeIn="00:00:01:00" TimeOut="00:00:02:00"><Text Valign="top" Vposition="20">Hello</Text></Subtitle>`,
+			[]string{"error: Subtitle 1: Vposition 20 is outside the range 0 to 15"}},
+		{"invalid Vposition", `<Subtitle SpotNumber="1" TimeIn="00:00:01:00" TimeOut="00:00:02:00"><Text Valign="bottom" Vposition="-1">Hello</Text></Subtitle>`,
+			[]string{`error: Subtitle 1: invalid Vposition "-1"`}},
+		{"alignment", `<Subtitle SpotNumber="1" TimeIn="00:00:01:00" TimeOut="00:00:02:00"><Text Halign="left" Valign="center">Hello</Text></Subtitle>`,
+			[]string{`error: Subtitle 1: Valign "center" is not permitted, expected top or bottom`,
+				"warning: Subtitle 1: captions are expected to be horizontally centered"}},
+	} {
+		s := diffReel(t, "en", tc.subtitles)
+		s.DisplayType = "ClosedCaption"
+		var got []string
+		for _, f := range ValidateCaptions(s, DefaultCaptionRules) {
+			got = append(got, f.String())
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got findings %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	s := diffReel(t, "en", diffSub("1", "00:00:01:00", "00:00:02:00", "Hello"))
+	s.DisplayType = "MainSubtitle"
+	findings := ValidateCaptions(s, CaptionRules{MaxLines: 1, MaxLineLength: 4})
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+	want := []string{
+		`error: DisplayType: DisplayType is "MainSubtitle", expected ClosedCaption`,
+		`error: Subtitle 1: Vposition 10 is outside the range 0 to 0`,
+		`error: Subtitle 1: line "Hello" has 5 characters, exceeding the limit of 4`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got findings %q, want %q", got, want)
+	}
+}
+
+func TestNewReelAsset(t *testing.T) {
+	for _, tc := range []struct {
+		captions bool
+		want     xml.Name
+	}{
+		{false, xml.Name{Space: cplNamespace, Local: "MainSubtitle"}},
+		{true, xml.Name{Space: ccNamespace, Local: "ClosedCaption"}},
+	} {
+		j := Job{Text: true, Track: true, Captions: tc.captions, Reel: 1, Framerate: "24", Language: "en",
+			Title: "Reel asset", Output: t.TempDir(), Wrapper: NativeWrapper{}, Log: ioutil.Discard}
+		res, err := j.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := NewReelAsset(res.MXF, "0d0b1d8a-7e8f-4a0b-8c5d-6e7f8a9b0c1d")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.XMLName != tc.want || a.ID != urn+res.AssetUUID || a.Language != "en" || a.AnnotationText != "Reel asset" {
+			t.Errorf("captions %v: got reel asset %+v", tc.captions, a)
+		}
+		if a.KeyID != "urn:uuid:0d0b1d8a-7e8f-4a0b-8c5d-6e7f8a9b0c1d" {
+			t.Errorf("captions %v: got KeyId %s", tc.captions, a.KeyID)
+		}
+		// the reel asset is marshalled in the namespace of its kind.
+		data, err := xml.Marshal(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got struct{ XMLName xml.Name }
+		if err := xml.Unmarshal(data, &got); err != nil || got.XMLName != tc.want {
+			t.Errorf("captions %v: marshalled as %v, %v", tc.captions, got.XMLName, err)
+		}
+	}
+	if _, err := NewReelAsset("missing.mxf", ""); err == nil {
+		t.Error("NewReelAsset returned no error for a missing file")
+	}
+}
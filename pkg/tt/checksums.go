@@ -0,0 +1,256 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Asset types of a DeliveryManifest.
+const (
+	AssetXML   = "xml"
+	AssetFont  = "font"
+	AssetImage = "image"
+	AssetMXF   = "mxf"
+	// AssetComposition is the type of the CPL, PKL, ASSETMAP and VOLINDEX.
+	AssetComposition = "composition"
+)
+
+// uuidPrefix matches the UUID that every generated file name starts with.
+var uuidPrefix = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+
+// manifestHeader is the header row of the CSV encoding of a DeliveryManifest.
+var manifestHeader = []string{"name", "uuid", "type", "size", "sha1", "sha256", "md5"}
+
+// Asset describes a generated file of a DeliveryManifest.
+type Asset struct {
+	// Name is the file name, relative to the manifest.
+	Name string `json:"name"`
+	// UUID is the document Id, resource name or AssetUUID the file is named after.
+	UUID string `json:"uuid"`
+	Type string `json:"type"`
+	Size int64  `json:"size"`
+	// SHA1 is base64 encoded, as in a PKL Hash element. SHA256 and MD5 are hex encoded.
+	SHA1   string `json:"sha1"`
+	SHA256 string `json:"sha256"`
+	MD5    string `json:"md5"`
+}
+
+// DeliveryManifest lists the checksums, sizes and UUIDs of the files generated for a document.
+type DeliveryManifest struct {
+	DocumentID string  `json:"documentId"`
+	Assets     []Asset `json:"assets"`
+}
+
+// HashFile returns the Asset of a generated file. Its type is derived from the file name and,
+// for resources, from the PNG signature.
+func HashFile(filename string) (Asset, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return Asset{}, err
+	}
+	name := filepath.Base(filename)
+	a := hashData(data)
+	a.Name = name
+	a.UUID = uuidPrefix.FindString(name)
+	switch {
+	case strings.HasPrefix(name, cplPrefix) || strings.HasPrefix(name, pklPrefix):
+		a.Type = AssetComposition
+		a.UUID = uuidPrefix.FindString(name[len(cplPrefix):])
+	case name == assetMapName || name == volIndexName:
+		a.Type = AssetComposition
+	case strings.HasSuffix(name, xmlFileExt):
+		a.Type = AssetXML
+	case strings.HasSuffix(name, ".mxf"):
+		a.Type = AssetMXF
+	case bytes.HasPrefix(data, []byte(pngSignature)):
+		a.Type = AssetImage
+	default:
+		a.Type = AssetFont
+	}
+	return a, nil
+}
+
+// hashData returns an Asset holding the size and checksums of data.
+func hashData(data []byte) Asset {
+	sha1Sum := sha1.Sum(data)
+	sha256Sum := sha256.Sum256(data)
+	md5Sum := md5.Sum(data)
+	return Asset{
+		Size:   int64(len(data)),
+		SHA1:   base64.StdEncoding.EncodeToString(sha1Sum[:]),
+		SHA256: hex.EncodeToString(sha256Sum[:]),
+		MD5:    hex.EncodeToString(md5Sum[:]),
+	}
+}
+
+// WriteDeliveryManifest writes m as JSON and CSV to the output directory, named after the XML
+// document as uuid_rN_manifest.json and uuid_rN_manifest.csv, and returns both paths.
+func WriteDeliveryManifest(m *DeliveryManifest, output string) ([]string, error) {
+	base := strings.TrimPrefix(m.DocumentID, urn)
+	for _, a := range m.Assets {
+		if a.Type == AssetXML {
+			base = strings.TrimSuffix(a.Name, xmlFileExt)
+		}
+	}
+	base = filepath.Join(output, base+"_manifest")
+	enc, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(base+".json", append(enc, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write(manifestHeader)
+	for _, a := range m.Assets {
+		w.Write([]string{a.Name, a.UUID, a.Type, strconv.FormatInt(a.Size, 10), a.SHA1, a.SHA256, a.MD5})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(base+".csv", b.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	return []string{base + ".json", base + ".csv"}, nil
+}
+
+// ReadDeliveryManifest reads a delivery manifest in JSON or, with a .csv extension, CSV.
+func ReadDeliveryManifest(filename string) (*DeliveryManifest, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m := &DeliveryManifest{}
+	if !strings.EqualFold(filepath.Ext(filename), ".csv") {
+		if err := json.NewDecoder(f).Decode(m); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		return m, nil
+	}
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = len(manifestHeader)
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		if line == 1 && rec[0] == manifestHeader[0] {
+			continue
+		}
+		size, err := strconv.ParseInt(rec[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: invalid size %q", filename, line, rec[3])
+		}
+		a := Asset{Name: rec[0], UUID: rec[1], Type: rec[2], Size: size, SHA1: rec[4], SHA256: rec[5], MD5: rec[6]}
+		if a.Type == AssetXML {
+			m.DocumentID = urn + a.UUID
+		}
+		m.Assets = append(m.Assets, a)
+	}
+	return m, nil
+}
+
+// VerifyDeliveryManifest checks the files listed in a delivery manifest, resolved relative to
+// the manifest, for presence, truncation and tampering. Every file must be listed with all
+// three checksums, and absolute names or names leaving the manifest directory are rejected.
+func VerifyDeliveryManifest(filename string) ([]Finding, error) {
+	m, err := ReadDeliveryManifest(filename)
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	dir := filepath.Dir(filename)
+	for _, a := range m.Assets {
+		report := func(format string, args ...interface{}) {
+			findings = append(findings, Finding{
+				Severity: SeverityError,
+				Location: a.Name,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+		// names are untrusted, only files beside the manifest or below it are read.
+		if !localName(a.Name) {
+			report("invalid file name, expected a relative path within the manifest directory")
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(a.Name)))
+		if err != nil {
+			report("%v", err)
+			continue
+		}
+		got := hashData(data)
+		if got.Size != a.Size {
+			report("size is %d bytes, expected %d", got.Size, a.Size)
+		}
+		for _, c := range []struct{ name, got, want string }{
+			{"SHA-1", got.SHA1, a.SHA1},
+			{"SHA-256", got.SHA256, a.SHA256},
+			{"MD5", got.MD5, a.MD5},
+		} {
+			switch {
+			case c.want == "":
+				report("%s checksum missing", c.name)
+			case c.got != c.want:
+				report("%s checksum mismatch", c.name)
+			}
+		}
+	}
+	return findings, nil
+}
+
+// localName reports whether a manifest file name is a non-empty relative path that does not
+// leave the manifest directory, in either slash or backslash notation.
+func localName(name string) bool {
+	if name == "" || filepath.IsAbs(name) || filepath.VolumeName(name) != "" || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) {
+		return false
+	}
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
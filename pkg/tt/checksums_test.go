@@ -0,0 +1,197 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDocumentUUID = "5d2740ae-25ab-428f-b5a2-6cd5287e5336"
+
+// writeTestFiles writes files of the given names and content to a new directory.
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestHashFile(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		testDocumentUUID + "_r1.xml":                      "abc",
+		"a6a0c5dc-a2b5-43a0-a9d5-f3c898c3d1e8_r1_sub.mxf": "mxf",
+		"232c45d8-fde8-4e5e-86b9-86e96354daf3":            "font",
+		"0f1e2d3c-4b5a-4968-8776-a5b4c3d2e1f0":            pngSignature + "image",
+		"CPL_fe95339a-acaf-444d-9537-2ec66255e3f8.xml":    "<CompositionPlaylist/>",
+		"ASSETMAP.xml": "<AssetMap/>",
+	})
+	a, err := HashFile(filepath.Join(dir, testDocumentUUID+"_r1.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Asset{
+		Name:   testDocumentUUID + "_r1.xml",
+		UUID:   testDocumentUUID,
+		Type:   AssetXML,
+		Size:   3,
+		SHA1:   "qZk+NkcGgWq6PiVxeFDCbJzQ2J0=",
+		SHA256: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		MD5:    "900150983cd24fb0d6963f7d28e17f72",
+	}
+	if a != want {
+		t.Errorf("HashFile() = %+v, want %+v", a, want)
+	}
+	for name, want := range map[string][2]string{
+		"a6a0c5dc-a2b5-43a0-a9d5-f3c898c3d1e8_r1_sub.mxf": {"a6a0c5dc-a2b5-43a0-a9d5-f3c898c3d1e8", AssetMXF},
+		"232c45d8-fde8-4e5e-86b9-86e96354daf3":            {"232c45d8-fde8-4e5e-86b9-86e96354daf3", AssetFont},
+		"0f1e2d3c-4b5a-4968-8776-a5b4c3d2e1f0":            {"0f1e2d3c-4b5a-4968-8776-a5b4c3d2e1f0", AssetImage},
+		"CPL_fe95339a-acaf-444d-9537-2ec66255e3f8.xml":    {"fe95339a-acaf-444d-9537-2ec66255e3f8", AssetComposition},
+		"ASSETMAP.xml": {"", AssetComposition},
+	} {
+		a, err := HashFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := [2]string{a.UUID, a.Type}; got != want {
+			t.Errorf("%s: uuid and type %v, want %v", name, got, want)
+		}
+	}
+	if _, err := HashFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("HashFile accepted a missing file")
+	}
+}
+
+// testManifest returns the delivery manifest of an XML document and its font in a new directory.
+func testManifest(t *testing.T) (*DeliveryManifest, string) {
+	t.Helper()
+	dir := writeTestFiles(t, map[string]string{
+		testDocumentUUID + "_r1.xml":           "<SubtitleReel/>",
+		"232c45d8-fde8-4e5e-86b9-86e96354daf3": "font",
+	})
+	m := &DeliveryManifest{DocumentID: urn + testDocumentUUID}
+	for _, name := range []string{testDocumentUUID + "_r1.xml", "232c45d8-fde8-4e5e-86b9-86e96354daf3"} {
+		a, err := HashFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Assets = append(m.Assets, a)
+	}
+	return m, dir
+}
+
+func TestDeliveryManifestRoundTrip(t *testing.T) {
+	m, dir := testManifest(t)
+	paths, err := WriteDeliveryManifest(m, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Join(dir, testDocumentUUID+"_r1_manifest")
+	if !reflect.DeepEqual(paths, []string{base + ".json", base + ".csv"}) {
+		t.Fatalf("WriteDeliveryManifest() = %v", paths)
+	}
+	for _, p := range paths {
+		got, err := ReadDeliveryManifest(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, m) {
+			t.Errorf("%s: got %+v, want %+v", filepath.Base(p), got, m)
+		}
+		findings, err := VerifyDeliveryManifest(p)
+		if err != nil || len(findings) != 0 {
+			t.Errorf("%s: findings %v, error %v", filepath.Base(p), findings, err)
+		}
+	}
+}
+
+func TestReadDeliveryManifestErrors(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"size.csv":     "name,uuid,type,size,sha1,sha256,md5\na,,xml,big,,,\n",
+		"fields.csv":   "name,uuid,type,size\n",
+		"invalid.json": "{",
+	})
+	for _, name := range []string{"size.csv", "fields.csv", "invalid.json", "missing.json"} {
+		if _, err := ReadDeliveryManifest(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestVerifyDeliveryManifest(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(m *DeliveryManifest, dir string)
+		want   string
+	}{
+		{"tampered", func(m *DeliveryManifest, dir string) {
+			ioutil.WriteFile(filepath.Join(dir, m.Assets[1].Name), []byte("FONT"), 0644)
+		}, "SHA-1 checksum mismatch"},
+		{"truncated", func(m *DeliveryManifest, dir string) {
+			ioutil.WriteFile(filepath.Join(dir, m.Assets[1].Name), []byte("fo"), 0644)
+		}, "size is 2 bytes, expected 4"},
+		{"missing file", func(m *DeliveryManifest, dir string) {
+			m.Assets[1].Name = "missing"
+		}, "no such file"},
+		{"blank checksum", func(m *DeliveryManifest, dir string) {
+			m.Assets[1].SHA256 = ""
+		}, "SHA-256 checksum missing"},
+		{"parent directory", func(m *DeliveryManifest, dir string) {
+			m.Assets[1].Name = "../" + m.Assets[1].Name
+		}, "invalid file name"},
+		{"nested parent directory", func(m *DeliveryManifest, dir string) {
+			m.Assets[1].Name = `fonts\..\..\` + m.Assets[1].Name
+		}, "invalid file name"},
+		{"absolute path", func(m *DeliveryManifest, dir string) {
+			m.Assets[1].Name = filepath.Join(dir, m.Assets[1].Name)
+		}, "invalid file name"},
+		{"empty name", func(m *DeliveryManifest, dir string) {
+			m.Assets[1].Name = ""
+		}, "invalid file name"},
+	}
+	for _, tc := range tests {
+		m, dir := testManifest(t)
+		tc.modify(m, dir)
+		paths, err := WriteDeliveryManifest(m, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range paths {
+			findings, err := VerifyDeliveryManifest(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(findings) == 0 || findings[0].Severity != SeverityError || !strings.Contains(findings[0].Message, tc.want) {
+				t.Errorf("%s %s: findings %v, want %q", tc.name, filepath.Ext(p), findings, tc.want)
+			}
+		}
+	}
+}
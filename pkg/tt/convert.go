@@ -0,0 +1,314 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ST 428-7 namespaces that documents can be converted to.
+const (
+	DCST2010 = "http://www.smpte-ra.org/schemas/428-7/2010/DCST"
+	DCST2014 = "http://www.smpte-ra.org/schemas/428-7/2014/DCST"
+)
+
+// attrs2007 maps the attribute names of ST 428-7:2007 documents to their later names.
+var attrs2007 = map[string]string{
+	"Id":        "ID",
+	"HAlign":    "Halign",
+	"HPosition": "Hposition",
+	"VAlign":    "Valign",
+	"VPosition": "Vposition",
+}
+
+// elements2007 maps the element names of ST 428-7:2007 documents to their later names.
+var elements2007 = map[string]string{
+	"ID":           "Id",
+	"TimecodeRate": "TimeCodeRate",
+}
+
+// timeAttrs are the Subtitle attributes holding a time value.
+var timeAttrs = map[string]bool{"TimeIn": true, "TimeOut": true, "FadeUpTime": true, "FadeDownTime": true}
+
+// ConvertFile reads a document and converts it to the given namespace, DCST2010 or DCST2014.
+// See ConvertDocument for the accepted formats.
+func ConvertFile(filename, namespace string, opts TextImportOptions) (*SubtitleReel, []Finding, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ConvertDocument(filepath.Base(filename), data, namespace, opts)
+}
+
+// ConvertDocument converts a document to the given namespace, DCST2010 or DCST2014. Its format is
+// determined by DetectFormat from its name and content: ST 428-7 2007 documents are upgraded with
+// Upgrade2007 and later ones converted with Convert, while SRT, WebVTT, IMSC and Interop documents
+// are imported with ImportText using opts.
+func ConvertDocument(name string, data []byte, namespace string, opts TextImportOptions) (*SubtitleReel, []Finding, error) {
+	format, err := DetectFormat(name, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if format != FormatDCST {
+		s, findings, err := ImportText(data, format, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		c, err := Convert(s, namespace)
+		return s, append(findings, c...), err
+	}
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, nil, err
+	}
+	if root.XMLName.Space == dcst2007 {
+		return Upgrade2007(bytes.NewReader(data), namespace)
+	}
+	if _, ok := xmlNsSubtitle[root.XMLName.Space]; !ok {
+		return nil, nil, fmt.Errorf("unsupported namespace: %s", root.XMLName.Space)
+	}
+	var s SubtitleReel
+	if err := xml.Unmarshal(data, &s); err != nil {
+		return nil, nil, err
+	}
+	findings, err := Convert(&s, namespace)
+	return &s, findings, err
+}
+
+// Convert converts a SubtitleReel between the 2010 and 2014 namespaces and gives it a new Id.
+// Upgrading is lossless.
+// Down-converting drops the 2014 constructs: LoadVariableZ, Zposition and VariableZ are removed,
+// and Ruby, Space, HGroup and Rotate are replaced by their displayed text. Every dropped feature
+// is reported as a warning.
+func Convert(s *SubtitleReel, namespace string) ([]Finding, error) {
+	if namespace != DCST2010 && namespace != DCST2014 {
+		return nil, fmt.Errorf("unsupported target namespace: %s", namespace)
+	}
+	s.XMLName.Space = namespace
+	s.Xmlns = namespace
+	s.ID = urn + uuidType4()
+	if namespace == DCST2014 {
+		return nil, nil
+	}
+
+	var findings findingList
+	drop := func(loc, format string, a ...interface{}) {
+		findings.add(SeverityWarning, loc, format, a...)
+	}
+	for _, l := range s.LoadVariableZ {
+		drop("LoadVariableZ "+l.ID, "dropped LoadVariableZ")
+	}
+	s.LoadVariableZ = nil
+	for i, sub := range subtitles(s) {
+		loc := subtitleLocation(i, sub)
+		dropDepth := func(element string, zposition, variableZ *string) {
+			if *zposition != "" {
+				drop(loc, "dropped %s Zposition %s", element, *zposition)
+			}
+			if *variableZ != "" {
+				drop(loc, "dropped %s VariableZ %s", element, *variableZ)
+			}
+			*zposition, *variableZ = "", ""
+		}
+		for _, t := range sub.texts() {
+			dropDepth("Text", &t.Zposition, &t.VariableZ)
+			var runs []*Run
+			for _, r := range t.Runs {
+				switch {
+				case r.Ruby != nil:
+					annotation := ""
+					if r.Ruby.Rt != nil {
+						annotation = r.Ruby.Rt.Text
+					}
+					drop(loc, "dropped Ruby annotation %q of %q", annotation, r.Ruby.Rb)
+					r = &Run{Text: r.Ruby.Rb}
+				case r.Space != nil:
+					drop(loc, "replaced Space with a space character")
+					r = &Run{Text: " "}
+				case r.HGroup != nil:
+					drop(loc, "dropped HGroup of %q", r.HGroup.Text)
+					r = &Run{Text: r.HGroup.Text}
+				case r.Rotate != nil:
+					drop(loc, "dropped Rotate of %q", r.Rotate.Text)
+					r = &Run{Text: r.Rotate.Text}
+				}
+				// merge adjacent character data left by replaced runs.
+				if n := len(runs); n > 0 && r.isText() && runs[n-1].isText() {
+					runs[n-1] = &Run{Text: runs[n-1].Text + r.Text}
+					continue
+				}
+				runs = append(runs, r)
+			}
+			t.Runs = runs
+		}
+		for _, img := range sub.images() {
+			dropDepth("Image", &img.Zposition, &img.VariableZ)
+		}
+	}
+	return findings, nil
+}
+
+// Upgrade2007 reads an ST 428-7:2007 document and converts it to the given namespace, DCST2010
+// or DCST2014. Renamed elements and attributes are mapped to their later names and time values
+// counted in edit units of the EditRate are converted to frames of the TimeCodeRate, which
+// defaults to the EditRate. A missing DisplayType defaults to MainSubtitle.
+func Upgrade2007(r io.Reader, namespace string) (*SubtitleReel, []Finding, error) {
+	if namespace != DCST2010 && namespace != DCST2014 {
+		return nil, nil, fmt.Errorf("unsupported target namespace: %s", namespace)
+	}
+	d := xml.NewDecoder(r)
+	var b bytes.Buffer
+	e := xml.NewEncoder(&b)
+	var findings []Finding
+	var path []string
+	var editRate, timecodeRate string
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(path) == 0 && t.Name.Space != dcst2007 {
+				return nil, nil, fmt.Errorf("not a 2007 document: %s", t.Name.Space)
+			}
+			name := t.Name.Local
+			if n, ok := elements2007[name]; ok {
+				name = n
+			}
+			path = append(path, name)
+			start := xml.StartElement{Name: xml.Name{Local: name}}
+			if len(path) == 1 {
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: namespace})
+			}
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+					continue
+				}
+				name := a.Name.Local
+				if n, ok := attrs2007[name]; ok {
+					name = n
+				}
+				value := a.Value
+				if start.Name.Local == "Subtitle" && timeAttrs[name] {
+					if value, err = editUnitsToFrames(value, editRate, timecodeRate); err != nil {
+						return nil, nil, fmt.Errorf("Subtitle %s: %w", name, err)
+					}
+				}
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+			}
+			err = e.EncodeToken(start)
+		case xml.EndElement:
+			err = e.EncodeToken(xml.EndElement{Name: xml.Name{Local: path[len(path)-1]}})
+			path = path[:len(path)-1]
+		case xml.CharData:
+			if len(path) == 2 {
+				switch path[1] {
+				case "EditRate":
+					editRate = strings.TrimSpace(string(t))
+				case "TimeCodeRate":
+					timecodeRate = strings.TrimSpace(string(t))
+				}
+			}
+			err = e.EncodeToken(t)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := e.Flush(); err != nil {
+		return nil, nil, err
+	}
+
+	var s SubtitleReel
+	if err := xml.Unmarshal(b.Bytes(), &s); err != nil {
+		return nil, nil, err
+	}
+	if s.DisplayType == "" {
+		s.DisplayType = "MainSubtitle"
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Location: "DisplayType",
+			Message:  "no DisplayType, set to MainSubtitle",
+		})
+	}
+	if s.TimeCodeRate == "" {
+		s.TimeCodeRate = strconv.Itoa(int(math.Round(getEditRate(s.EditRate))))
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Location: "TimeCodeRate",
+			Message:  "no TimeCodeRate, set to the EditRate " + s.TimeCodeRate,
+		})
+	}
+	c, err := Convert(&s, namespace)
+	return &s, append(findings, c...), err
+}
+
+// editUnitsToFrames converts a 2007 time value, either a timecode counting edit units in its
+// last field or a plain count of edit units, to a timecode counting frames of the TimeCodeRate,
+// or of the EditRate rounded to whole frames when there is no TimeCodeRate.
+func editUnitsToFrames(value, editRate, timecodeRate string) (string, error) {
+	rate := getEditRate(editRate)
+	if timecodeRate == "" {
+		timecodeRate = strconv.Itoa(int(math.Round(rate)))
+	}
+	tcr, err := strconv.Atoi(timecodeRate)
+	if rate <= 0 || err != nil || tcr <= 0 {
+		return "", errors.New("time value precedes a valid EditRate and TimeCodeRate")
+	}
+	var seconds, units int
+	if n, err := strconv.Atoi(value); err == nil {
+		units = n
+	} else {
+		m := tcRegexp.FindStringSubmatch(value)
+		if m == nil {
+			return "", fmt.Errorf("invalid time value: %s", value)
+		}
+		h, _ := strconv.Atoi(m[1])
+		mi, _ := strconv.Atoi(m[2])
+		sec, _ := strconv.Atoi(m[3])
+		units, _ = strconv.Atoi(m[4])
+		seconds = h*3600 + mi*60 + sec
+	}
+	tc, err := NewTimecode(float64(tcr))
+	if err != nil {
+		return "", err
+	}
+	tc.SetFrames(seconds*tcr + int(math.Round(float64(units)*float64(tcr)/rate)))
+	return tc.GetTimeCode(), nil
+}
@@ -0,0 +1,185 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEditUnitsToFrames(t *testing.T) {
+	for _, tc := range []struct {
+		value, editRate, timecodeRate, want string
+	}{
+		{"00:00:01:24", "48 1", "24", "00:00:01:12"},
+		{"00:01:00:47", "48 1", "24", "00:01:01:00"},
+		{"72", "48 1", "24", "00:00:01:12"},
+		{"00:00:02:00", "24000 1001", "24", "00:00:02:00"},
+		{"00:00:00:125", "250 1", "25", "00:00:00:13"},
+		{"00:00:03:12", "25 1", "", "00:00:03:12"},
+		{"50", "25 1", "", "00:00:02:00"},
+		{"00:00:01:12", "24000 1001", "", "00:00:01:12"},
+	} {
+		got, err := editUnitsToFrames(tc.value, tc.editRate, tc.timecodeRate)
+		if err != nil || got != tc.want {
+			t.Errorf("editUnitsToFrames(%q, %q, %q) = %q, %v, want %q", tc.value, tc.editRate, tc.timecodeRate, got, err, tc.want)
+		}
+	}
+	for _, tc := range [][3]string{
+		{"00:00:01:00", "", "24"},
+		{"00:00:01:00", "", ""},
+		{"00:00:01:00", "24 1", "0"},
+		{"00:00:01:00", "24 1", "abc"},
+		{"1.5", "24 1", "24"},
+		{"00:01:00", "24 1", "24"},
+	} {
+		if got, err := editUnitsToFrames(tc[0], tc[1], tc[2]); err == nil {
+			t.Errorf("editUnitsToFrames(%q, %q, %q) = %q, want an error", tc[0], tc[1], tc[2], got)
+		}
+	}
+}
+
+// document2007 is an ST 428-7:2007 document with renamed elements and attributes, timed in edit
+// units of a 48 fps EditRate.
+const document2007 = `<?xml version="1.0" encoding="UTF-8"?>
+<SubtitleReel xmlns="http://www.smpte-ra.org/schemas/428-7/2007/DCST">
+  <ID>urn:uuid:5d2740ae-25ab-428f-b5a2-6cd5287e5336</ID>
+  <ContentTitleText>Upgrade</ContentTitleText>
+  <IssueDate>2020-11-03T00:00:00+00:00</IssueDate>
+  <ReelNumber>1</ReelNumber>
+  <Language>en</Language>
+  <EditRate>48 1</EditRate>
+  <TimecodeRate>24</TimecodeRate>
+  <StartTime>00:00:00:00</StartTime>
+  <LoadFont Id="Font1">urn:uuid:232c45d8-fde8-4e5e-86b9-86e96354daf3</LoadFont>
+  <SubtitleList>
+    <Font Id="Font1">
+      <Subtitle SpotNumber="1" TimeIn="00:00:01:24" TimeOut="144" FadeUpTime="00:00:00:04" FadeDownTime="4">
+        <Text HAlign="left" HPosition="10" VAlign="bottom" VPosition="8">Upgraded</Text>
+      </Subtitle>
+    </Font>
+  </SubtitleList>
+</SubtitleReel>`
+
+func TestUpgrade2007(t *testing.T) {
+	deterministic(t)
+	s, findings, err := Upgrade2007(strings.NewReader(document2007), DCST2014)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.XMLName.Space != DCST2014 || s.TimeCodeRate != "24" || s.ContentTitleText != "Upgrade" {
+		t.Errorf("got namespace %s, TimeCodeRate %q and title %q", s.XMLName.Space, s.TimeCodeRate, s.ContentTitleText)
+	}
+	if len(s.LoadFont) != 1 || s.LoadFont[0].ID != "Font1" {
+		t.Errorf("got LoadFont %+v, want ID Font1", s.LoadFont)
+	}
+	subs := subtitles(s)
+	if len(subs) != 1 {
+		t.Fatalf("got %d subtitles, want 1", len(subs))
+	}
+	sub := subs[0]
+	got := []string{sub.TimeIn, sub.TimeOut, sub.FadeUpTime, sub.FadeDownTime}
+	if want := []string{"00:00:01:12", "00:00:03:00", "00:00:00:02", "00:00:00:02"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got times %v, want %v", got, want)
+	}
+	text := sub.texts()
+	if len(text) != 1 {
+		t.Fatalf("got %d Text elements, want 1", len(text))
+	}
+	got = []string{text[0].Halign, text[0].Hposition, text[0].Valign, text[0].Vposition}
+	if want := []string{"left", "10", "bottom", "8"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got Text position %v, want %v", got, want)
+	}
+	if len(findings) != 1 || findings[0].Location != "DisplayType" {
+		t.Errorf("got findings %v, want a missing DisplayType", findings)
+	}
+}
+
+func TestUpgrade2007DefaultsTimeCodeRate(t *testing.T) {
+	deterministic(t)
+	doc := strings.Replace(document2007, "  <TimecodeRate>24</TimecodeRate>\n", "", 1)
+	doc = strings.Replace(doc, "48 1", "25 1", 1)
+	s, findings, err := Upgrade2007(strings.NewReader(doc), DCST2010)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.TimeCodeRate != "25" {
+		t.Errorf("got TimeCodeRate %q, want the EditRate 25", s.TimeCodeRate)
+	}
+	if sub := subtitles(s)[0]; sub.TimeIn != "00:00:01:24" || sub.TimeOut != "00:00:05:19" {
+		t.Errorf("got TimeIn %s and TimeOut %s", sub.TimeIn, sub.TimeOut)
+	}
+	var locations []string
+	for _, f := range findings {
+		locations = append(locations, f.Location)
+	}
+	if want := []string{"DisplayType", "TimeCodeRate"}; !reflect.DeepEqual(locations, want) {
+		t.Errorf("got findings at %v, want %v", locations, want)
+	}
+}
+
+func TestUpgrade2007Errors(t *testing.T) {
+	for name, doc := range map[string]string{
+		"2010 namespace": strings.Replace(document2007, "2007/DCST", "2010/DCST", 1),
+		"no EditRate":    strings.Replace(document2007, "<EditRate>48 1</EditRate>", "", 1),
+		"invalid time":   strings.Replace(document2007, `TimeOut="144"`, `TimeOut="1:2"`, 1),
+		"malformed":      document2007[:len(document2007)/2],
+	} {
+		if _, _, err := Upgrade2007(strings.NewReader(doc), DCST2014); err == nil {
+			t.Errorf("%s: Upgrade2007 returned no error", name)
+		}
+	}
+	if _, _, err := Upgrade2007(strings.NewReader(document2007), dcst2007); err == nil {
+		t.Error("Upgrade2007 accepted a 2007 target namespace")
+	}
+}
+
+func TestConvertTo2010Findings(t *testing.T) {
+	deterministic(t)
+	_, findings, err := ConvertFile("testdata/full.xml", DCST2010, TextImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range findings {
+		if f.Severity != SeverityWarning {
+			t.Errorf("%s: got severity %v, want a warning", f.Location, f.Severity)
+		}
+		got = append(got, f.Location+": "+f.Message)
+	}
+	want := []string{
+		"LoadVariableZ VariableZ1: dropped LoadVariableZ",
+		"Subtitle 1: dropped Text Zposition -1.5",
+		"Subtitle 2: dropped Text VariableZ VariableZ1",
+		`Subtitle 2: dropped Ruby annotation "かんじ" of "漢字"`,
+		"Subtitle 2: replaced Space with a space character",
+		`Subtitle 2: dropped HGroup of "12"`,
+		`Subtitle 2: dropped Rotate of "A"`,
+		"Subtitle 3: dropped Image Zposition 1.0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got findings\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
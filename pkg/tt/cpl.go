@@ -0,0 +1,247 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jack-watts/empty-tt/pkg/mxf"
+)
+
+// CPL reel asset namespaces of ST 429-7 and ST 429-12.
+const (
+	cplNamespace = "http://www.smpte-ra.org/schemas/429-7/2006/CPL"
+	ccNamespace  = "http://www.smpte-ra.org/schemas/429-12/2008/TT"
+)
+
+// File names of the packaging documents written by writeComposition.
+const (
+	cplPrefix    = "CPL_"
+	pklPrefix    = "PKL_"
+	assetMapName = "ASSETMAP.xml"
+	volIndexName = "VOLINDEX.xml"
+	// packageCreator is the Issuer and Creator of the packaging documents.
+	packageCreator = "empty-tt"
+)
+
+// ReelAsset is a MainSubtitle or ClosedCaption reel asset of a composition playlist.
+type ReelAsset struct {
+	XMLName           xml.Name
+	ID                string `xml:"Id"`
+	AnnotationText    string `xml:"AnnotationText,omitempty"`
+	EditRate          string `xml:"EditRate"`
+	IntrinsicDuration int64  `xml:"IntrinsicDuration"`
+	EntryPoint        int64  `xml:"EntryPoint"`
+	Duration          int64  `xml:"Duration"`
+	KeyID             string `xml:"KeyId,omitempty"`
+	Hash              string `xml:"Hash"`
+	Language          string `xml:"Language,omitempty"`
+}
+
+// NewReelAsset returns the CPL reel asset of a timed text track file. Closed caption track files,
+// identified by their DisplayType or, when encrypted, by their _cap.mxf file name suffix, are
+// written as a ST 429-12 ClosedCaption asset rather than a MainSubtitle asset. The keyID is set
+// for encrypted track files.
+func NewReelAsset(filename, keyID string) (*ReelAsset, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	t, err := mxf.Open(filename, nil)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum(data)
+	a := &ReelAsset{
+		XMLName:           xml.Name{Space: cplNamespace, Local: "MainSubtitle"},
+		ID:                urn + t.AssetUUID,
+		EditRate:          t.Descriptor.EditRate,
+		IntrinsicDuration: t.Descriptor.ContainerDuration,
+		Duration:          t.Descriptor.ContainerDuration,
+		Hash:              base64.StdEncoding.EncodeToString(sum[:]),
+	}
+	if keyID != "" {
+		a.KeyID = urn + strings.TrimPrefix(keyID, urn)
+	}
+	closedCaption := strings.HasSuffix(filename, "_cap.mxf")
+	if t.XML != nil {
+		var s *SubtitleReel
+		if err := xml.Unmarshal(t.XML, &s); err != nil {
+			return nil, err
+		}
+		a.AnnotationText = s.ContentTitleText
+		a.Language = s.Language
+		closedCaption = s.DisplayType == "ClosedCaption"
+	}
+	if closedCaption {
+		a.XMLName = xml.Name{Space: ccNamespace, Local: "ClosedCaption"}
+	}
+	return a, nil
+}
+
+// compositionPlaylist is an ST 429-7 composition playlist of a single reel.
+type compositionPlaylist struct {
+	XMLName          xml.Name `xml:"http://www.smpte-ra.org/schemas/429-7/2006/CPL CompositionPlaylist"`
+	ID               string   `xml:"Id"`
+	AnnotationText   string   `xml:"AnnotationText"`
+	IssueDate        string   `xml:"IssueDate"`
+	Issuer           string   `xml:"Issuer"`
+	Creator          string   `xml:"Creator"`
+	ContentTitleText string   `xml:"ContentTitleText"`
+	ContentKind      string   `xml:"ContentKind"`
+	ReelID           string   `xml:"ReelList>Reel>Id"`
+	AssetList        struct {
+		Assets []*ReelAsset `xml:",any"`
+	} `xml:"ReelList>Reel>AssetList"`
+}
+
+// packingList is an ST 429-8 packing list.
+type packingList struct {
+	XMLName        xml.Name    `xml:"http://www.smpte-ra.org/schemas/429-8/2007/PKL PackingList"`
+	ID             string      `xml:"Id"`
+	AnnotationText string      `xml:"AnnotationText"`
+	IssueDate      string      `xml:"IssueDate"`
+	Issuer         string      `xml:"Issuer"`
+	Creator        string      `xml:"Creator"`
+	Assets         []*pklAsset `xml:"AssetList>Asset"`
+}
+
+// pklAsset is an asset of a packing list.
+type pklAsset struct {
+	ID   string `xml:"Id"`
+	Hash string `xml:"Hash"`
+	Size int64  `xml:"Size"`
+	Type string `xml:"Type"`
+}
+
+// assetMap is an ST 429-9 asset map of a single volume.
+type assetMap struct {
+	XMLName     xml.Name   `xml:"http://www.smpte-ra.org/schemas/429-9/2007/AM AssetMap"`
+	ID          string     `xml:"Id"`
+	Creator     string     `xml:"Creator"`
+	VolumeCount int        `xml:"VolumeCount"`
+	IssueDate   string     `xml:"IssueDate"`
+	Issuer      string     `xml:"Issuer"`
+	Assets      []*amAsset `xml:"AssetList>Asset"`
+}
+
+// amAsset is an asset of an asset map, stored in a single chunk.
+type amAsset struct {
+	ID          string `xml:"Id"`
+	PackingList string `xml:"PackingList,omitempty"`
+	Path        string `xml:"ChunkList>Chunk>Path"`
+	VolumeIndex int    `xml:"ChunkList>Chunk>VolumeIndex"`
+	Offset      int64  `xml:"ChunkList>Chunk>Offset"`
+	Length      int64  `xml:"ChunkList>Chunk>Length"`
+}
+
+// volumeIndex is the ST 429-9 volume index of the first volume.
+type volumeIndex struct {
+	XMLName xml.Name `xml:"http://www.smpte-ra.org/schemas/429-9/2007/AM VolumeIndex"`
+	Index   int      `xml:"Index"`
+}
+
+// writeComposition writes a CPL of a single reel holding the track file of a Result, a PKL of
+// the CPL and the track file, and the ASSETMAP and VOLINDEX of the output directory, and
+// returns their paths. The CPL is a supplemental one, it holds no picture or sound assets.
+func writeComposition(res *Result, title, output string) ([]string, error) {
+	asset, err := NewReelAsset(res.MXF, res.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	date := issueDate()
+	cpl := &compositionPlaylist{
+		ID:               urn + uuidType4(),
+		AnnotationText:   title,
+		IssueDate:        date,
+		Issuer:           packageCreator,
+		Creator:          packageCreator,
+		ContentTitleText: title,
+		ContentKind:      "test",
+		ReelID:           urn + uuidType4(),
+	}
+	cpl.AssetList.Assets = []*ReelAsset{asset}
+	pkl := &packingList{
+		ID:             urn + uuidType4(),
+		AnnotationText: title,
+		IssueDate:      date,
+		Issuer:         packageCreator,
+		Creator:        packageCreator,
+	}
+	am := &assetMap{
+		ID:          urn + uuidType4(),
+		Creator:     packageCreator,
+		VolumeCount: 1,
+		IssueDate:   date,
+		Issuer:      packageCreator,
+	}
+	var paths []string
+	// write encodes a document to the output directory and returns its encoding.
+	write := func(name string, v interface{}) ([]byte, error) {
+		enc, err := xml.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		data := append([]byte(xml.Header), append(enc, '\n')...)
+		filename := filepath.Join(output, name)
+		paths = append(paths, filename)
+		return data, ioutil.WriteFile(filename, data, 0644)
+	}
+	// add lists an asset in the PKL and the ASSETMAP.
+	add := func(id, name, hash, mimeType string, size int64) {
+		pkl.Assets = append(pkl.Assets, &pklAsset{ID: id, Hash: hash, Size: size, Type: mimeType})
+		am.Assets = append(am.Assets, &amAsset{ID: id, Path: name, VolumeIndex: 1, Length: size})
+	}
+
+	name := cplPrefix + strings.TrimPrefix(cpl.ID, urn) + xmlFileExt
+	data, err := write(name, cpl)
+	if err != nil {
+		return nil, err
+	}
+	add(cpl.ID, name, hashData(data).SHA1, "text/xml", int64(len(data)))
+	fi, err := os.Stat(res.MXF)
+	if err != nil {
+		return nil, err
+	}
+	add(asset.ID, filepath.Base(res.MXF), asset.Hash, "application/mxf", fi.Size())
+
+	name = pklPrefix + strings.TrimPrefix(pkl.ID, urn) + xmlFileExt
+	if data, err = write(name, pkl); err != nil {
+		return nil, err
+	}
+	am.Assets = append(am.Assets, &amAsset{ID: pkl.ID, PackingList: "true", Path: name, VolumeIndex: 1, Length: int64(len(data))})
+	if _, err := write(assetMapName, am); err != nil {
+		return nil, err
+	}
+	if _, err := write(volIndexName, &volumeIndex{Index: 1}); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
@@ -0,0 +1,234 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defVariableZID is the ID of the LoadVariableZ element of generated documents.
+const defVariableZID = "VariableZ1"
+
+// DepthLimits are the stereoscopic comfort limits of a Zposition, in percent of the screen
+// width. Negative values place an element in front of the screen.
+type DepthLimits struct {
+	// Near is the smallest Zposition, the furthest in front of the screen.
+	Near float64
+	// Far is the largest Zposition, the furthest behind the screen.
+	Far float64
+}
+
+// DefaultDepthLimits keeps elements within 2% of screen width parallax of the screen plane.
+var DefaultDepthLimits = DepthLimits{Near: -2, Far: 2}
+
+// ZPoint is a single entry of a LoadVariableZ depth curve, a Zposition held for Count edit units.
+type ZPoint struct {
+	Z     float64
+	Count int
+}
+
+// ParseVariableZ parses the content of a LoadVariableZ element, a whitespace separated list of
+// Zposition values that are each optionally followed by ":" and a count of edit units.
+func ParseVariableZ(s string) ([]ZPoint, error) {
+	var points []ZPoint
+	for _, f := range strings.Fields(s) {
+		p := ZPoint{Count: 1}
+		z := f
+		if i := strings.Index(f, ":"); i >= 0 {
+			z = f[:i]
+			n, err := strconv.Atoi(f[i+1:])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid edit unit count: %q", f)
+			}
+			p.Count = n
+		}
+		v, err := strconv.ParseFloat(z, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Zposition: %q", f)
+		}
+		p.Z = v
+		points = append(points, p)
+	}
+	if len(points) == 0 {
+		return nil, errors.New("empty VariableZ")
+	}
+	return points, nil
+}
+
+// curveLength returns the number of edit units covered by a depth curve.
+func curveLength(points []ZPoint) int {
+	n := 0
+	for _, p := range points {
+		n += p.Count
+	}
+	return n
+}
+
+// variableZCurves returns the depth curves of a SubtitleReel by LoadVariableZ ID. Malformed
+// curves are left out.
+func variableZCurves(s *SubtitleReel) map[string][]ZPoint {
+	curves := make(map[string][]ZPoint)
+	for _, l := range s.LoadVariableZ {
+		if points, err := ParseVariableZ(l.Positions); err == nil {
+			curves[l.ID] = points
+		}
+	}
+	return curves
+}
+
+// depthAt returns the Zposition of an element at the given edit unit offset from the TimeIn of
+// its Subtitle. A VariableZ reference takes precedence over Zposition and the last entry of a
+// curve is held once it runs out.
+func depthAt(zposition, variableZ string, curves map[string][]ZPoint, offset int) float64 {
+	if points, ok := curves[variableZ]; ok {
+		for _, p := range points {
+			if offset < p.Count {
+				return p.Z
+			}
+			offset -= p.Count
+		}
+		return points[len(points)-1].Z
+	}
+	return getFloat(zposition)
+}
+
+// setDepth applies a Zposition and a VariableZ depth curve to every Text and Image element of a
+// generated SubtitleReel. The curve is loaded as a LoadVariableZ element and referenced by ID.
+func setDepth(s *SubtitleReel, zposition, variableZ string) error {
+	if zposition == "" && variableZ == "" {
+		return nil
+	}
+	if zposition != "" {
+		if _, err := strconv.ParseFloat(zposition, 64); err != nil {
+			return fmt.Errorf("invalid Zposition: %q", zposition)
+		}
+	}
+	ref := ""
+	if variableZ != "" {
+		if _, err := ParseVariableZ(variableZ); err != nil {
+			return err
+		}
+		ref = defVariableZID
+		s.LoadVariableZ = append(s.LoadVariableZ, &LoadVariableZ{ID: ref, Positions: variableZ})
+	}
+	for _, sub := range subtitles(s) {
+		for _, t := range sub.texts() {
+			t.Zposition, t.VariableZ = zposition, ref
+		}
+		for _, i := range sub.images() {
+			i.Zposition, i.VariableZ = zposition, ref
+		}
+	}
+	return nil
+}
+
+// ValidateDepth checks the Zposition and VariableZ of every Text and Image element, and the
+// LoadVariableZ curves they reference, against the comfort limits. LoadVariableZ and VariableZ
+// are 2014 constructs and are reported in documents of any other namespace.
+func ValidateDepth(s *SubtitleReel, limits DepthLimits) []Finding {
+	var findings findingList
+	report := findings.add
+	ns := namespace(s)
+	version2014 := xmlNsSubtitle[ns] == dcst2014
+	curves := make(map[string][]ZPoint)
+	used := make(map[string]bool)
+	for _, l := range s.LoadVariableZ {
+		loc := "LoadVariableZ " + l.ID
+		if !version2014 {
+			report(SeverityError, loc, "LoadVariableZ is not permitted in a %s document", ns)
+		}
+		if l.ID == "" {
+			report(SeverityError, loc, "LoadVariableZ has no ID")
+			continue
+		}
+		if _, ok := curves[l.ID]; ok {
+			report(SeverityError, loc, "duplicate LoadVariableZ ID")
+			continue
+		}
+		points, err := ParseVariableZ(l.Positions)
+		if err != nil {
+			report(SeverityError, loc, "%s", err)
+			continue
+		}
+		curves[l.ID] = points
+		for _, p := range points {
+			if p.Z < limits.Near || p.Z > limits.Far {
+				report(SeverityError, loc, "Zposition %g is outside the comfort limits %g to %g", p.Z, limits.Near, limits.Far)
+				break
+			}
+		}
+	}
+
+	rate := getEditRate(s.EditRate)
+	for i, sub := range subtitles(s) {
+		loc := subtitleLocation(i, sub)
+		duration := 0
+		in, errIn := ParseTimecode(sub.TimeIn, rate)
+		out, errOut := ParseTimecode(sub.TimeOut, rate)
+		if errIn == nil && errOut == nil {
+			duration = out.Frames() - in.Frames()
+		}
+		check := func(element, zposition, variableZ string) {
+			if zposition != "" {
+				z, err := strconv.ParseFloat(zposition, 64)
+				switch {
+				case err != nil:
+					report(SeverityError, loc, "%s Zposition %q is not a decimal", element, zposition)
+				case z < limits.Near || z > limits.Far:
+					report(SeverityError, loc, "%s Zposition %g is outside the comfort limits %g to %g", element, z, limits.Near, limits.Far)
+				}
+			}
+			if variableZ == "" {
+				return
+			}
+			used[variableZ] = true
+			if !version2014 {
+				report(SeverityError, loc, "VariableZ is not permitted in a %s document", ns)
+			}
+			points, ok := curves[variableZ]
+			if !ok {
+				report(SeverityError, loc, "%s VariableZ %q references no LoadVariableZ", element, variableZ)
+				return
+			}
+			if n := curveLength(points); n < duration {
+				report(SeverityWarning, loc, "%s VariableZ %q covers %d of %d edit units, its last Zposition is held", element, variableZ, n, duration)
+			}
+		}
+		for _, t := range sub.texts() {
+			check("Text", t.Zposition, t.VariableZ)
+		}
+		for _, img := range sub.images() {
+			check("Image", img.Zposition, img.VariableZ)
+		}
+	}
+	for _, l := range s.LoadVariableZ {
+		if _, ok := curves[l.ID]; ok && !used[l.ID] {
+			report(SeverityWarning, "LoadVariableZ "+l.ID, "LoadVariableZ is not referenced")
+		}
+	}
+	return findings
+}
@@ -0,0 +1,135 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseVariableZ(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		want []ZPoint
+	}{
+		{"-1.0", []ZPoint{{-1, 1}}},
+		{"-1.0:24 -1.5:24 -2.0", []ZPoint{{-1, 24}, {-1.5, 24}, {-2, 1}}},
+		{"  0.5:2\n\t1e-1 ", []ZPoint{{0.5, 2}, {0.1, 1}}},
+	} {
+		got, err := ParseVariableZ(tc.s)
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseVariableZ(%q) = %v, %v, want %v", tc.s, got, err, tc.want)
+		}
+	}
+	for _, s := range []string{"", " \n ", "abc", "-1.0:0", "-1.0:-2", "-1.0:x", "-1.0:", ":24", "1:2:3"} {
+		if got, err := ParseVariableZ(s); err == nil {
+			t.Errorf("ParseVariableZ(%q) = %v, want an error", s, got)
+		}
+	}
+}
+
+func TestDepthAt(t *testing.T) {
+	curves := map[string][]ZPoint{"VariableZ1": {{-1, 2}, {-1.5, 3}}}
+	if n := curveLength(curves["VariableZ1"]); n != 5 {
+		t.Errorf("curveLength = %d, want 5", n)
+	}
+	for _, tc := range []struct {
+		zposition, variableZ string
+		offset               int
+		want                 float64
+	}{
+		{"0.5", "", 10, 0.5},
+		{"0.5", "VariableZ1", 0, -1},
+		{"", "VariableZ1", 1, -1},
+		{"", "VariableZ1", 2, -1.5},
+		{"", "VariableZ1", 4, -1.5},
+		{"", "VariableZ1", 100, -1.5},
+		{"0.5", "VariableZ2", 0, 0.5},
+	} {
+		if got := depthAt(tc.zposition, tc.variableZ, curves, tc.offset); got != tc.want {
+			t.Errorf("depthAt(%q, %q, %d) = %g, want %g", tc.zposition, tc.variableZ, tc.offset, got, tc.want)
+		}
+	}
+}
+
+func TestValidateDepth(t *testing.T) {
+	// the second Subtitle lasts 48 edit units at 24 fps.
+	subs := `<Subtitle SpotNumber="1" TimeIn="00:00:01:00" TimeOut="00:00:02:00"><Text Zposition="%s">One</Text></Subtitle>` +
+		`<Subtitle SpotNumber="2" TimeIn="00:00:03:00" TimeOut="00:00:05:00"><Text VariableZ="%s">Two</Text>` +
+		`<Image Zposition="-2">urn:uuid:0b1c2d3e-4f5a-4b6c-9d7e-8f9a0b1c2d3e</Image></Subtitle>`
+	for _, tc := range []struct {
+		name                 string
+		zposition, variableZ string
+		load                 []*LoadVariableZ
+		want                 []string
+	}{
+		{"valid", "1.5", "VariableZ1", []*LoadVariableZ{{ID: "VariableZ1", Positions: "-1.0:24 -1.5:24"}}, nil},
+		{"Zposition", "2.5", "", nil,
+			[]string{"error: Subtitle 1: Text Zposition 2.5 is outside the comfort limits -2 to 2"}},
+		{"invalid Zposition", "near", "", nil,
+			[]string{`error: Subtitle 1: Text Zposition "near" is not a decimal`}},
+		{"unresolved VariableZ", "", "VariableZ2", []*LoadVariableZ{{ID: "VariableZ1", Positions: "-1.0:48"}}, []string{
+			`error: Subtitle 2: Text VariableZ "VariableZ2" references no LoadVariableZ`,
+			"warning: LoadVariableZ VariableZ1: LoadVariableZ is not referenced",
+		}},
+		{"short curve", "", "VariableZ1", []*LoadVariableZ{{ID: "VariableZ1", Positions: "-1.0:24 -1.5:12"}},
+			[]string{`warning: Subtitle 2: Text VariableZ "VariableZ1" covers 36 of 48 edit units, its last Zposition is held`}},
+		{"curve limits", "", "VariableZ1", []*LoadVariableZ{{ID: "VariableZ1", Positions: "-1.0:24 -3.0:24 3.0"}},
+			[]string{"error: LoadVariableZ VariableZ1: Zposition -3 is outside the comfort limits -2 to 2"}},
+		{"malformed curve", "", "", []*LoadVariableZ{{ID: "VariableZ1", Positions: "-1.0:0"}},
+			[]string{`error: LoadVariableZ VariableZ1: invalid edit unit count: "-1.0:0"`}},
+		{"duplicate ID", "", "VariableZ1", []*LoadVariableZ{{ID: "VariableZ1", Positions: "0:48"}, {ID: "VariableZ1", Positions: "1:48"}},
+			[]string{"error: LoadVariableZ VariableZ1: duplicate LoadVariableZ ID"}},
+		{"no ID", "", "", []*LoadVariableZ{{Positions: "0:48"}},
+			[]string{"error: LoadVariableZ : LoadVariableZ has no ID"}},
+	} {
+		s := diffReel(t, "en", fmt.Sprintf(subs, tc.zposition, tc.variableZ))
+		s.LoadVariableZ = tc.load
+		var got []string
+		for _, f := range ValidateDepth(s, DefaultDepthLimits) {
+			got = append(got, f.String())
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got findings %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	// VariableZ and LoadVariableZ are 2014 constructs.
+	s := diffReel(t, "en", fmt.Sprintf(subs, "", "VariableZ1"))
+	s.XMLName.Space = DCST2010
+	s.LoadVariableZ = []*LoadVariableZ{{ID: "VariableZ1", Positions: "-1.0:48"}}
+	var got []string
+	for _, f := range ValidateDepth(s, DepthLimits{Near: -1, Far: 1}) {
+		got = append(got, f.String())
+	}
+	want := []string{
+		"error: LoadVariableZ VariableZ1: LoadVariableZ is not permitted in a " + DCST2010 + " document",
+		"error: Subtitle 2: VariableZ is not permitted in a " + DCST2010 + " document",
+		"error: Subtitle 2: Image Zposition -2 is outside the comfort limits -1 to 1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("2010 document: got findings %q, want %q", got, want)
+	}
+}
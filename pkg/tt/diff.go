@@ -0,0 +1,420 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see <http://www.gnu.org/licenses/>.*/
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Change kinds reported by Diff.
+const (
+	Added    = "added"
+	Removed  = "removed"
+	Modified = "modified"
+)
+
+// Change describes a single difference between two values of the same field.
+type Change struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+// EventChange describes an added, removed or modified Subtitle event.
+type EventChange struct {
+	Kind       string   `json:"kind"`
+	SpotNumber string   `json:"spotNumber,omitempty"`
+	TimeIn     string   `json:"timeIn"`
+	TimeOut    string   `json:"timeOut"`
+	Changes    []Change `json:"changes,omitempty"`
+}
+
+// DiffReport is the result of comparing two SubtitleReel documents.
+type DiffReport struct {
+	Header []Change      `json:"header,omitempty"`
+	Events []EventChange `json:"events,omitempty"`
+}
+
+// Empty reports whether no differences were found.
+func (d *DiffReport) Empty() bool {
+	return len(d.Header) == 0 && len(d.Events) == 0
+}
+
+// String returns a human readable representation of the report.
+func (d *DiffReport) String() string {
+	var b strings.Builder
+	for _, c := range d.Header {
+		fmt.Fprintf(&b, "~ %s: %q -> %q\n", c.Field, c.Old, c.New)
+	}
+	for _, e := range d.Events {
+		sign := "~"
+		switch e.Kind {
+		case Added:
+			sign = "+"
+		case Removed:
+			sign = "-"
+		}
+		fmt.Fprintf(&b, "%s Subtitle", sign)
+		if e.SpotNumber != "" {
+			fmt.Fprintf(&b, " %s", e.SpotNumber)
+		}
+		fmt.Fprintf(&b, " [%s - %s]\n", e.TimeIn, e.TimeOut)
+		for _, c := range e.Changes {
+			fmt.Fprintf(&b, "    %s: %q -> %q\n", c.Field, c.Old, c.New)
+		}
+	}
+	return b.String()
+}
+
+// DiffFiles parses two ST 428-7 XML documents and compares them using Diff.
+func DiffFiles(a, b string, tolerance int) (*DiffReport, error) {
+	sa, err := parseXML(a)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", a, err)
+	}
+	sb, err := parseXML(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b, err)
+	}
+	return Diff(sa, sb, tolerance), nil
+}
+
+// Diff compares two SubtitleReel documents. Subtitle events are matched by SpotNumber
+// where both events carry one, otherwise by the nearest TimeIn within tolerance frames.
+// A negative tolerance defaults to one second at the EditRate of a.
+func Diff(a, b *SubtitleReel, tolerance int) *DiffReport {
+	d := &DiffReport{}
+	d.Header = diffHeader(a, b)
+
+	rateA := getEditRate(a.EditRate)
+	rateB := getEditRate(b.EditRate)
+	if tolerance < 0 {
+		tolerance = int(rateA + 0.5)
+	}
+	evA := eventList(a, rateA)
+	evB := eventList(b, rateB)
+	matched := make([]bool, len(evB))
+
+	// pair events that share a SpotNumber first.
+	pairs := make(map[int]int)
+	spots := make(map[string]int)
+	for j, e := range evB {
+		if e.sub.SpotNumber != "" {
+			spots[e.sub.SpotNumber] = j
+		}
+	}
+	for i, e := range evA {
+		if e.sub.SpotNumber == "" {
+			continue
+		}
+		if j, ok := spots[e.sub.SpotNumber]; ok && !matched[j] {
+			pairs[i] = j
+			matched[j] = true
+		}
+	}
+	// pair the remaining events by timing proximity.
+	for i, e := range evA {
+		if _, ok := pairs[i]; ok {
+			continue
+		}
+		best, bestDelta := -1, tolerance+1
+		for j, f := range evB {
+			if matched[j] || (e.sub.SpotNumber != "" && f.sub.SpotNumber != "") {
+				continue
+			}
+			delta := abs(e.in - f.in)
+			if delta < bestDelta {
+				best, bestDelta = j, delta
+			}
+		}
+		if best >= 0 {
+			pairs[i] = best
+			matched[best] = true
+		}
+	}
+
+	for i, e := range evA {
+		j, ok := pairs[i]
+		if !ok {
+			d.Events = append(d.Events, newEventChange(Removed, e.sub))
+			continue
+		}
+		changes := diffField(nil, "SubtitleList.Font", e.fonts, evB[j].fonts)
+		if changes = append(changes, diffSubtitle(e.sub, evB[j].sub)...); len(changes) > 0 {
+			ec := newEventChange(Modified, evB[j].sub)
+			ec.Changes = changes
+			d.Events = append(d.Events, ec)
+		}
+	}
+	for j, f := range evB {
+		if !matched[j] {
+			d.Events = append(d.Events, newEventChange(Added, f.sub))
+		}
+	}
+	sort.SliceStable(d.Events, func(i, j int) bool {
+		return d.Events[i].TimeIn < d.Events[j].TimeIn
+	})
+	return d
+}
+
+// event associates a Subtitle with its TimeIn frame count and the Font elements enclosing it.
+type event struct {
+	sub   *Subtitle
+	in    int
+	fonts string
+}
+
+// eventList returns every Subtitle of a SubtitleReel with its TimeIn resolved to frames.
+func eventList(s *SubtitleReel, rate float64) []event {
+	var ev []event
+	walkSubtitles(s, func(sub *Subtitle, fonts []*Font) {
+		var f []string
+		for _, font := range fonts {
+			f = append(f, fontString(font))
+		}
+		e := event{sub: sub, fonts: strings.Join(f, " > ")}
+		if tc, err := ParseTimecode(sub.TimeIn, rate); err == nil {
+			e.in = tc.Frames()
+		}
+		ev = append(ev, e)
+	})
+	return ev
+}
+
+// newEventChange returns an EventChange of the given kind for a Subtitle.
+func newEventChange(kind string, s *Subtitle) EventChange {
+	return EventChange{
+		Kind:       kind,
+		SpotNumber: s.SpotNumber,
+		TimeIn:     s.TimeIn,
+		TimeOut:    s.TimeOut,
+	}
+}
+
+// diffHeader compares the global properties of two SubtitleReel documents.
+func diffHeader(a, b *SubtitleReel) []Change {
+	var c []Change
+	c = diffField(c, "Namespace", a.XMLName.Space, b.XMLName.Space)
+	c = diffField(c, "ContentTitleText", a.ContentTitleText, b.ContentTitleText)
+	c = diffField(c, "ReelNumber", fmt.Sprint(a.ReelNumber), fmt.Sprint(b.ReelNumber))
+	c = diffField(c, "Language", a.Language, b.Language)
+	c = diffField(c, "EditRate", a.EditRate, b.EditRate)
+	c = diffField(c, "TimeCodeRate", a.TimeCodeRate, b.TimeCodeRate)
+	c = diffField(c, "StartTime", a.StartTime, b.StartTime)
+	c = diffField(c, "DisplayType", a.DisplayType, b.DisplayType)
+	c = diffField(c, "LoadFont", loadFontString(a.LoadFont), loadFontString(b.LoadFont))
+	c = diffField(c, "LoadVariableZ", loadVariableZString(a.LoadVariableZ), loadVariableZString(b.LoadVariableZ))
+	return c
+}
+
+// diffSubtitle compares the timing, text, style and position of two Subtitle events.
+func diffSubtitle(a, b *Subtitle) []Change {
+	var c []Change
+	c = diffField(c, "TimeIn", a.TimeIn, b.TimeIn)
+	c = diffField(c, "TimeOut", a.TimeOut, b.TimeOut)
+	c = diffField(c, "FadeUpTime", a.FadeUpTime, b.FadeUpTime)
+	c = diffField(c, "FadeDownTime", a.FadeDownTime, b.FadeDownTime)
+	c = diffField(c, "Font", nestedFontsString(a.Font), nestedFontsString(b.Font))
+	c = diffField(c, "Font.Text", nestedFontText(a.Font), nestedFontText(b.Font))
+
+	textsA, textsB := a.texts(), b.texts()
+	for i := 0; i < len(textsA) || i < len(textsB); i++ {
+		var ta, tb *Text
+		if i < len(textsA) {
+			ta = textsA[i]
+		}
+		if i < len(textsB) {
+			tb = textsB[i]
+		}
+		field := fmt.Sprintf("Text[%d]", i+1)
+		c = diffField(c, field, textContent(ta), textContent(tb))
+		c = diffField(c, field+".Position", textPosition(ta), textPosition(tb))
+		var fa, fb *NestedFont
+		var ra, rb string
+		if ta != nil {
+			fa, ra = ta.firstFont(), rubyString(ta)
+		}
+		if tb != nil {
+			fb, rb = tb.firstFont(), rubyString(tb)
+		}
+		c = diffField(c, field+".Font", nestedFontString(fa), nestedFontString(fb))
+		c = diffField(c, field+".Ruby", ra, rb)
+	}
+
+	imagesA, imagesB := a.images(), b.images()
+	for i := 0; i < len(imagesA) || i < len(imagesB); i++ {
+		var ia, ib *Image
+		if i < len(imagesA) {
+			ia = imagesA[i]
+		}
+		if i < len(imagesB) {
+			ib = imagesB[i]
+		}
+		field := fmt.Sprintf("Image[%d]", i+1)
+		c = diffField(c, field, imageContent(ia), imageContent(ib))
+		c = diffField(c, field+".Position", imagePosition(ia), imagePosition(ib))
+	}
+	return c
+}
+
+// diffField appends a Change to c when old and new differ.
+func diffField(c []Change, field, old, new string) []Change {
+	if old == new {
+		return c
+	}
+	return append(c, Change{Field: field, Old: old, New: new})
+}
+
+// attrString formats a list of name/value pairs, omitting empty values.
+func attrString(kv ...string) string {
+	var s []string
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] != "" {
+			s = append(s, kv[i]+"="+kv[i+1])
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+// loadFontString returns a comparable representation of a list of LoadFont elements.
+func loadFontString(l []*LoadFont) string {
+	var s []string
+	for _, f := range l {
+		s = append(s, attrString("ID", f.ID, "Font", f.Font))
+	}
+	return strings.Join(s, ", ")
+}
+
+// loadVariableZString returns a comparable representation of a list of LoadVariableZ elements.
+func loadVariableZString(l []*LoadVariableZ) string {
+	var s []string
+	for _, z := range l {
+		s = append(s, attrString("ID", z.ID, "Positions", strings.Join(strings.Fields(z.Positions), " ")))
+	}
+	return strings.Join(s, ", ")
+}
+
+// fontString returns a comparable representation of the attributes of a Font element.
+func fontString(f *Font) string {
+	if f == nil {
+		return ""
+	}
+	return attrString("ID", f.ID, "Weight", f.Weight, "Size", f.Size, "Color", f.Color,
+		"Effect", f.Effect, "EffectColor", f.EffectColor, "EffectSize", f.EffectSize,
+		"Italic", f.Italic, "Underline", f.Underline, "AspectAdjust", f.AspectAdjust,
+		"Spacing", f.Spacing, "Feather", f.Feather)
+}
+
+// nestedFontString returns a comparable representation of the attributes of a NestedFont element.
+func nestedFontString(f *NestedFont) string {
+	if f == nil {
+		return ""
+	}
+	return attrString("ID", f.ID, "Weight", f.Weight, "Size", f.Size, "Color", f.Color,
+		"Effect", f.Effect, "EffectColor", f.EffectColor, "EffectSize", f.EffectSize,
+		"Italic", f.Italic, "Underline", f.Underline, "AspectAdjust", f.AspectAdjust,
+		"Spacing", f.Spacing, "Feather", f.Feather)
+}
+
+// nestedFontsString returns a comparable representation of the attributes of a list of
+// NestedFont elements and their Font children.
+func nestedFontsString(l []*NestedFont) string {
+	var s []string
+	walkNestedFonts(l, func(f *NestedFont) {
+		s = append(s, nestedFontString(f))
+	})
+	return strings.Join(s, ", ")
+}
+
+// nestedFontText returns the character data of a list of NestedFont elements and their Font
+// children.
+func nestedFontText(l []*NestedFont) string {
+	var b strings.Builder
+	walkNestedFonts(l, func(f *NestedFont) {
+		b.WriteString(f.Text)
+	})
+	return strings.TrimSpace(b.String())
+}
+
+// textContent returns the character data of a Text element including any nested Font run.
+func textContent(t *Text) string {
+	if t == nil {
+		return ""
+	}
+	return strings.TrimSpace(t.Content())
+}
+
+// rubyString returns a comparable representation of the Ruby runs of a Text element.
+func rubyString(t *Text) string {
+	var s []string
+	for _, r := range t.Runs {
+		if r.Ruby == nil {
+			continue
+		}
+		rt := &Rt{}
+		if r.Ruby.Rt != nil {
+			rt = r.Ruby.Rt
+		}
+		s = append(s, r.Ruby.Rb+"("+rt.Text+" "+attrString("Size", rt.Size, "Position", rt.Position,
+			"Offset", rt.Offset, "Spacing", rt.Spacing, "AspectAdjust", rt.AspectAdjust)+")")
+	}
+	return strings.Join(s, " ")
+}
+
+// textPosition returns a comparable representation of the position attributes of a Text element.
+func textPosition(t *Text) string {
+	if t == nil {
+		return ""
+	}
+	return attrString("Halign", t.Halign, "Hposition", t.Hposition, "Valign", t.Valign,
+		"Vposition", t.Vposition, "Direction", t.Direction, "Zposition", t.Zposition,
+		"VariableZ", t.VariableZ)
+}
+
+// imageContent returns the URN referenced by an Image element.
+func imageContent(i *Image) string {
+	if i == nil {
+		return ""
+	}
+	return strings.TrimSpace(i.Image)
+}
+
+// imagePosition returns a comparable representation of the position attributes of an Image element.
+func imagePosition(i *Image) string {
+	if i == nil {
+		return ""
+	}
+	return attrString("Halign", i.Halign, "Hposition", i.Hposition, "Valign", i.Valign,
+		"Vposition", i.Vposition, "Zposition", i.Zposition, "VariableZ", i.VariableZ)
+}
+
+// abs returns the absolute value of a given integer.
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
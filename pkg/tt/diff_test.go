@@ -0,0 +1,145 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// diffReel returns a 24 fps SubtitleReel holding the given Subtitle elements.
+func diffReel(t *testing.T, language, subtitles string) *SubtitleReel {
+	t.Helper()
+	doc := `<SubtitleReel xmlns="` + DCST2014 + `"><Id>urn:uuid:5d2740ae-25ab-428f-b5a2-6cd5287e5336</Id>` +
+		`<Language>` + language + `</Language><EditRate>24 1</EditRate><TimeCodeRate>24</TimeCodeRate>` +
+		`<SubtitleList>` + subtitles + `</SubtitleList></SubtitleReel>`
+	var s SubtitleReel
+	if err := xml.Unmarshal([]byte(doc), &s); err != nil {
+		t.Fatal(err)
+	}
+	return &s
+}
+
+// diffSub returns a Subtitle element holding a single line of text.
+func diffSub(spot, in, out, text string) string {
+	attr := ""
+	if spot != "" {
+		attr = ` SpotNumber="` + spot + `"`
+	}
+	return `<Subtitle` + attr + ` TimeIn="` + in + `" TimeOut="` + out + `"><Text Valign="bottom" Vposition="10">` +
+		text + `</Text></Subtitle>`
+}
+
+// eventKinds returns the kind and TimeIn of every event of a report.
+func eventKinds(d *DiffReport) []string {
+	var s []string
+	for _, e := range d.Events {
+		s = append(s, e.Kind+" "+e.TimeIn)
+	}
+	return s
+}
+
+func TestDiffIdentical(t *testing.T) {
+	subs := diffSub("1", "00:00:01:00", "00:00:02:00", "One") + diffSub("", "00:00:03:00", "00:00:04:00", "Two")
+	if d := Diff(diffReel(t, "en", subs), diffReel(t, "en", subs), -1); !d.Empty() {
+		t.Errorf("got differences between identical documents:\n%s", d)
+	}
+}
+
+func TestDiffMatchesSpotNumbers(t *testing.T) {
+	// a retimed event is matched by its SpotNumber however far it moved, and numbered events
+	// with different SpotNumbers are never matched by timing.
+	a := diffReel(t, "en", diffSub("1", "00:00:01:00", "00:00:02:00", "One")+
+		diffSub("2", "00:00:03:00", "00:00:04:00", "Two"))
+	b := diffReel(t, "en", diffSub("1", "00:00:10:00", "00:00:11:00", "One")+
+		diffSub("3", "00:00:03:00", "00:00:04:00", "Two"))
+	d := Diff(a, b, -1)
+	want := []string{"removed 00:00:03:00", "added 00:00:03:00", "modified 00:00:10:00"}
+	if got := eventKinds(d); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got events %v, want %v", got, want)
+	}
+	changes := d.Events[2].Changes
+	wantChanges := []Change{
+		{Field: "TimeIn", Old: "00:00:01:00", New: "00:00:10:00"},
+		{Field: "TimeOut", Old: "00:00:02:00", New: "00:00:11:00"},
+	}
+	if d.Events[2].SpotNumber != "1" || !reflect.DeepEqual(changes, wantChanges) {
+		t.Errorf("got Subtitle %s changes %v, want %v", d.Events[2].SpotNumber, changes, wantChanges)
+	}
+}
+
+func TestDiffMatchesTimeIn(t *testing.T) {
+	a := diffReel(t, "en", diffSub("", "00:00:01:00", "00:00:02:00", "One")+
+		diffSub("", "00:00:05:00", "00:00:06:00", "Two"))
+	b := diffReel(t, "en", diffSub("", "00:00:01:04", "00:00:02:00", "One")+
+		diffSub("", "00:00:01:02", "00:00:02:00", "Uno")+
+		diffSub("", "00:00:08:00", "00:00:09:00", "Two"))
+	d := Diff(a, b, 12)
+	// the nearest TimeIn within tolerance is matched, the event three seconds later is not.
+	want := []string{"modified 00:00:01:02", "added 00:00:01:04", "removed 00:00:05:00", "added 00:00:08:00"}
+	if got := eventKinds(d); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got events %v, want %v", got, want)
+	}
+	wantChanges := []Change{
+		{Field: "TimeIn", Old: "00:00:01:00", New: "00:00:01:02"},
+		{Field: "Text[1]", Old: "One", New: "Uno"},
+	}
+	if !reflect.DeepEqual(d.Events[0].Changes, wantChanges) {
+		t.Errorf("got changes %v, want %v", d.Events[0].Changes, wantChanges)
+	}
+}
+
+func TestDiffDefaultTolerance(t *testing.T) {
+	// a negative tolerance is one second at the EditRate of the first document.
+	a := diffReel(t, "en", diffSub("", "00:00:01:00", "00:00:02:00", "One"))
+	for _, tc := range []struct {
+		in   string
+		want []string
+	}{
+		{"00:00:02:00", []string{"modified 00:00:02:00"}},
+		{"00:00:02:01", []string{"removed 00:00:01:00", "added 00:00:02:01"}},
+	} {
+		b := diffReel(t, "en", diffSub("", tc.in, "00:00:03:00", "One"))
+		if got := eventKinds(Diff(a, b, -1)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("TimeIn %s: got events %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestDiffReportString(t *testing.T) {
+	a := diffReel(t, "en", diffSub("1", "00:00:01:00", "00:00:02:00", "One"))
+	b := diffReel(t, "fr", diffSub("1", "00:00:01:00", "00:00:02:00", "Un")+
+		diffSub("2", "00:00:03:00", "00:00:04:00", "Deux"))
+	want := strings.Join([]string{
+		`~ Language: "en" -> "fr"`,
+		`~ Subtitle 1 [00:00:01:00 - 00:00:02:00]`,
+		`    Text[1]: "One" -> "Un"`,
+		`+ Subtitle 2 [00:00:03:00 - 00:00:04:00]`,
+	}, "\n") + "\n"
+	if got := Diff(a, b, -1).String(); got != want {
+		t.Errorf("got report\n%s\nwant\n%s", got, want)
+	}
+}
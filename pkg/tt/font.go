@@ -0,0 +1,148 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+const (
+	// maxFontSize is the RDD 52 size limit of a single font resource.
+	maxFontSize = 640 << 10
+	defFontID   = "MinRefFont"
+)
+
+// FontResource is a font resource referenced by a LoadFont element.
+type FontResource struct {
+	// ID is the LoadFont ID attribute.
+	ID string
+	// UUID is the file name of the resource and the URN referenced by LoadFont.
+	UUID string
+	// Path is the source file, empty for the default font.
+	Path string
+	data []byte
+}
+
+// LoadFont returns the LoadFont element referencing the font resource.
+func (f *FontResource) LoadFont() *LoadFont {
+	return &LoadFont{ID: f.ID, Font: urn + f.UUID}
+}
+
+// Size returns the size of the font resource in bytes.
+func (f *FontResource) Size() int {
+	return len(f.data)
+}
+
+// LoadFonts reads and validates the OpenType/TrueType fonts at the given paths. Every font is
+// assigned a new Type-4 UUID resource name and a LoadFont ID. The default font is resolved
+// from Resources when no paths are given.
+func LoadFonts(paths []string, text string) ([]*FontResource, error) {
+	if len(paths) == 0 {
+		data, err := readResource(DefaultFont)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve default font resource: %w", err)
+		}
+		return []*FontResource{{ID: defFontID, UUID: fontName, data: data}}, nil
+	}
+	var fonts []*FontResource
+	for i, p := range paths {
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if err := ValidateFont(data, text); err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		fonts = append(fonts, &FontResource{
+			ID:   fmt.Sprintf("Font%d", i+1),
+			UUID: uuidType4(),
+			Path: p,
+			data: data,
+		})
+	}
+	return fonts, nil
+}
+
+// ValidateFont confirms that data is a parseable sfnt font within the RDD 52 size limit
+// that provides a glyph for every rune of text.
+func ValidateFont(data []byte, text string) error {
+	if len(data) > maxFontSize {
+		return fmt.Errorf("font resource exceeds %d bytes: %d", maxFontSize, len(data))
+	}
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return fmt.Errorf("invalid font resource: %w", err)
+	}
+	if missing := missingGlyphs(f, text); missing != "" {
+		return fmt.Errorf("font resource has no glyphs for: %q", missing)
+	}
+	return nil
+}
+
+// WriteFonts writes the font resources to the output directory using their UUID as file name.
+func WriteFonts(fonts []*FontResource, output string) error {
+	for _, f := range fonts {
+		if err := ioutil.WriteFile(filepath.Join(output, f.UUID), f.data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// missingGlyphs returns every distinct rune of text that has no glyph in the given font.
+func missingGlyphs(f *sfnt.Font, text string) string {
+	var b sfnt.Buffer
+	var missing strings.Builder
+	seen := make(map[rune]bool)
+	for _, r := range text {
+		if seen[r] || r == ' ' || r == '\n' || r == '\t' {
+			continue
+		}
+		seen[r] = true
+		if i, err := f.GlyphIndex(&b, r); err != nil || i == 0 {
+			missing.WriteRune(r)
+		}
+	}
+	return missing.String()
+}
+
+// documentText returns the character data of every Text, NestedFont, Ruby and Rt element of a SubtitleReel.
+func documentText(s *SubtitleReel) string {
+	var b strings.Builder
+	for _, sub := range subtitles(s) {
+		walkNestedFonts(sub.Font, func(f *NestedFont) {
+			b.WriteString(f.Text)
+		})
+		for _, t := range sub.texts() {
+			b.WriteString(t.Content())
+			b.WriteString(t.Annotations())
+		}
+	}
+	return b.String()
+}
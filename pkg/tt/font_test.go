@@ -0,0 +1,98 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestValidateFont(t *testing.T) {
+	padded := func(n int) []byte {
+		return append(append([]byte(nil), goregular.TTF...), make([]byte, n-len(goregular.TTF))...)
+	}
+	for _, tc := range []struct {
+		name string
+		data []byte
+		text string
+		err  string
+	}{
+		{"valid", goregular.TTF, "Hello, world!", ""},
+		{"whitespace", goregular.TTF, "Hello\n\tworld ", ""},
+		{"size limit", padded(maxFontSize), "Hello", ""},
+		{"oversized", padded(maxFontSize + 1), "Hello", "font resource exceeds 655360 bytes: 655361"},
+		{"missing glyphs", goregular.TTF, "Hello 漢字 漢", `font resource has no glyphs for: "漢字"`},
+		{"invalid", []byte("not a font"), "Hello", "invalid font resource"},
+		{"empty", nil, "", "invalid font resource"},
+	} {
+		err := ValidateFont(tc.data, tc.text)
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		case tc.err != "" && (err == nil || !strings.HasPrefix(err.Error(), tc.err)):
+			t.Errorf("%s: got error %v, want %s", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestLoadFonts(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "regular.ttf"), filepath.Join(dir, "italic.ttf")}
+	for i, data := range [][]byte{goregular.TTF, goitalic.TTF} {
+		if err := ioutil.WriteFile(paths[i], data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fonts, err := LoadFonts(paths, "Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fonts) != 2 || fonts[0].ID != "Font1" || fonts[1].ID != "Font2" || fonts[0].UUID == fonts[1].UUID {
+		t.Fatalf("got fonts %+v", fonts)
+	}
+	if lf := fonts[1].LoadFont(); lf.ID != "Font2" || lf.Font != urn+fonts[1].UUID {
+		t.Errorf("got LoadFont %+v", lf)
+	}
+	if fonts[0].Size() != len(goregular.TTF) {
+		t.Errorf("got size %d, want %d", fonts[0].Size(), len(goregular.TTF))
+	}
+
+	oversized := filepath.Join(dir, "oversized.ttf")
+	if err := ioutil.WriteFile(oversized, make([]byte, maxFontSize+1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{oversized, filepath.Join(dir, "missing.ttf")} {
+		if _, err := LoadFonts(append(paths, p), "Hello"); err == nil || !strings.Contains(err.Error(), p) {
+			t.Errorf("LoadFonts(%s) error = %v, want an error naming the file", filepath.Base(p), err)
+		}
+	}
+	if _, err := LoadFonts(paths, "漢字"); err == nil {
+		t.Error("LoadFonts accepted fonts without glyphs for the text")
+	}
+}
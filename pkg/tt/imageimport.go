@@ -0,0 +1,229 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see <http://www.gnu.org/licenses/>.*/
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ImageImportOptions configures ImportImages.
+type ImageImportOptions struct {
+	// Images is the directory holding the PNG files named in the timing list.
+	Images string
+	// Output is the target output directory for the XML document and image resources.
+	Output string
+	// Framerate is the EditRate of the timing list and the resulting document, e.g. "24" or "24000/1001".
+	Framerate string
+	// StartTime is the timecode of the timing list at the start of the reel, subtracted from every
+	// TimeIn and TimeOut. It defaults to zero for CSV lists and, for EDLs, to the whole hour of the
+	// first record in point, e.g. 01:00:00:00.
+	StartTime string
+	Reel      int
+	Display   int
+	Title     string
+	Language  string
+}
+
+// TimingEntry is a single event of a timing list.
+type TimingEntry struct {
+	File      string
+	TimeIn    string
+	TimeOut   string
+	Halign    string
+	Hposition string
+	Valign    string
+	Vposition string
+	Zposition string
+}
+
+// ImportImages builds an image profile SubtitleReel from a directory of PNGs and a timing list.
+// Every PNG is copied to the output directory under a new Type-4 UUID file name and validated with
+// CheckImage. Lists with an .edl extension are read as CMX 3600 EDLs, anything else as CSV.
+func ImportImages(list string, opts ImageImportOptions) (*SubtitleReel, []Finding, error) {
+	f, err := os.Open(list)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	var entries []TimingEntry
+	edl := strings.EqualFold(filepath.Ext(list), ".edl")
+	if edl {
+		entries, err = ReadEDL(f)
+	} else {
+		entries, err = ReadTimingCSV(f)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", list, err)
+	}
+
+	s := newSubtitleReel(opts.Title, opts.Language, opts.Framerate, opts.Reel, opts.Display)
+	rate := getEditRate(s.EditRate)
+	start := 0
+	switch {
+	case opts.StartTime != "":
+		tc, err := ParseTimecode(opts.StartTime, rate)
+		if err != nil {
+			return nil, nil, fmt.Errorf("StartTime: %w", err)
+		}
+		start = tc.Frames()
+	case edl && len(entries) > 0:
+		tc, err := ParseTimecode(entries[0].TimeIn, rate)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: entry 1: %w", list, err)
+		}
+		hour := 3600 * int(math.Ceil(rate))
+		start = tc.Frames() - tc.Frames()%hour
+	}
+	var findings []Finding
+	for i, e := range entries {
+		in, err := ParseTimecode(e.TimeIn, rate)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: entry %d: %w", list, i+1, err)
+		}
+		out, err := ParseTimecode(e.TimeOut, rate)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: entry %d: %w", list, i+1, err)
+		}
+		if out.Frames() <= in.Frames() {
+			return nil, nil, fmt.Errorf("%s: entry %d: TimeOut %s is not after TimeIn %s", list, i+1, e.TimeOut, e.TimeIn)
+		}
+		if in.Frames() < start {
+			return nil, nil, fmt.Errorf("%s: entry %d: TimeIn %s is before the start of the reel", list, i+1, e.TimeIn)
+		}
+		in.SetFrames(in.Frames() - start)
+		out.SetFrames(out.Frames() - start)
+		ID := uuidType4()
+		dst := filepath.Join(opts.Output, ID)
+		if err := copyFile(filepath.Join(opts.Images, e.File), dst); err != nil {
+			return nil, nil, err
+		}
+		findings = append(findings, CheckImage(dst, ImageCheckOptions{})...)
+		s.listFont().Subtitle = append(s.listFont().Subtitle, &Subtitle{
+			SpotNumber: fmt.Sprint(i + 1),
+			TimeIn:     in.GetTimeCode(),
+			TimeOut:    out.GetTimeCode(),
+			Image: []*Image{
+				{
+					Image:     urn + ID,
+					Halign:    e.Halign,
+					Hposition: e.Hposition,
+					Valign:    e.Valign,
+					Vposition: e.Vposition,
+					Zposition: e.Zposition,
+				},
+			},
+		})
+	}
+	findings = append(findings, ValidateDepth(s, DefaultDepthLimits)...)
+	return s, findings, nil
+}
+
+// ReadTimingCSV reads a timing list with the columns file, TimeIn, TimeOut and the optional
+// columns Halign, Hposition, Valign, Vposition and Zposition. A header row starting with "file" is skipped.
+func ReadTimingCSV(r io.Reader) ([]TimingEntry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var entries []TimingEntry
+	for i, rec := range records {
+		if i == 0 && strings.EqualFold(rec[0], "file") {
+			continue
+		}
+		if len(rec) < 3 {
+			return nil, fmt.Errorf("line %d: expected at least 3 fields, got %d", i+1, len(rec))
+		}
+		if n := 8 - len(rec); n > 0 {
+			rec = append(rec, make([]string, n)...)
+		}
+		entries = append(entries, TimingEntry{
+			File:      rec[0],
+			TimeIn:    rec[1],
+			TimeOut:   rec[2],
+			Halign:    rec[3],
+			Hposition: rec[4],
+			Valign:    rec[5],
+			Vposition: rec[6],
+			Zposition: rec[7],
+		})
+	}
+	return entries, nil
+}
+
+// ReadEDL reads a CMX 3600 EDL. Each event's record in and out points become the TimeIn and TimeOut,
+// and the file is taken from the "* FROM CLIP NAME:" comment, or the reel name when absent. The
+// record points are returned as is, ImportImages offsets them by the StartTime of the reel.
+func ReadEDL(r io.Reader) ([]TimingEntry, error) {
+	var entries []TimingEntry
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if name := strings.TrimPrefix(line, "* FROM CLIP NAME:"); name != line && len(entries) > 0 {
+			entries[len(entries)-1].File = strings.TrimSpace(name)
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 8 || !isDigits(fields[0]) {
+			continue
+		}
+		n := len(fields)
+		entries = append(entries, TimingEntry{
+			File:    fields[1],
+			TimeIn:  fields[n-2],
+			TimeOut: fields[n-1],
+		})
+	}
+	return entries, sc.Err()
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits.
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// copyFile copies the file src to dst.
+func copyFile(src, dst string) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, data, 0644)
+}
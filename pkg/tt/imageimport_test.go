@@ -0,0 +1,90 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testEDL = `TITLE: TEST
+FCM: NON-DROP FRAME
+
+001  AX       V     C        00:00:00:00 00:00:02:00 01:00:10:00 01:00:12:00
+* FROM CLIP NAME: a.png
+002  AX       V     C        00:00:00:00 00:00:01:12 01:00:20:00 01:00:21:12
+* FROM CLIP NAME: a.png
+`
+
+func TestImportImagesEDLStartTime(t *testing.T) {
+	dir := t.TempDir()
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, color.NRGBA{R: 0xFF, A: 0xFF})
+		}
+	}
+	f, err := os.Create(filepath.Join(dir, "a.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := encodeRGBA(f, img); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	list := filepath.Join(dir, "list.edl")
+	if err := ioutil.WriteFile(list, []byte(testEDL), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		start string
+		want  [][2]string
+	}{
+		{"", [][2]string{{"00:00:10:00", "00:00:12:00"}, {"00:00:20:00", "00:00:21:12"}}},
+		{"01:00:05:00", [][2]string{{"00:00:05:00", "00:00:07:00"}, {"00:00:15:00", "00:00:16:12"}}},
+	}
+	for _, tc := range tests {
+		s, _, err := ImportImages(list, ImageImportOptions{Images: dir, Output: t.TempDir(), Framerate: "24", StartTime: tc.start})
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs := subtitles(s)
+		if len(subs) != len(tc.want) {
+			t.Fatalf("StartTime %q: got %d events, want %d", tc.start, len(subs), len(tc.want))
+		}
+		for i, sub := range subs {
+			if sub.TimeIn != tc.want[i][0] || sub.TimeOut != tc.want[i][1] {
+				t.Errorf("StartTime %q: event %d is %s-%s, want %s-%s", tc.start, i+1, sub.TimeIn, sub.TimeOut, tc.want[i][0], tc.want[i][1])
+			}
+		}
+	}
+	if _, _, err := ImportImages(list, ImageImportOptions{Images: dir, Output: t.TempDir(), Framerate: "24", StartTime: "01:00:15:00"}); err == nil {
+		t.Error("ImportImages accepted an event before StartTime")
+	}
+}
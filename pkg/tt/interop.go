@@ -0,0 +1,241 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// interopTickRate is the number of ticks per second of Interop time values.
+const interopTickRate = 250
+
+// interopHeader maps the Interop header elements to the SubtitleReel fields they populate.
+var interopHeader = map[string]bool{"SubtitleID": true, "MovieTitle": true, "ReelNumber": true, "Language": true}
+
+// importInterop converts an Interop DCSubtitle document to a SubtitleReel. Element and attribute
+// names are mapped to their ST 428-7 names, time values counted in ticks of 4 ms are converted
+// to frames, and top-level Subtitle elements are wrapped in a SubtitleList. Font and image
+// references are file names in Interop and are reported, since they need to be replaced by
+// urn:uuid resources.
+func importInterop(data []byte, opts TextImportOptions) (*SubtitleReel, []Finding, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var b bytes.Buffer
+	e := xml.NewEncoder(&b)
+	var findings []Finding
+	warn := func(loc, format string, a ...interface{}) {
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Location: loc,
+			Message:  fmt.Sprintf(format, a...),
+		})
+	}
+	header := make(map[string]string)
+	editRate := toEditRate(opts.Framerate)
+	var path []string
+	// wrapped holds the wrapper elements opened around top-level Font and Subtitle elements.
+	var wrapped []string
+	closeWrapper := func() error {
+		for i := len(wrapped) - 1; i >= 0; i-- {
+			if err := e.EncodeToken(xml.EndElement{Name: xml.Name{Local: wrapped[i]}}); err != nil {
+				return err
+			}
+		}
+		wrapped = nil
+		return nil
+	}
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := t.Name.Local
+			if len(path) == 0 {
+				if name != "DCSubtitle" {
+					return nil, nil, fmt.Errorf("not an Interop document: %s", name)
+				}
+				path = append(path, name)
+				err = e.EncodeToken(xml.StartElement{
+					Name: xml.Name{Local: "SubtitleReel"},
+					Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: DCST2014}},
+				})
+				break
+			}
+			path = append(path, name)
+			if len(path) == 2 {
+				if name != "Subtitle" || len(wrapped) != 2 {
+					if err := closeWrapper(); err != nil {
+						return nil, nil, err
+					}
+				}
+				switch {
+				case name == "Font":
+					wrapped = []string{"SubtitleList"}
+				case name == "Subtitle" && len(wrapped) == 0:
+					wrapped = []string{"SubtitleList", "Font"}
+				}
+				if name == "Font" || name == "Subtitle" {
+					for _, w := range wrapped {
+						if err := e.EncodeToken(xml.StartElement{Name: xml.Name{Local: w}}); err != nil {
+							return nil, nil, err
+						}
+					}
+					if name == "Font" {
+						wrapped = nil
+					}
+				}
+			}
+			if len(path) == 2 && name != "Font" && name != "Subtitle" && name != "LoadFont" {
+				// header elements are read separately, everything else is dropped.
+				if !interopHeader[name] {
+					warn(name, "dropped Interop element %s", name)
+				}
+				var value string
+				if err := d.DecodeElement(&value, &t); err != nil {
+					return nil, nil, err
+				}
+				header[name] = strings.TrimSpace(value)
+				path = path[:len(path)-1]
+				continue
+			}
+			start := xml.StartElement{Name: xml.Name{Local: name}}
+			var uri string
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+					continue
+				}
+				attr := a.Name.Local
+				if n, ok := attrs2007[attr]; ok {
+					attr = n
+				}
+				value := a.Value
+				switch {
+				case name == "LoadFont" && attr == "URI":
+					uri = value
+					continue
+				case name == "Subtitle" && timeAttrs[attr]:
+					if value, err = ticksToFrames(value, editRate); err != nil {
+						return nil, nil, fmt.Errorf("Subtitle %s: %w", attr, err)
+					}
+				}
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attr}, Value: value})
+			}
+			if err := e.EncodeToken(start); err != nil {
+				return nil, nil, err
+			}
+			if name == "LoadFont" {
+				warn("LoadFont", "font reference %s must be replaced by a urn:uuid font resource", uri)
+				err = e.EncodeToken(xml.CharData(uri))
+			}
+		case xml.EndElement:
+			path = path[:len(path)-1]
+			name := t.Name.Local
+			if len(path) == 0 {
+				if err := closeWrapper(); err != nil {
+					return nil, nil, err
+				}
+				name = "SubtitleReel"
+			}
+			err = e.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}})
+			if len(path) == 1 && t.Name.Local == "Font" {
+				err = e.EncodeToken(xml.EndElement{Name: xml.Name{Local: "SubtitleList"}})
+			}
+		case xml.CharData:
+			if len(path) > 0 && path[len(path)-1] == "Image" {
+				warn("Image", "image reference %s must be replaced by a urn:uuid image resource", strings.TrimSpace(string(t)))
+			}
+			if len(path) > 1 {
+				err = e.EncodeToken(t)
+			}
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := e.Flush(); err != nil {
+		return nil, nil, err
+	}
+
+	var body SubtitleReel
+	if err := xml.Unmarshal(b.Bytes(), &body); err != nil {
+		return nil, nil, err
+	}
+	if len(subtitles(&body)) == 0 {
+		return nil, nil, errors.New("no Subtitle elements found")
+	}
+	if opts.Title == "" {
+		opts.Title = header["MovieTitle"]
+	}
+	if opts.Language == "" {
+		opts.Language = header["Language"]
+	}
+	if opts.Language == "" {
+		opts.Language = "en"
+	}
+	if n, err := strconv.Atoi(header["ReelNumber"]); err == nil && n > 0 {
+		opts.Reel = n
+	}
+	s := newSubtitleReel(opts.Title, opts.Language, opts.Framerate, opts.Reel, opts.Display)
+	s.XMLName.Space = DCST2014
+	s.Xmlns = DCST2014
+	s.LoadFont = body.LoadFont
+	s.SubtitleList = body.SubtitleList
+	return s, findings, nil
+}
+
+// ticksToFrames converts an Interop time value, either HH:MM:SS:TTT counting ticks of 4 ms,
+// HH:MM:SS.sss or a plain count of ticks, to a timecode counting frames of the EditRate.
+func ticksToFrames(value, editRate string) (string, error) {
+	var seconds float64
+	if n, err := strconv.Atoi(value); err == nil {
+		seconds = float64(n) / interopTickRate
+	} else {
+		m := tcRegexp.FindStringSubmatch(value)
+		if m == nil {
+			return "", fmt.Errorf("invalid time value: %s", value)
+		}
+		h, _ := strconv.Atoi(m[1])
+		mi, _ := strconv.Atoi(m[2])
+		sec, _ := strconv.Atoi(m[3])
+		seconds = float64(h*3600 + mi*60 + sec)
+		if strings.Contains(value, ".") {
+			f, _ := strconv.ParseFloat("0."+m[4], 64)
+			seconds += f
+		} else {
+			ticks, _ := strconv.Atoi(m[4])
+			seconds += float64(ticks) / interopTickRate
+		}
+	}
+	return msToTimecode(seconds*1000, editRate)
+}
@@ -0,0 +1,422 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Job holds the settings of a single document generation. Unlike CreateXML, which reads the
+// package variables, a Job shares no mutable state and can be run concurrently with others.
+type Job struct {
+	// Text and Image select the profile. Image takes precedence when both are set.
+	Text  bool
+	Image bool
+	// Track and Encrypt signal that an, optionally encrypted, MXF track file is to be written.
+	Track   bool
+	Encrypt bool
+	Reel    int
+	// Display is the DisplayType, 0 = MainSubtitle, >= 1 = ClosedCaption.
+	Display int
+	// Duration is the ContainerDuration of the track file. Zero derives it from the last TimeOut
+	// of the document.
+	Duration int
+	// Framerate is a whole number, e.g. "24", or a rational, e.g. "24000/1001".
+	Framerate string
+	// Namespace is the DCST namespace of the document, DCST2010 or DCST2014. Defaults to
+	// DCST2014 and is replaced by the namespace of a template.
+	Namespace string
+	Language  string
+	Title     string
+	Template  string
+	// Output is the target output directory. The XML document is written to Log when empty.
+	Output    string
+	Fonts     []string
+	Subset    bool
+	Captions  bool
+	Direction string
+	RubyText  string
+	Zposition string
+	VariableZ string
+	// Checksums writes a DeliveryManifest of the generated files beside them. Requires Output.
+	Checksums bool
+	// Archive packages the output into a single ArchiveZip or ArchiveTarGz archive with a
+	// checksum manifest instead of writing loose files. Requires Output.
+	Archive string
+	// Composition writes a supplemental CPL, PKL, ASSETMAP and VOLINDEX of the track file.
+	// Requires Track and Output.
+	Composition bool
+	// Wrapper writes the track file. Defaults to asdcp-wrap at $PATH.
+	Wrapper Wrapper
+	// Log receives validation findings and messages. Defaults to os.Stdout.
+	Log io.Writer
+}
+
+// Result holds the files and identifiers produced by a Job.
+type Result struct {
+	// DocumentID is the Id of the XML document.
+	DocumentID string `json:"documentId"`
+	// XML is the path of the XML document, empty when it was written to Log.
+	XML string `json:"xml,omitempty"`
+	// Resources are the paths of the font and image resources.
+	Resources []string `json:"resources,omitempty"`
+	// MXF and AssetUUID identify the track file.
+	MXF       string `json:"mxf,omitempty"`
+	AssetUUID string `json:"assetUuid,omitempty"`
+	// KeyID and Key are the content key of an encrypted track file.
+	KeyID string `json:"keyId,omitempty"`
+	Key   string `json:"key,omitempty"`
+	// Composition are the paths of the CPL, PKL, ASSETMAP and VOLINDEX.
+	Composition []string `json:"composition,omitempty"`
+	// Manifest are the paths of the JSON and CSV DeliveryManifest.
+	Manifest []string `json:"manifest,omitempty"`
+	// Archive is the path of the archive of a packaged Job. XML, Resources, MXF and Manifest are
+	// then the names of the files within the archive.
+	Archive string `json:"archive,omitempty"`
+}
+
+// Files returns the paths of the XML document, its resources, its track file, its packaging
+// documents and its delivery manifest.
+func (r *Result) Files() []string {
+	files := append([]string{r.XML}, r.Resources...)
+	if r.MXF != "" {
+		files = append(files, r.MXF)
+	}
+	files = append(files, r.Composition...)
+	return append(files, r.Manifest...)
+}
+
+// Run generates the XML document, its resources and, optionally, its track file.
+func (j Job) Run() (*Result, error) {
+	return j.RunContext(context.Background())
+}
+
+// RunContext is Run with a context. The context is checked between the steps of the Job, so a
+// cancelled Job stops before writing its next file.
+func (j Job) RunContext(ctx context.Context) (*Result, error) {
+	if err := j.validate(); err != nil {
+		return nil, err
+	}
+	if j.Checksums && j.Output == "" {
+		return nil, errors.New("checksums require an output directory")
+	}
+	if j.Composition && (!j.Track || j.Output == "") {
+		return nil, errors.New("a composition requires a track file and an output directory")
+	}
+	if j.Archive != "" {
+		if j.Output == "" {
+			return nil, errors.New("an archive requires an output directory")
+		}
+		return j.packageJob(ctx)
+	}
+	return j.run(ctx)
+}
+
+// validate checks the frame rate, reel number and duration of a Job.
+func (j Job) validate() error {
+	if err := checkFrameRate(j.Framerate); err != nil {
+		return err
+	}
+	if j.Reel < 1 {
+		return fmt.Errorf("invalid reel number %d", j.Reel)
+	}
+	if j.Duration < 0 {
+		return fmt.Errorf("invalid duration %d", j.Duration)
+	}
+	return nil
+}
+
+// run generates the files of a Job into its output directory.
+func (j Job) run(ctx context.Context) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	log := j.Log
+	if log == nil {
+		log = os.Stdout
+	}
+	if j.Captions {
+		if j.Image {
+			return nil, errors.New("the image profile is not permitted in closed caption mode")
+		}
+		j.Text = true
+	}
+	if j.Image {
+		j.Text = false
+	}
+	ns := j.Namespace
+	if ns == "" {
+		ns = xmlNs
+	}
+	if j.Template != "" {
+		s, err := parseXML(j.Template)
+		if err != nil {
+			fmt.Fprintf(log, "%s\nunable to use template, running with default values\n", err)
+		} else {
+			ns = s.XMLName.Space
+			j.Title = s.ContentTitleText
+			j.Language = s.Language
+			j.Framerate = strings.Replace(strings.TrimSuffix(s.EditRate, " 1"), " ", "/", 1)
+			if err := checkFrameRate(j.Framerate); err != nil {
+				return nil, fmt.Errorf("template %s: %w", j.Template, err)
+			}
+			if s.DisplayType == "MainSubtitle" {
+				j.Display = 0
+			}
+			if s.DisplayType == "ClosedCaption" {
+				j.Display = 1
+			}
+		}
+	}
+	if j.Captions {
+		j.Display = 1
+	}
+	editRate := toEditRate(j.Framerate)
+	docID := uuidType4()
+	dxml := SubtitleReel{
+		Xmlns:            ns,
+		ID:               urn + docID,
+		ContentTitleText: j.Title,
+		IssueDate:        issueDate(),
+		ReelNumber:       j.Reel,
+		Language:         j.Language,
+		EditRate:         editRate,
+		TimeCodeRate:     strconv.Itoa(timecodeRate(editRate)),
+		StartTime:        startTime,
+		DisplayType:      "MainSubtitle",
+		SubtitleList:     &SubtitleList{},
+	}
+	mxfFileExt := "_sub.mxf"
+	if j.Display >= 1 {
+		dxml.DisplayType = "ClosedCaption"
+		mxfFileExt = "_cap.mxf"
+	}
+
+	res := &Result{DocumentID: dxml.ID}
+	timeIn, timeOut, err := eventTiming(j.Reel, editRate)
+	if err != nil {
+		return nil, err
+	}
+	var fonts []*FontResource
+	list := dxml.listFont()
+	if j.Image {
+		imageID, err := makePNG(j.Output)
+		if err != nil {
+			return nil, err
+		}
+		if j.Output != "" {
+			res.Resources = append(res.Resources, filepath.Join(j.Output, imageID))
+		}
+		list.Subtitle = append(list.Subtitle, &Subtitle{
+			TimeIn:  timeIn,
+			TimeOut: timeOut,
+			Image:   []*Image{{Image: urn + imageID}},
+		})
+	}
+	if j.Text {
+		t, err := defaultText(j.Captions, j.Direction, j.RubyText)
+		if err != nil {
+			return nil, err
+		}
+		list.Subtitle = append(list.Subtitle, &Subtitle{
+			TimeIn:  timeIn,
+			TimeOut: timeOut,
+			Text:    []*Text{t},
+		})
+
+		text := documentText(&dxml)
+		if fonts, err = LoadFonts(j.Fonts, text); err != nil {
+			return nil, err
+		}
+		for _, f := range fonts {
+			if j.Subset {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				before, after, err := f.Subset(text)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", f.ID, err)
+				}
+				fmt.Fprintf(log, "%s: subset font resource from %d to %d bytes\n", f.ID, before, after)
+			}
+			dxml.LoadFont = append(dxml.LoadFont, f.LoadFont())
+		}
+		if len(j.Fonts) > 0 {
+			list.ID = fonts[0].ID
+		}
+		if err := report(log, ValidateText(&dxml), "text validation failed"); err != nil {
+			return nil, err
+		}
+	}
+	if j.Zposition != "" || j.VariableZ != "" {
+		if err := setDepth(&dxml, j.Zposition, j.VariableZ); err != nil {
+			return nil, err
+		}
+		if err := report(log, ValidateDepth(&dxml, DefaultDepthLimits), "depth validation failed"); err != nil {
+			return nil, err
+		}
+	}
+	if j.Captions {
+		if err := report(log, ValidateCaptions(&dxml, DefaultCaptionRules), "closed caption validation failed"); err != nil {
+			return nil, err
+		}
+	}
+
+	// Write XML to the log when no output path is given.
+	if j.Output == "" {
+		enc, err := xml.MarshalIndent(dxml, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(log, "%s%s\n", xml.Header, enc)
+		return res, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	xmlOutputPath, err := WriteXML(&dxml, j.Output)
+	if err != nil {
+		return nil, err
+	}
+	res.XML = xmlOutputPath
+	if j.Text {
+		if err := WriteFonts(fonts, j.Output); err != nil {
+			return nil, err
+		}
+		for _, f := range fonts {
+			res.Resources = append(res.Resources, filepath.Join(j.Output, f.UUID))
+		}
+	}
+
+	if j.Track {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		w := j.Wrapper
+		if w == nil {
+			w = &ASDCPWrapper{}
+		}
+		res.AssetUUID = uuidType4()
+		mxfFilename := res.AssetUUID + reelNo + strconv.Itoa(j.Reel) + mxfFileExt
+		duration := j.Duration
+		if duration == 0 {
+			duration = lastTimeOut(&dxml)
+		}
+		opts, err := wrapTrack(w, j.Encrypt, j.Framerate, xmlOutputPath, filepath.Join(j.Output, mxfFilename), res.AssetUUID, duration)
+		if err != nil {
+			return nil, err
+		}
+		res.MXF = filepath.Join(j.Output, mxfFilename)
+		res.KeyID, res.Key = opts.KeyID, opts.Key
+		if j.Encrypt {
+			fmt.Fprintf(log, `
+Keep the following safe!
+KeyID: %s
+KeyString: %s
+`, opts.KeyID, opts.Key)
+		}
+	}
+	if j.Composition {
+		if res.Composition, err = writeComposition(res, j.Title, j.Output); err != nil {
+			return nil, err
+		}
+	}
+	if j.Checksums {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		m := &DeliveryManifest{DocumentID: res.DocumentID}
+		for _, f := range res.Files() {
+			a, err := HashFile(f)
+			if err != nil {
+				return nil, err
+			}
+			m.Assets = append(m.Assets, a)
+		}
+		if res.Manifest, err = WriteDeliveryManifest(m, j.Output); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// report writes findings to log and returns an error with the given message when any of them
+// is an error.
+func report(log io.Writer, findings []Finding, message string) error {
+	for _, f := range findings {
+		fmt.Fprintln(log, f)
+	}
+	if HasErrors(findings) {
+		return errors.New(message)
+	}
+	return nil
+}
+
+// wrapTrack wraps a document into a track file with a new content key when encrypting.
+func wrapTrack(w Wrapper, encrypt bool, frameRate, source, output, assetUUID string, duration int) (WrapOptions, error) {
+	opts := WrapOptions{
+		AssetUUID: assetUUID,
+		Duration:  duration,
+		FrameRate: frameRate,
+	}
+	if encrypt {
+		opts.KeyID = uuidType4()
+		opts.Key = randomHex()
+	}
+	return opts, w.Wrap(source, output, opts)
+}
+
+// eventTiming returns the TimeIn and TimeOut of the generated Subtitle event of an EditRate.
+// Reel 1 starts after r1TimeIn seconds, all other reels after defTimeIn seconds.
+func eventTiming(reel int, editRate string) (string, string, error) {
+	fps := timecodeRate(editRate)
+	frames := defTimeIn * fps
+	if reel == 1 {
+		frames = r1TimeIn * fps
+	}
+	timeIn, err := timecode(frames, fps)
+	if err != nil {
+		return "", "", err
+	}
+	timeOut, err := timecode(frames+minDuration, fps)
+	return timeIn, timeOut, err
+}
+
+// timecode returns the timecode of a frame count at the given TimeCodeRate.
+func timecode(frames, fps int) (string, error) {
+	tc, err := NewTimecode(float64(fps))
+	if err != nil {
+		return "", fmt.Errorf("TimeCodeRate %d: %w", fps, err)
+	}
+	tc.SetFrames(frames)
+	return tc.GetTimeCode(), nil
+}
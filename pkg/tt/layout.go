@@ -0,0 +1,161 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// vertical text defaults applied to generated Text elements with a ttb Direction. Vertical
+// lines are set at the right edge of the screen and centered vertically.
+const (
+	verticalHalign    = "right"
+	verticalHposition = "10"
+	verticalValign    = "center"
+)
+
+// legal attribute values of ST 428-7.
+var (
+	directions = map[string]bool{"ltr": true, "rtl": true, "ttb": true, "btt": true}
+	haligns    = map[string]bool{"left": true, "center": true, "right": true}
+	valigns    = map[string]bool{"top": true, "center": true, "bottom": true}
+	rtPosition = map[string]bool{"before": true, "after": true}
+)
+
+// defaultText returns the Text element of a generated document with the caption defaults, a
+// writing direction and a ruby annotated base given as "base=annotation".
+func defaultText(captions bool, direction, ruby string) (*Text, error) {
+	t := NewText("")
+	if captions {
+		t.Halign, t.Valign, t.Vposition = captionHalign, captionValign, captionVposition
+	}
+	t.Direction = direction
+	if isVertical(direction) {
+		t.Halign, t.Hposition, t.Valign, t.Vposition = verticalHalign, verticalHposition, verticalValign, ""
+	}
+	if ruby != "" {
+		i := strings.Index(ruby, "=")
+		if i <= 0 || i == len(ruby)-1 {
+			return nil, errors.New("ruby must be given as base=annotation")
+		}
+		t.Runs = append(t.Runs, &Run{Ruby: &Ruby{Rb: ruby[:i], Rt: &Rt{Text: ruby[i+1:]}}})
+	}
+	return t, nil
+}
+
+// isVertical reports whether a Direction sets lines vertically.
+func isVertical(direction string) bool {
+	return direction == "ttb" || direction == "btt"
+}
+
+// namespace returns the DCST namespace of a SubtitleReel.
+func namespace(s *SubtitleReel) string {
+	if s.XMLName.Space != "" {
+		return s.XMLName.Space
+	}
+	return s.Xmlns
+}
+
+// ValidateText checks the layout attributes and Ruby annotations of every Text element against
+// ST 428-7. Ruby is a 2014 construct and is reported in documents of any other namespace.
+func ValidateText(s *SubtitleReel) []Finding {
+	var findings findingList
+	ns := namespace(s)
+	for i, sub := range subtitles(s) {
+		report := findings.at(subtitleLocation(i, sub))
+		for _, t := range sub.texts() {
+			checkLayout(t, report)
+			for _, r := range t.Runs {
+				if r.Ruby != nil {
+					if xmlNsSubtitle[ns] != dcst2014 {
+						report(SeverityError, "Ruby is not permitted in a %s document", ns)
+					}
+					checkRuby(r.Ruby, report)
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// checkLayout reports illegal Direction, alignment and position attributes of a Text element.
+func checkLayout(t *Text, report func(severity, format string, a ...interface{})) {
+	if t.Direction != "" && !directions[t.Direction] {
+		report(SeverityError, "Direction %q is not one of ltr, rtl, ttb or btt", t.Direction)
+	}
+	if t.Halign != "" && !haligns[t.Halign] {
+		report(SeverityError, "Halign %q is not one of left, center or right", t.Halign)
+	}
+	if t.Valign != "" && !valigns[t.Valign] {
+		report(SeverityError, "Valign %q is not one of top, center or bottom", t.Valign)
+	}
+	for _, p := range [][2]string{{"Hposition", t.Hposition}, {"Vposition", t.Vposition}} {
+		if p[1] == "" {
+			continue
+		}
+		if v, err := strconv.ParseFloat(p[1], 64); err != nil || v < 0 || v > 100 {
+			report(SeverityError, "%s %q is outside the range 0 to 100", p[0], p[1])
+		}
+	}
+	// vertical lines advance across the screen, so a column centered horizontally is offset by
+	// Hposition from the center, and one aligned to an edge is set by Halign and Hposition.
+	if isVertical(t.Direction) && t.Halign == "" && t.Hposition == "" {
+		report(SeverityWarning, "vertical text has no horizontal position and will be centered")
+	}
+}
+
+// checkRuby reports illegal Ruby content and Rt attributes.
+func checkRuby(r *Ruby, report func(severity, format string, a ...interface{})) {
+	if strings.TrimSpace(r.Rb) == "" {
+		report(SeverityError, "Ruby has an empty Rb base")
+	}
+	if r.Rt == nil || strings.TrimSpace(r.Rt.Text) == "" {
+		report(SeverityError, "Ruby %q has no Rt annotation", r.Rb)
+		return
+	}
+	rt := r.Rt
+	if rt.Position != "" && !rtPosition[rt.Position] {
+		report(SeverityError, "Rt Position %q is not one of before or after", rt.Position)
+	}
+	if rt.Size != "" {
+		if v, err := strconv.ParseFloat(rt.Size, 64); err != nil || v <= 0 {
+			report(SeverityError, "Rt Size %q is not a positive decimal", rt.Size)
+		}
+	}
+	if rt.AspectAdjust != "" {
+		if v, err := strconv.ParseFloat(rt.AspectAdjust, 64); err != nil || v < 0.25 || v > 4 {
+			report(SeverityError, "Rt AspectAdjust %q is outside the range 0.25 to 4.0", rt.AspectAdjust)
+		}
+	}
+	for _, a := range [][2]string{{"Offset", rt.Offset}, {"Spacing", rt.Spacing}} {
+		if a[1] == "" {
+			continue
+		}
+		if _, err := strconv.ParseFloat(a[1], 64); err != nil {
+			report(SeverityError, "Rt %s %q is not a decimal", a[0], a[1])
+		}
+	}
+}
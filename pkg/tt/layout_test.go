@@ -0,0 +1,136 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"reflect"
+	"testing"
+)
+
+// textSub returns a Subtitle element holding a single Text element with the given attributes and
+// content.
+func textSub(attrs, content string) string {
+	return `<Subtitle SpotNumber="1" TimeIn="00:00:01:00" TimeOut="00:00:02:00"><Text ` + attrs + `>` + content + `</Text></Subtitle>`
+}
+
+func TestValidateText(t *testing.T) {
+	const ruby = `<Ruby><Rb>漢字</Rb><Rt Size="0.5" Position="before" Offset="0" Spacing="0" AspectAdjust="1.0">かんじ</Rt></Ruby>`
+	for _, tc := range []struct {
+		name, subtitles string
+		want            []string
+	}{
+		{"valid", textSub(`Direction="rtl" Halign="left" Hposition="0" Valign="top" Vposition="100"`, "Hello"), nil},
+		{"vertical", textSub(`Direction="ttb" Halign="right" Hposition="10"`, "縦書き"), nil},
+		{"ruby", textSub(`Direction="ttb" Halign="right" Hposition="10"`, ruby), nil},
+		{"Direction", textSub(`Direction="up"`, "Hello"),
+			[]string{`error: Subtitle 1: Direction "up" is not one of ltr, rtl, ttb or btt`}},
+		{"alignment", textSub(`Halign="middle" Valign="Bottom"`, "Hello"), []string{
+			`error: Subtitle 1: Halign "middle" is not one of left, center or right`,
+			`error: Subtitle 1: Valign "Bottom" is not one of top, center or bottom`,
+		}},
+		{"position", textSub(`Hposition="-1" Vposition="100.5"`, "Hello") +
+			diffSub("", "00:00:03:00", "00:00:04:00", "Hello"), []string{
+			`error: Subtitle 1: Hposition "-1" is outside the range 0 to 100`,
+			`error: Subtitle 1: Vposition "100.5" is outside the range 0 to 100`,
+		}},
+		{"invalid position", diffSub("", "00:00:03:00", "00:00:04:00", "Hello") + textSub(`Vposition="ten"`, "Hello"),
+			[]string{`error: Subtitle 1: Vposition "ten" is outside the range 0 to 100`}},
+		{"unpositioned vertical", `<Subtitle TimeIn="00:00:01:00" TimeOut="00:00:02:00"><Text Direction="btt">縦</Text></Subtitle>`,
+			[]string{"warning: Subtitle #1: vertical text has no horizontal position and will be centered"}},
+		{"empty Rb", textSub("", `<Ruby><Rb> </Rb><Rt>かんじ</Rt></Ruby>`),
+			[]string{"error: Subtitle 1: Ruby has an empty Rb base"}},
+		{"no Rt", textSub("", `<Ruby><Rb>漢字</Rb></Ruby>`), []string{`error: Subtitle 1: Ruby "漢字" has no Rt annotation`}},
+		{"empty Rt", textSub("", `<Ruby><Rb>漢字</Rb><Rt Size="0.5"></Rt></Ruby>`),
+			[]string{`error: Subtitle 1: Ruby "漢字" has no Rt annotation`}},
+		{"Rt attributes", textSub("", `<Ruby><Rb>漢字</Rb><Rt Size="0" Position="above" Offset="a" Spacing="1e" AspectAdjust="4.5">かんじ</Rt></Ruby>`), []string{
+			`error: Subtitle 1: Rt Position "above" is not one of before or after`,
+			`error: Subtitle 1: Rt Size "0" is not a positive decimal`,
+			`error: Subtitle 1: Rt AspectAdjust "4.5" is outside the range 0.25 to 4.0`,
+			`error: Subtitle 1: Rt Offset "a" is not a decimal`,
+			`error: Subtitle 1: Rt Spacing "1e" is not a decimal`,
+		}},
+		{"Rt limits", textSub("", `<Ruby><Rb>漢字</Rb><Rt Size="0.1" AspectAdjust="0.25" Offset="-0.5" Spacing="0.1">かんじ</Rt></Ruby>`), nil},
+	} {
+		var got []string
+		for _, f := range ValidateText(diffReel(t, "ja", tc.subtitles)) {
+			got = append(got, f.String())
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got findings %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestValidateTextRubyNamespace(t *testing.T) {
+	s := diffReel(t, "ja", textSub("", `<Ruby><Rb>漢字</Rb><Rt>かんじ</Rt></Ruby>`))
+	s.XMLName.Space = DCST2010
+	var got []string
+	for _, f := range ValidateText(s) {
+		got = append(got, f.String())
+	}
+	want := []string{"error: Subtitle 1: Ruby is not permitted in a " + DCST2010 + " document"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got findings %q, want %q", got, want)
+	}
+}
+
+func TestDefaultText(t *testing.T) {
+	for _, tc := range []struct {
+		captions        bool
+		direction, ruby string
+		halign, valign  string
+		hposition, vpos string
+		runs            int
+	}{
+		{false, "", "", "", "", "", "", 0},
+		{true, "", "", captionHalign, captionValign, "", captionVposition, 0},
+		{false, "ttb", "漢字=かんじ", verticalHalign, verticalValign, verticalHposition, "", 1},
+	} {
+		text, err := defaultText(tc.captions, tc.direction, tc.ruby)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{text.Halign, text.Valign, text.Hposition, text.Vposition, text.Direction}
+		if want := []string{tc.halign, tc.valign, tc.hposition, tc.vpos, tc.direction}; !reflect.DeepEqual(got, want) {
+			t.Errorf("defaultText(%v, %q, %q) position %q, want %q", tc.captions, tc.direction, tc.ruby, got, want)
+		}
+		var rubies int
+		for _, r := range text.Runs {
+			if r.Ruby != nil {
+				rubies++
+				if r.Ruby.Rb != "漢字" || r.Ruby.Rt == nil || r.Ruby.Rt.Text != "かんじ" {
+					t.Errorf("got Ruby %+v", r.Ruby)
+				}
+			}
+		}
+		if rubies != tc.runs {
+			t.Errorf("defaultText(%v, %q, %q) has %d Ruby runs, want %d", tc.captions, tc.direction, tc.ruby, rubies, tc.runs)
+		}
+	}
+	for _, ruby := range []string{"漢字", "=かんじ", "漢字="} {
+		if _, err := defaultText(false, "", ruby); err == nil {
+			t.Errorf("defaultText accepted ruby %q", ruby)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultMatrix is the test matrix of server and player conformance content.
+var DefaultMatrix = MatrixOptions{
+	EditRates:    []string{"24", "25", "30", "48", "50", "60", "24000/1001"},
+	Namespaces:   []string{DCST2010, DCST2014},
+	DisplayTypes: []string{"MainSubtitle", "ClosedCaption"},
+	Profiles:     []string{"text", "image"},
+	Encrypted:    []bool{false, true},
+	Reels:        []int{1, 2},
+}
+
+// permittedEditRates are the EditRates of RDD 52 track files.
+var permittedEditRates = map[string]bool{
+	"24 1": true, "25 1": true, "30 1": true, "48 1": true, "50 1": true, "60 1": true,
+}
+
+// MatrixOptions lists the values of every dimension of a test matrix and where it is written.
+type MatrixOptions struct {
+	// Output is the root of the directory tree.
+	Output string
+	// Workers, Wrapper and ASDCP are used as per Manifest.
+	Workers int
+	Wrapper string
+	ASDCP   string
+
+	EditRates    []string
+	Namespaces   []string
+	DisplayTypes []string
+	Profiles     []string
+	Encrypted    []bool
+	Reels        []int
+}
+
+// MatrixCase is a single combination of a test matrix and its outcome.
+type MatrixCase struct {
+	// Path is the directory of the case relative to MatrixOptions.Output.
+	Path        string
+	EditRate    string
+	Namespace   string
+	DisplayType string
+	Profile     string
+	Encrypted   bool
+	Reel        int
+	// Expected is the outcome a conforming server or player is expected to show.
+	Expected string
+	Result   BatchResult
+}
+
+// Cases returns the cartesian product of the matrix dimensions.
+func (o MatrixOptions) Cases() []*MatrixCase {
+	var cases []*MatrixCase
+	for _, rate := range o.EditRates {
+		for _, ns := range o.Namespaces {
+			for _, display := range o.DisplayTypes {
+				for _, profile := range o.Profiles {
+					for _, encrypted := range o.Encrypted {
+						for _, reel := range o.Reels {
+							c := &MatrixCase{
+								EditRate:    rate,
+								Namespace:   ns,
+								DisplayType: display,
+								Profile:     profile,
+								Encrypted:   encrypted,
+								Reel:        reel,
+							}
+							c.Path = c.path()
+							c.Expected = c.expected()
+							cases = append(cases, c)
+						}
+					}
+				}
+			}
+		}
+	}
+	return cases
+}
+
+// path returns the directory of a case: rate/namespace/display/profile/encryption/reel.
+func (c *MatrixCase) path() string {
+	encryption := "clear"
+	if c.Encrypted {
+		encryption = "encrypted"
+	}
+	return filepath.Join(strings.Replace(c.EditRate, "/", "-", 1), namespaceName(c.Namespace),
+		strings.ToLower(c.DisplayType), c.Profile, encryption, "r"+strconv.Itoa(c.Reel))
+}
+
+// namespaceName returns a short name of a DCST namespace, e.g. "dcst2014".
+func namespaceName(ns string) string {
+	if n, ok := xmlNsSubtitle[ns]; ok {
+		return n
+	}
+	return strings.Trim(strings.Replace(strings.TrimPrefix(ns, "http://"), "/", "-", -1), "-")
+}
+
+// expected returns the expected outcome of a case, "play" or "reject" with the reasons.
+func (c *MatrixCase) expected() string {
+	var reasons []string
+	if !permittedEditRates[toEditRate(c.EditRate)] {
+		reasons = append(reasons, "EditRate "+c.EditRate+" is not an RDD 52 edit rate")
+	}
+	if c.DisplayType == "ClosedCaption" && c.Profile == "image" {
+		reasons = append(reasons, "closed captions use the text profile")
+	}
+	if len(reasons) > 0 {
+		return "reject: " + strings.Join(reasons, "; ")
+	}
+	if c.Encrypted {
+		return "play with a KDM"
+	}
+	return "play"
+}
+
+// job returns the generation Job of a case. The track file ends with the TimeOut of its
+// Subtitle event.
+func (c *MatrixCase) job() Job {
+	j := Job{
+		Text:      c.Profile != "image",
+		Image:     c.Profile == "image",
+		Track:     true,
+		Encrypt:   c.Encrypted,
+		Reel:      c.Reel,
+		Framerate: c.EditRate,
+		Namespace: c.Namespace,
+		Language:  "en",
+		Title:     "Matrix " + strings.Replace(c.Path, string(filepath.Separator), " ", -1),
+	}
+	if c.DisplayType == "ClosedCaption" {
+		j.Display = 1
+	}
+	fps := timecodeRate(toEditRate(c.EditRate))
+	j.Duration = defTimeIn*fps + minDuration
+	if c.Reel == 1 {
+		j.Duration = r1TimeIn*fps + minDuration
+	}
+	return j
+}
+
+// RunMatrix generates every case of a test matrix into its directory and writes an index.csv
+// and README.txt describing the cases and their expected outcome to the output root.
+func RunMatrix(o MatrixOptions) ([]*MatrixCase, error) {
+	cases := o.Cases()
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("empty matrix")
+	}
+	m := &Manifest{Workers: o.Workers, Output: o.Output, Wrapper: o.Wrapper, ASDCP: o.ASDCP}
+	for _, c := range cases {
+		m.Jobs = append(m.Jobs, BatchJob{Name: c.Path, Job: c.job()})
+	}
+	results, err := RunBatch(m)
+	if err != nil {
+		return nil, err
+	}
+	for i, c := range cases {
+		c.Result = results[i]
+	}
+	if err := writeMatrixIndex(filepath.Join(o.Output, "index.csv"), cases); err != nil {
+		return nil, err
+	}
+	return cases, writeMatrixReadme(filepath.Join(o.Output, "README.txt"), o, cases)
+}
+
+// writeMatrixIndex writes one CSV row per case.
+func writeMatrixIndex(filename string, cases []*MatrixCase) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"path", "edit_rate", "namespace", "display_type", "profile", "encrypted", "reel",
+		"document_id", "xml", "mxf", "asset_uuid", "key_id", "key", "expected", "error"})
+	for _, c := range cases {
+		r := c.Result.Result
+		if r == nil {
+			r = &Result{}
+		}
+		w.Write([]string{c.Path, c.EditRate, c.Namespace, c.DisplayType, c.Profile, strconv.FormatBool(c.Encrypted),
+			strconv.Itoa(c.Reel), r.DocumentID, relPath(c.Path, r.XML), relPath(c.Path, r.MXF), r.AssetUUID,
+			r.KeyID, r.Key, c.Expected, c.Result.Error})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// relPath returns the path of a file of a case relative to the matrix root.
+func relPath(dir, file string) string {
+	if file == "" {
+		return ""
+	}
+	return filepath.Join(dir, filepath.Base(file))
+}
+
+// writeMatrixReadme describes the dimensions, layout and outcome of a matrix.
+func writeMatrixReadme(filename string, o MatrixOptions, cases []*MatrixCase) error {
+	var b strings.Builder
+	failed, rejected := 0, 0
+	for _, c := range cases {
+		if c.Result.Error != "" {
+			failed++
+		}
+		if strings.HasPrefix(c.Expected, "reject") {
+			rejected++
+		}
+	}
+	namespaces := make([]string, len(o.Namespaces))
+	for i, ns := range o.Namespaces {
+		namespaces[i] = namespaceName(ns)
+	}
+	var encryption []string
+	for _, e := range o.Encrypted {
+		if e {
+			encryption = append(encryption, "encrypted")
+		} else {
+			encryption = append(encryption, "clear")
+		}
+	}
+	fmt.Fprintf(&b, "empty-tt test matrix\n\n")
+	fmt.Fprintf(&b, "%d cases, %d expected to be rejected, %d failed to generate.\n\n", len(cases), rejected, failed)
+	fmt.Fprintf(&b, "Dimensions\n\n")
+	fmt.Fprintf(&b, "  edit rates     %s\n", strings.Join(o.EditRates, ", "))
+	fmt.Fprintf(&b, "  namespaces     %s\n", strings.Join(namespaces, ", "))
+	fmt.Fprintf(&b, "  display types  %s\n", strings.Join(o.DisplayTypes, ", "))
+	fmt.Fprintf(&b, "  profiles       %s\n", strings.Join(o.Profiles, ", "))
+	fmt.Fprintf(&b, "  encryption     %s\n", strings.Join(encryption, ", "))
+	fmt.Fprintf(&b, "  reels          %s\n\n", strings.Trim(strings.Join(strings.Fields(fmt.Sprint(o.Reels)), ", "), "[]"))
+	fmt.Fprintf(&b, "Layout\n\n")
+	fmt.Fprintf(&b, "  <edit rate>/<namespace>/<display type>/<profile>/<clear|encrypted>/r<reel>/\n\n")
+	fmt.Fprintf(&b, "  Every case holds an XML document, its font or image resource and a track file.\n")
+	fmt.Fprintf(&b, "  index.csv lists every case with its IDs, the content key of encrypted track files,\n")
+	fmt.Fprintf(&b, "  the expected outcome and any generation error.\n\n")
+	fmt.Fprintf(&b, "Expected outcome\n\n")
+	fmt.Fprintf(&b, "  play             the track file is expected to be ingested and displayed.\n")
+	fmt.Fprintf(&b, "  play with a KDM  as above once a KDM for the content key has been ingested.\n")
+	fmt.Fprintf(&b, "  reject: <why>    the track file is expected to be rejected or reported.\n")
+	return ioutil.WriteFile(filename, []byte(b.String()), 0644)
+}
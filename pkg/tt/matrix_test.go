@@ -0,0 +1,164 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatrixCases(t *testing.T) {
+	cases := DefaultMatrix.Cases()
+	if len(cases) != 7*2*2*2*2*2 {
+		t.Fatalf("got %d cases, want %d", len(cases), 7*2*2*2*2*2)
+	}
+	paths := make(map[string]bool)
+	for _, c := range cases {
+		if paths[c.Path] {
+			t.Errorf("duplicate case %s", c.Path)
+		}
+		paths[c.Path] = true
+	}
+	// the last dimension varies fastest.
+	for i, want := range map[int]string{
+		0:                  "24/dcst2010/mainsubtitle/text/clear/r1",
+		1:                  "24/dcst2010/mainsubtitle/text/clear/r2",
+		2:                  "24/dcst2010/mainsubtitle/text/encrypted/r1",
+		len(cases) - 1:     "24000-1001/dcst2014/closedcaption/image/encrypted/r2",
+		len(cases)/7 - 1:   "24/dcst2014/closedcaption/image/encrypted/r2",
+		len(cases) / 7 * 6: "24000-1001/dcst2010/mainsubtitle/text/clear/r1",
+	} {
+		if got := cases[i].Path; got != filepath.FromSlash(want) {
+			t.Errorf("case %d: got %s, want %s", i, got, want)
+		}
+	}
+
+	o := DefaultMatrix
+	o.Reels = nil
+	if cases := o.Cases(); len(cases) != 0 {
+		t.Errorf("got %d cases without reels, want 0", len(cases))
+	}
+	if _, err := RunMatrix(o); err == nil {
+		t.Error("RunMatrix accepted an empty matrix")
+	}
+}
+
+func TestMatrixExpected(t *testing.T) {
+	for _, tc := range []struct {
+		c    MatrixCase
+		want string
+	}{
+		{MatrixCase{EditRate: "24", DisplayType: "MainSubtitle", Profile: "text"}, "play"},
+		{MatrixCase{EditRate: "60", DisplayType: "ClosedCaption", Profile: "text"}, "play"},
+		{MatrixCase{EditRate: "25", DisplayType: "MainSubtitle", Profile: "image", Encrypted: true}, "play with a KDM"},
+		{MatrixCase{EditRate: "24000/1001", DisplayType: "MainSubtitle", Profile: "text", Encrypted: true},
+			"reject: EditRate 24000/1001 is not an RDD 52 edit rate"},
+		{MatrixCase{EditRate: "48", DisplayType: "ClosedCaption", Profile: "image"},
+			"reject: closed captions use the text profile"},
+		{MatrixCase{EditRate: "96", DisplayType: "ClosedCaption", Profile: "image"},
+			"reject: EditRate 96 is not an RDD 52 edit rate; closed captions use the text profile"},
+	} {
+		if got := tc.c.expected(); got != tc.want {
+			t.Errorf("%+v: got %q, want %q", tc.c, got, tc.want)
+		}
+	}
+}
+
+func TestMatrixCaseJob(t *testing.T) {
+	o := MatrixOptions{
+		EditRates:    []string{"25"},
+		Namespaces:   []string{DCST2014},
+		DisplayTypes: []string{"ClosedCaption"},
+		Profiles:     []string{"image"},
+		Encrypted:    []bool{true},
+		Reels:        []int{1, 2},
+	}
+	cases := o.Cases()
+	for i, want := range []int{r1TimeIn*25 + minDuration, defTimeIn*25 + minDuration} {
+		j := cases[i].job()
+		if !j.Image || j.Text || !j.Encrypt || j.Display != 1 || j.Reel != i+1 || j.Namespace != DCST2014 || j.Framerate != "25" {
+			t.Errorf("case %s: got job %+v", cases[i].Path, j)
+		}
+		if j.Duration != want {
+			t.Errorf("case %s: got duration %d, want %d", cases[i].Path, j.Duration, want)
+		}
+	}
+}
+
+func TestRunMatrix(t *testing.T) {
+	o := MatrixOptions{
+		Output:       t.TempDir(),
+		Wrapper:      "native",
+		EditRates:    []string{"25", "24000/1001"},
+		Namespaces:   []string{DCST2014},
+		DisplayTypes: []string{"MainSubtitle", "ClosedCaption"},
+		Profiles:     []string{"text"},
+		Encrypted:    []bool{false},
+		Reels:        []int{1},
+	}
+	cases, err := RunMatrix(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		if c.Result.Error != "" {
+			t.Errorf("case %s: %s", c.Path, c.Result.Error)
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(o.Output, c.Path, filepath.Base(c.Result.Result.MXF))); err != nil {
+			t.Errorf("case %s: %v", c.Path, err)
+		}
+	}
+	f, err := os.Open(filepath.Join(o.Output, "index.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(cases)+1 {
+		t.Fatalf("index.csv has %d rows, want %d", len(rows), len(cases)+1)
+	}
+	for i, c := range cases {
+		row := rows[i+1]
+		if row[0] != c.Path || row[13] != c.Expected || row[9] != relPath(c.Path, c.Result.Result.MXF) {
+			t.Errorf("index.csv row %d = %q", i+1, row)
+		}
+	}
+	readme, err := ioutil.ReadFile(filepath.Join(o.Output, "README.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"4 cases, 2 expected to be rejected, 0 failed to generate.", "edit rates     25, 24000/1001"} {
+		if !strings.Contains(string(readme), want) {
+			t.Errorf("README.txt lacks %q", want)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see <http://www.gnu.org/licenses/>.*/
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+)
+
+// PGS segment types.
+const (
+	pgsPDS = 0x14
+	pgsODS = 0x15
+	pgsPCS = 0x16
+	pgsWDS = 0x17
+	pgsEND = 0x80
+)
+
+const (
+	pgsEpochStart   = 0x80
+	pgsFirstInSeq   = 0x80
+	pgsCroppedFlag  = 0x40
+	pgsSegmentMagic = "PG"
+)
+
+// pgsSegment is a single segment of a PGS stream.
+type pgsSegment struct {
+	pts  int64
+	kind byte
+	data []byte
+}
+
+// pgsCompositionObject places an object within a presentation composition.
+type pgsCompositionObject struct {
+	objectID uint16
+	x, y     int
+	cropped  bool
+	crop     image.Rectangle
+}
+
+// pgsComposition is a presentation composition segment.
+type pgsComposition struct {
+	pts       int64
+	video     image.Point
+	state     byte
+	paletteID byte
+	objects   []pgsCompositionObject
+}
+
+// pgsObject is an object definition, possibly assembled from several segments.
+type pgsObject struct {
+	width, height int
+	rle           []byte
+}
+
+// ImportPGS decodes a Blu-ray PGS (.sup) subtitle stream into an image profile SubtitleReel.
+// Every composition object is written as a UUID named RGBA PNG to the output directory and
+// its position is mapped onto the target DCI container.
+func ImportPGS(filename string, opts BitmapImportOptions) (*SubtitleReel, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadPGS(bufio.NewReader(f), opts)
+}
+
+// ReadPGS decodes a Blu-ray PGS subtitle stream, see ImportPGS.
+func ReadPGS(r io.Reader, opts BitmapImportOptions) (*SubtitleReel, error) {
+	if err := checkFrameRate(opts.Framerate); err != nil {
+		return nil, err
+	}
+	var (
+		placer   *bitmapPlacer
+		comp     *pgsComposition
+		open     []*Image
+		openPTS  int64
+		palettes = make(map[byte]color.Palette)
+		objects  = make(map[uint16]*pgsObject)
+	)
+	for {
+		seg, err := readPGSSegment(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch seg.kind {
+		case pgsPCS:
+			if comp, err = parsePCS(seg); err != nil {
+				return nil, err
+			}
+			if placer == nil {
+				if placer, err = newBitmapPlacer(opts, comp.video); err != nil {
+					return nil, err
+				}
+			}
+			if comp.state&pgsEpochStart != 0 {
+				palettes = make(map[byte]color.Palette)
+				objects = make(map[uint16]*pgsObject)
+			}
+		case pgsPDS:
+			id, pal, err := parsePDS(seg.data)
+			if err != nil {
+				return nil, err
+			}
+			palettes[id] = pal
+		case pgsODS:
+			if err := parseODS(seg.data, objects); err != nil {
+				return nil, err
+			}
+		case pgsWDS:
+			// window definitions are implied by the composition object positions.
+		case pgsEND:
+			if comp == nil {
+				continue
+			}
+			// a new display set ends the event currently shown.
+			placer.add(openPTS, comp.pts, open)
+			open, openPTS = nil, comp.pts
+			for _, o := range comp.objects {
+				obj := objects[o.objectID]
+				if obj == nil {
+					return nil, fmt.Errorf("pgs: composition references undefined object %d", o.objectID)
+				}
+				if obj.width > comp.video.X || obj.height > comp.video.Y {
+					return nil, fmt.Errorf("pgs: object %d exceeds the video size", o.objectID)
+				}
+				img, err := decodePGSObject(obj, palettes[comp.paletteID])
+				if err != nil {
+					return nil, err
+				}
+				at := image.Pt(o.x, o.y)
+				if o.cropped {
+					img = img.SubImage(o.crop.Add(img.Bounds().Min)).(*image.NRGBA)
+				}
+				i, err := placer.image(img, at)
+				if err != nil {
+					return nil, err
+				}
+				if i != nil {
+					open = append(open, i)
+				}
+			}
+			comp = nil
+		}
+	}
+	if placer == nil {
+		return nil, errors.New("pgs: no presentation composition segments found")
+	}
+	if len(open) > 0 {
+		return nil, errors.New("pgs: stream ends before the last composition is cleared")
+	}
+	return placer.reel, nil
+}
+
+// readPGSSegment reads the next segment of a PGS stream.
+func readPGSSegment(r io.Reader) (*pgsSegment, error) {
+	var hdr [13]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("pgs: truncated segment header")
+		}
+		return nil, err
+	}
+	if string(hdr[:2]) != pgsSegmentMagic {
+		return nil, errors.New("pgs: invalid segment magic")
+	}
+	seg := &pgsSegment{
+		pts:  int64(binary.BigEndian.Uint32(hdr[2:])),
+		kind: hdr[10],
+		data: make([]byte, binary.BigEndian.Uint16(hdr[11:])),
+	}
+	if _, err := io.ReadFull(r, seg.data); err != nil {
+		return nil, errors.New("pgs: truncated segment")
+	}
+	return seg, nil
+}
+
+// parsePCS parses a presentation composition segment.
+func parsePCS(seg *pgsSegment) (*pgsComposition, error) {
+	d := seg.data
+	if len(d) < 11 {
+		return nil, errors.New("pgs: truncated presentation composition segment")
+	}
+	c := &pgsComposition{
+		pts:       seg.pts,
+		video:     image.Pt(int(binary.BigEndian.Uint16(d[0:])), int(binary.BigEndian.Uint16(d[2:]))),
+		state:     d[7],
+		paletteID: d[9],
+	}
+	if c.video.X == 0 || c.video.Y == 0 || c.video.X > Container4K.X || c.video.Y > Container4K.Y {
+		return nil, errors.New("pgs: invalid video size")
+	}
+	n := int(d[10])
+	p := 11
+	for i := 0; i < n; i++ {
+		if len(d) < p+8 {
+			return nil, errors.New("pgs: truncated composition object")
+		}
+		o := pgsCompositionObject{
+			objectID: binary.BigEndian.Uint16(d[p:]),
+			cropped:  d[p+3]&pgsCroppedFlag != 0,
+			x:        int(binary.BigEndian.Uint16(d[p+4:])),
+			y:        int(binary.BigEndian.Uint16(d[p+6:])),
+		}
+		p += 8
+		if o.cropped {
+			if len(d) < p+8 {
+				return nil, errors.New("pgs: truncated composition object")
+			}
+			x := int(binary.BigEndian.Uint16(d[p:]))
+			y := int(binary.BigEndian.Uint16(d[p+2:]))
+			o.crop = image.Rect(x, y, x+int(binary.BigEndian.Uint16(d[p+4:])), y+int(binary.BigEndian.Uint16(d[p+6:])))
+			p += 8
+		}
+		c.objects = append(c.objects, o)
+	}
+	return c, nil
+}
+
+// parsePDS parses a palette definition segment.
+func parsePDS(d []byte) (byte, color.Palette, error) {
+	if len(d) < 2 {
+		return 0, nil, errors.New("pgs: truncated palette definition segment")
+	}
+	pal := make(color.Palette, 256)
+	for i := range pal {
+		pal[i] = color.NRGBA{}
+	}
+	for p := 2; p+5 <= len(d); p += 5 {
+		pal[d[p]] = ycbcrToNRGBA(d[p+1], d[p+3], d[p+2], d[p+4])
+	}
+	return d[0], pal, nil
+}
+
+// parseODS parses an object definition segment, appending to an object spanning several segments.
+func parseODS(d []byte, objects map[uint16]*pgsObject) error {
+	if len(d) < 4 {
+		return errors.New("pgs: truncated object definition segment")
+	}
+	id := binary.BigEndian.Uint16(d)
+	if d[3]&pgsFirstInSeq != 0 {
+		// the 24-bit object data length is implied by the segment sizes.
+		if len(d) < 11 {
+			return errors.New("pgs: truncated object definition segment")
+		}
+		objects[id] = &pgsObject{
+			width:  int(binary.BigEndian.Uint16(d[7:])),
+			height: int(binary.BigEndian.Uint16(d[9:])),
+			rle:    append([]byte(nil), d[11:]...),
+		}
+		return nil
+	}
+	obj := objects[id]
+	if obj == nil {
+		return fmt.Errorf("pgs: continuation of undefined object %d", id)
+	}
+	obj.rle = append(obj.rle, d[4:]...)
+	return nil
+}
+
+// decodePGSObject decodes the run-length encoded bitmap of an object using the given palette.
+func decodePGSObject(obj *pgsObject, pal color.Palette) (*image.NRGBA, error) {
+	if pal == nil {
+		return nil, errors.New("pgs: composition references undefined palette")
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, obj.width, obj.height))
+	d := obj.rle
+	x, y := 0, 0
+	put := func(n int, c byte) {
+		col := pal[c].(color.NRGBA)
+		for ; n > 0 && x < obj.width; n-- {
+			img.SetNRGBA(x, y, col)
+			x++
+		}
+	}
+	for p := 0; p < len(d) && y < obj.height; {
+		b := d[p]
+		p++
+		if b != 0 {
+			put(1, b)
+			continue
+		}
+		if p >= len(d) {
+			break
+		}
+		f := d[p]
+		p++
+		switch {
+		case f == 0:
+			// end of line.
+			x = 0
+			y++
+		case f&0xC0 == 0x00:
+			put(int(f&0x3F), 0)
+		case f&0xC0 == 0x40:
+			if p >= len(d) {
+				return nil, errors.New("pgs: truncated run length data")
+			}
+			put(int(f&0x3F)<<8|int(d[p]), 0)
+			p++
+		case f&0xC0 == 0x80:
+			if p >= len(d) {
+				return nil, errors.New("pgs: truncated run length data")
+			}
+			put(int(f&0x3F), d[p])
+			p++
+		default:
+			if p+1 >= len(d) {
+				return nil, errors.New("pgs: truncated run length data")
+			}
+			put(int(f&0x3F)<<8|int(d[p]), d[p+1])
+			p += 2
+		}
+	}
+	return img, nil
+}
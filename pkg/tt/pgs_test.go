@@ -0,0 +1,166 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pgsTestSegment returns a PGS segment of the given kind and presentation timestamp.
+func pgsTestSegment(pts uint32, kind byte, data []byte) []byte {
+	seg := make([]byte, 13, 13+len(data))
+	copy(seg, pgsSegmentMagic)
+	binary.BigEndian.PutUint32(seg[2:], pts)
+	seg[10] = kind
+	binary.BigEndian.PutUint16(seg[11:], uint16(len(data)))
+	return append(seg, data...)
+}
+
+// pgsTestRLE run-length encodes a w by h bitmap of palette index c with a transparent first line.
+func pgsTestRLE(w, h int, c byte) []byte {
+	rle := []byte{0, 0x40 | byte(w>>8), byte(w), 0, 0}
+	for y := 1; y < h; y++ {
+		rle = append(rle, 0, 0xC0|byte(w>>8), byte(w), c, 0, 0)
+	}
+	return rle
+}
+
+// pgsTestStream returns a PGS stream showing a single w by h object at x, y on a 1920x1080 video
+// from pts in to out.
+func pgsTestStream(in, out uint32, x, y, w, h int) []byte {
+	var b bytes.Buffer
+	pcs := []byte{0x07, 0x80, 0x04, 0x38, 0x10, 0, 0, pgsEpochStart, 0, 0, 1, 0, 1, 0, 0}
+	pcs = append(pcs, byte(x>>8), byte(x), byte(y>>8), byte(y))
+	b.Write(pgsTestSegment(in, pgsPCS, pcs))
+	b.Write(pgsTestSegment(in, pgsWDS, []byte{1, 0, 0, byte(x >> 8), byte(x), byte(y >> 8), byte(y), byte(w >> 8), byte(w), byte(h >> 8), byte(h)}))
+	// palette entry 1 is opaque white.
+	b.Write(pgsTestSegment(in, pgsPDS, []byte{0, 0, 1, 235, 128, 128, 255}))
+	rle := pgsTestRLE(w, h, 1)
+	ods := []byte{0, 1, 0, pgsFirstInSeq | 0x40, 0, 0, 0, byte(w >> 8), byte(w), byte(h >> 8), byte(h)}
+	// split the object data over two segments.
+	b.Write(pgsTestSegment(in, pgsODS, append(ods, rle[:len(rle)/2]...)))
+	b.Write(pgsTestSegment(in, pgsODS, append([]byte{0, 1, 0, 0x40}, rle[len(rle)/2:]...)))
+	b.Write(pgsTestSegment(in, pgsEND, nil))
+	// clear the display.
+	b.Write(pgsTestSegment(out, pgsPCS, []byte{0x07, 0x80, 0x04, 0x38, 0x10, 0, 1, 0, 0, 0, 0}))
+	b.Write(pgsTestSegment(out, pgsEND, nil))
+	return b.Bytes()
+}
+
+func TestReadPGS(t *testing.T) {
+	dir := t.TempDir()
+	stream := pgsTestStream(90000, 3*90000, 100, 900, 20, 10)
+	s, err := ReadPGS(bytes.NewReader(stream), BitmapImportOptions{Output: dir, Framerate: "24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs := subtitles(s)
+	if len(subs) != 1 || len(subs[0].Image) != 1 {
+		t.Fatalf("got %d events, want a single event with one image", len(subs))
+	}
+	sub := subs[0]
+	if sub.TimeIn != "00:00:01:00" || sub.TimeOut != "00:00:03:00" {
+		t.Errorf("got %s-%s, want 00:00:01:00-00:00:03:00", sub.TimeIn, sub.TimeOut)
+	}
+	// the 1920x1080 video is centred in the 2048x1080 container, the transparent first line is cropped.
+	i := sub.Image[0]
+	if i.Hposition != "8.01" || i.Vposition != "83.43" || i.Halign != "left" || i.Valign != "top" {
+		t.Errorf("got image at %s %s, %s %s", i.Halign, i.Hposition, i.Valign, i.Vposition)
+	}
+	f, err := os.Open(filepath.Join(dir, strings.TrimPrefix(i.Image, urn)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != 20 || size.Y != 9 {
+		t.Errorf("got image size %v, want 20x9", size)
+	}
+	if c := color.NRGBAModel.Convert(img.At(5, 5)).(color.NRGBA); c != (color.NRGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("got pixel colour %v, want opaque white", c)
+	}
+	if findings := CheckImage(f.Name(), ImageCheckOptions{}); len(findings) != 0 {
+		t.Errorf("decoded image has findings: %v", findings)
+	}
+}
+
+// invalidFramerates are rejected by the bitmap imports.
+var invalidFramerates = []string{"", "0", "-24", "abc", "24/0", "0/1001", "23.976"}
+
+func TestReadPGSRejectsInvalidFramerates(t *testing.T) {
+	stream := pgsTestStream(90000, 3*90000, 100, 900, 20, 10)
+	for _, rate := range invalidFramerates {
+		if _, err := ReadPGS(bytes.NewReader(stream), BitmapImportOptions{Output: t.TempDir(), Framerate: rate}); err == nil {
+			t.Errorf("ReadPGS accepted frame rate %q", rate)
+		}
+	}
+}
+
+// malformedPGS returns the PGS streams that ReadPGS must reject.
+func malformedPGS() map[string][]byte {
+	stream := pgsTestStream(90000, 3*90000, 100, 900, 20, 10)
+	// an object of 65535x65535 pixels.
+	huge := append([]byte(nil), stream...)
+	ods := bytes.Index(huge, []byte{0, 1, 0, pgsFirstInSeq | 0x40})
+	copy(huge[ods+7:], []byte{0xFF, 0xFF, 0xFF, 0xFF})
+	return map[string][]byte{
+		"empty":        nil,
+		"magic":        []byte("PG"),
+		"not a stream": []byte("not a PGS stream"),
+		"huge object":  huge,
+	}
+}
+
+func TestReadPGSMalformed(t *testing.T) {
+	dir := t.TempDir()
+	for name, in := range malformedPGS() {
+		if _, err := ReadPGS(bytes.NewReader(in), BitmapImportOptions{Output: dir, Framerate: "24"}); err == nil {
+			t.Errorf("%s: ReadPGS accepted % x", name, in)
+		}
+	}
+}
+
+func FuzzReadPGS(f *testing.F) {
+	stream := pgsTestStream(90000, 3*90000, 100, 900, 20, 10)
+	f.Add(stream)
+	for n := 1; n < len(stream); n += 7 {
+		f.Add(stream[:n])
+	}
+	for _, in := range malformedPGS() {
+		f.Add(in)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ReadPGS(bytes.NewReader(data), BitmapImportOptions{Output: t.TempDir(), Framerate: "24"})
+	})
+}
@@ -0,0 +1,259 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see <http://www.gnu.org/licenses/>.*/
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	pngSignature  = "\x89PNG\r\n\x1a\n"
+	pngBitDepth   = 8
+	pngColorRGBA  = 6
+	pngInterlaced = 1
+)
+
+// ImageCheckOptions configures CheckImages.
+type ImageCheckOptions struct {
+	// Resources is the directory holding the UUID named image resources.
+	Resources string
+	// MaxSize is the largest permitted image size in pixels. Defaults to Container2K.
+	MaxSize image.Point
+	// MaxFileSize is the largest permitted file size in bytes. Zero disables the check.
+	MaxFileSize int64
+	// Fix signals that non-conforming images are to be re-encoded in place as 8-bit RGBA
+	// non-interlaced PNGs. The canvas is kept, as cropping would move the image on screen.
+	Fix bool
+}
+
+// pngHeader holds the IHDR fields of a PNG file.
+type pngHeader struct {
+	width, height int
+	bitDepth      byte
+	colorType     byte
+	interlace     byte
+}
+
+// CheckImages checks every Image resource referenced by a SubtitleReel. It confirms that each file
+// exists, is a valid 8-bit RGBA non-interlaced PNG within the size limits, and is cropped to the
+// bounding box of its non-transparent pixels. Errors resolved by Fix are reported as warnings
+// marked "(fixed)".
+func CheckImages(s *SubtitleReel, opts ImageCheckOptions) []Finding {
+	if opts.MaxSize == (image.Point{}) {
+		opts.MaxSize = Container2K
+	}
+	var findings []Finding
+	seen := make(map[string]bool)
+	for _, sub := range subtitles(s) {
+		for _, i := range sub.images() {
+			ref := imageContent(i)
+			if seen[ref] {
+				continue
+			}
+			seen[ref] = true
+			findings = append(findings, CheckImage(resourcePath(opts.Resources, ref), opts)...)
+		}
+	}
+	return findings
+}
+
+// CheckImage checks a single PNG image resource, see CheckImages.
+func CheckImage(filename string, opts ImageCheckOptions) []Finding {
+	if opts.MaxSize == (image.Point{}) {
+		opts.MaxSize = Container2K
+	}
+	var findings []Finding
+	report := func(severity, format string, a ...interface{}) {
+		findings = append(findings, Finding{
+			Severity: severity,
+			Location: filename,
+			Message:  fmt.Sprintf(format, a...),
+		})
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		report(SeverityError, "unable to read image resource: %s", err)
+		return findings
+	}
+	hdr, err := readPNGHeader(data)
+	if err != nil {
+		report(SeverityError, "%s", err)
+		return findings
+	}
+
+	// fixable holds the findings resolved by re-encoding the image.
+	var fixable []int
+	if hdr.bitDepth != pngBitDepth || hdr.colorType != pngColorRGBA {
+		fixable = append(fixable, len(findings))
+		report(SeverityError, "PNG is not 8-bit RGBA: bit depth %d, color type %d", hdr.bitDepth, hdr.colorType)
+	}
+	if hdr.interlace == pngInterlaced {
+		fixable = append(fixable, len(findings))
+		report(SeverityError, "PNG is interlaced")
+	}
+	if opts.MaxFileSize > 0 && int64(len(data)) > opts.MaxFileSize {
+		report(SeverityError, "file size %d exceeds %d bytes", len(data), opts.MaxFileSize)
+	}
+	// the size is checked from the header, an oversized image is not decoded.
+	if hdr.width > opts.MaxSize.X || hdr.height > opts.MaxSize.Y {
+		report(SeverityError, "image size %dx%d exceeds %dx%d", hdr.width, hdr.height, opts.MaxSize.X, opts.MaxSize.Y)
+		return findings
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		report(SeverityError, "invalid PNG: %s", err)
+		return findings
+	}
+	bbox := alphaBounds(img)
+	switch {
+	case bbox.Empty():
+		report(SeverityWarning, "image is fully transparent")
+	case bbox != img.Bounds():
+		report(SeverityWarning, "image %v is not cropped to its alpha bounding box %v", img.Bounds().Size(), bbox)
+	}
+
+	if opts.Fix && len(fixable) > 0 {
+		if err := writeRGBA(filename, img); err != nil {
+			report(SeverityError, "unable to re-encode image: %s", err)
+			return findings
+		}
+		for _, i := range fixable {
+			findings[i].Severity = SeverityWarning
+			findings[i].Message += " (fixed)"
+		}
+	}
+	return findings
+}
+
+// readPNGHeader returns the IHDR fields of a PNG file.
+func readPNGHeader(data []byte) (*pngHeader, error) {
+	if len(data) < 33 || string(data[:8]) != pngSignature {
+		return nil, fmt.Errorf("not a PNG file")
+	}
+	if string(data[12:16]) != "IHDR" {
+		return nil, fmt.Errorf("invalid PNG: missing IHDR chunk")
+	}
+	return &pngHeader{
+		width:     int(binary.BigEndian.Uint32(data[16:])),
+		height:    int(binary.BigEndian.Uint32(data[20:])),
+		bitDepth:  data[24],
+		colorType: data[25],
+		interlace: data[28],
+	}, nil
+}
+
+// alphaBounds returns the bounding box of the non-transparent pixels of an image.
+func alphaBounds(img image.Image) image.Rectangle {
+	b := img.Bounds()
+	box := image.Rectangle{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
+				box = box.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return box
+}
+
+// writeRGBA writes an image to filename as an 8-bit RGBA non-interlaced PNG of the same size.
+func writeRGBA(filename string, img image.Image) error {
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := encodeRGBA(f, dst); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// encodeRGBA writes an image as an 8-bit RGBA PNG. Unlike png.Encode, the colour type is kept
+// for fully opaque images.
+func encodeRGBA(w io.Writer, img *image.NRGBA) error {
+	b := img.Bounds()
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(b.Dx()))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(b.Dy()))
+	ihdr[8] = pngBitDepth
+	ihdr[9] = pngColorRGBA
+
+	var idat bytes.Buffer
+	z := zlib.NewWriter(&idat)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, y):img.PixOffset(b.Max.X, y)]
+		if _, err := z.Write(append([]byte{0}, row...)); err != nil {
+			return err
+		}
+	}
+	if err := z.Close(); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, pngSignature); err != nil {
+		return err
+	}
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{{"IHDR", ihdr}, {"IDAT", idat.Bytes()}, {"IEND", nil}} {
+		if err := writeChunk(w, c.name, c.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeChunk writes a single PNG chunk.
+func writeChunk(w io.Writer, name string, data []byte) error {
+	var hdr [8]byte
+	binary.BigEndian.PutUint32(hdr[:4], uint32(len(data)))
+	copy(hdr[4:], name)
+	crc := crc32.NewIEEE()
+	crc.Write(hdr[4:])
+	crc.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	for _, b := range [][]byte{hdr[:], data, sum[:]} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
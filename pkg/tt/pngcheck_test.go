@@ -0,0 +1,133 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckImageFix(t *testing.T) {
+	// a fully opaque greyscale image is not RGBA but needs no cropping.
+	img := image.NewGray(image.Rect(0, 0, 40, 20))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+	filename := filepath.Join(t.TempDir(), "grey.png")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	findings := CheckImage(filename, ImageCheckOptions{Fix: true})
+	if HasErrors(findings) {
+		t.Fatalf("errors left after fix: %v", findings)
+	}
+	var fixed int
+	for _, f := range findings {
+		if strings.HasSuffix(f.Message, " (fixed)") {
+			fixed++
+		}
+	}
+	if fixed != 1 {
+		t.Errorf("got %d fixed findings, want 1: %v", fixed, findings)
+	}
+	if findings := CheckImage(filename, ImageCheckOptions{}); len(findings) != 0 {
+		t.Errorf("re-encoded image has findings: %v", findings)
+	}
+}
+
+func TestCheckImageFixKeepsCanvas(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	img.Set(10, 5, color.NRGBA{R: 0xFF, A: 0xFF})
+	filename := filepath.Join(t.TempDir(), "uncropped.png")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := encodeRGBA(f, img); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	before, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings := CheckImage(filename, ImageCheckOptions{Fix: true})
+	if len(findings) != 1 || findings[0].Severity != SeverityWarning || strings.HasSuffix(findings[0].Message, "(fixed)") {
+		t.Errorf("got %v, want a single unfixed cropping warning", findings)
+	}
+	after, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != string(after) {
+		t.Errorf("uncropped image was rewritten")
+	}
+}
+
+func TestCheckImageSizeFromHeader(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+	filename := filepath.Join(t.TempDir(), "large.png")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := encodeRGBA(f, img); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	findings := CheckImage(filename, ImageCheckOptions{MaxSize: image.Pt(20, 20)})
+	if len(findings) != 1 || findings[0].Message != "image size 40x20 exceeds 20x20" {
+		t.Errorf("got %v, want a single size error", findings)
+	}
+
+	// a header claiming a huge image is reported without decoding the image data.
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[16], data[17], data[18], data[19] = 0x00, 0x10, 0x00, 0x00
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	findings = CheckImage(filename, ImageCheckOptions{})
+	if len(findings) != 1 || findings[0].Message != "image size 1048576x20 exceeds 2048x1080" {
+		t.Errorf("got %v, want a single size error", findings)
+	}
+}
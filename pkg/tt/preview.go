@@ -0,0 +1,629 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see <http://www.gnu.org/licenses/>.*/
+
+import (
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// DCI container sizes available to the preview renderer.
+var (
+	Container2K = image.Pt(2048, 1080)
+	Container4K = image.Pt(4096, 2160)
+)
+
+const (
+	defaultFontSize = 42
+	previewIndex    = "index.html"
+)
+
+// PreviewOptions configures the output of Preview.
+type PreviewOptions struct {
+	// Output is the target output directory for the HTML page and PNG frames.
+	Output string
+	// Frames signals that a PNG frame is to be rendered for every Subtitle event.
+	Frames bool
+	// Container is the frame size in pixels, typically Container2K or Container4K.
+	Container image.Point
+	// Resources is the directory holding the UUID named font and image resources.
+	Resources string
+	// Stereo signals that frames are rendered side by side for the left and right eye, each
+	// offset by half the parallax of the Zposition or VariableZ of its elements.
+	Stereo bool
+}
+
+// previewEvent is the template data of a single Subtitle event.
+type previewEvent struct {
+	Index      int
+	SpotNumber string
+	TimeIn     string
+	TimeOut    string
+	Content    string
+	Depth      string
+	Left       float64
+	Width      float64
+	Frame      string
+}
+
+// Preview renders a SubtitleReel into a static HTML page with a timeline and event list,
+// and optionally into per-event PNG frames.
+func Preview(s *SubtitleReel, opts PreviewOptions) error {
+	if opts.Container == (image.Point{}) {
+		opts.Container = Container2K
+	}
+	if err := os.MkdirAll(opts.Output, 0755); err != nil {
+		return err
+	}
+	var r *renderer
+	if opts.Frames {
+		r = newRenderer(s, opts)
+	}
+
+	rate := getEditRate(s.EditRate)
+	var events []previewEvent
+	last := 0
+	for i, sub := range subtitles(s) {
+		in, out := 0, 0
+		if tc, err := ParseTimecode(sub.TimeIn, rate); err == nil {
+			in = tc.Frames()
+		}
+		if tc, err := ParseTimecode(sub.TimeOut, rate); err == nil {
+			out = tc.Frames()
+		}
+		if out > last {
+			last = out
+		}
+		e := previewEvent{
+			Index:      i + 1,
+			SpotNumber: sub.SpotNumber,
+			TimeIn:     sub.TimeIn,
+			TimeOut:    sub.TimeOut,
+			Content:    subtitleContent(sub),
+			Depth:      subtitleDepth(sub),
+			Left:       float64(in),
+			Width:      float64(out - in),
+		}
+		if r != nil {
+			e.Frame = fmt.Sprintf("%04d.png", i+1)
+			if err := r.renderFile(sub, filepath.Join(opts.Output, e.Frame)); err != nil {
+				return err
+			}
+		}
+		events = append(events, e)
+	}
+	// scale the timeline to a percentage of the last TimeOut.
+	if last > 0 {
+		for i := range events {
+			events[i].Left = events[i].Left * 100 / float64(last)
+			events[i].Width = events[i].Width * 100 / float64(last)
+		}
+	}
+
+	f, err := os.Create(filepath.Join(opts.Output, previewIndex))
+	if err != nil {
+		return err
+	}
+	data := struct {
+		Reel   *SubtitleReel
+		Events []previewEvent
+	}{s, events}
+	if err := previewTemplate.Execute(f, data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// RenderFrame renders a single Subtitle event of a SubtitleReel onto a transparent frame.
+func RenderFrame(s *SubtitleReel, sub *Subtitle, opts PreviewOptions) (*image.RGBA, error) {
+	if opts.Container == (image.Point{}) {
+		opts.Container = Container2K
+	}
+	return newRenderer(s, opts).render(sub)
+}
+
+// subtitles returns the Subtitle events of a SubtitleReel, see walkSubtitles.
+func subtitles(s *SubtitleReel) []*Subtitle {
+	var subs []*Subtitle
+	walkSubtitles(s, func(sub *Subtitle, fonts []*Font) {
+		subs = append(subs, sub)
+	})
+	return subs
+}
+
+// walkSubtitles calls fn for every Subtitle event of a SubtitleReel with the Font elements
+// enclosing it, outermost first. The Subtitle children of the SubtitleList and of each Font are
+// visited before its Font children, in the order they are encoded.
+func walkSubtitles(s *SubtitleReel, fn func(sub *Subtitle, fonts []*Font)) {
+	if s.SubtitleList == nil {
+		return
+	}
+	var walk func(subs []*Subtitle, children []*Font, fonts []*Font)
+	walk = func(subs []*Subtitle, children []*Font, fonts []*Font) {
+		for _, sub := range subs {
+			if sub != nil {
+				fn(sub, fonts)
+			}
+		}
+		for _, f := range children {
+			if f != nil {
+				walk(f.Subtitle, f.Font, append(fonts[:len(fonts):len(fonts)], f))
+			}
+		}
+	}
+	walk(s.SubtitleList.Subtitle, s.SubtitleList.Font, nil)
+}
+
+// texts returns the Text elements of a Subtitle, including those held by its Font children.
+func (sub *Subtitle) texts() []*Text {
+	texts := append([]*Text(nil), sub.Text...)
+	walkNestedFonts(sub.Font, func(f *NestedFont) {
+		texts = append(texts, f.Texts...)
+	})
+	return texts
+}
+
+// images returns the Image elements of a Subtitle, including those held by its Font children.
+func (sub *Subtitle) images() []*Image {
+	images := append([]*Image(nil), sub.Image...)
+	walkNestedFonts(sub.Font, func(f *NestedFont) {
+		images = append(images, f.Image...)
+	})
+	return images
+}
+
+// walkNestedFonts calls fn for every NestedFont of fonts and their Font children, parents first.
+func walkNestedFonts(fonts []*NestedFont, fn func(f *NestedFont)) {
+	for _, f := range fonts {
+		if f != nil {
+			fn(f)
+			walkNestedFonts(f.Font, fn)
+		}
+	}
+}
+
+// subtitleContent returns a one line summary of the Text and Image content of a Subtitle.
+func subtitleContent(sub *Subtitle) string {
+	var parts []string
+	for _, t := range sub.texts() {
+		parts = append(parts, textContent(t))
+	}
+	for _, i := range sub.images() {
+		parts = append(parts, "["+imageContent(i)+"]")
+	}
+	return strings.Join(parts, " / ")
+}
+
+// subtitleDepth returns a one line summary of the Zposition and VariableZ of a Subtitle.
+func subtitleDepth(sub *Subtitle) string {
+	var parts []string
+	add := func(zposition, variableZ string) {
+		switch {
+		case variableZ != "":
+			parts = append(parts, variableZ)
+		case zposition != "":
+			parts = append(parts, zposition)
+		}
+	}
+	for _, t := range sub.texts() {
+		add(t.Zposition, t.VariableZ)
+	}
+	for _, i := range sub.images() {
+		add(i.Zposition, i.VariableZ)
+	}
+	return strings.Join(parts, " / ")
+}
+
+// renderer draws Subtitle events of a single SubtitleReel.
+type renderer struct {
+	opts   PreviewOptions
+	styles map[*Subtitle]*NestedFont
+	fonts  map[string]*sfnt.Font
+	curves map[string][]ZPoint
+}
+
+// newRenderer loads the font resources referenced by a SubtitleReel.
+func newRenderer(s *SubtitleReel, opts PreviewOptions) *renderer {
+	r := &renderer{
+		opts:   opts,
+		styles: make(map[*Subtitle]*NestedFont),
+		fonts:  make(map[string]*sfnt.Font),
+		curves: variableZCurves(s),
+	}
+	walkSubtitles(s, func(sub *Subtitle, fonts []*Font) {
+		var style *NestedFont
+		for _, f := range fonts {
+			style = mergeFont(style, fontAttributes(f))
+		}
+		r.styles[sub] = style
+	})
+	for _, l := range s.LoadFont {
+		if data, err := ioutil.ReadFile(resourcePath(opts.Resources, l.Font)); err == nil {
+			if f, err := opentype.Parse(data); err == nil {
+				r.fonts[l.ID] = f
+			}
+		}
+	}
+	return r
+}
+
+// renderFile renders a Subtitle event and writes it as PNG to filename.
+func (r *renderer) renderFile(sub *Subtitle, filename string) error {
+	img, err := r.render(sub)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// render draws the Text and Image children of a Subtitle event, side by side for both eyes
+// when stereo frames are requested.
+func (r *renderer) render(sub *Subtitle) (*image.RGBA, error) {
+	if !r.opts.Stereo {
+		return r.renderEye(sub, 0)
+	}
+	size := r.opts.Container
+	img := image.NewRGBA(image.Rect(0, 0, 2*size.X, size.Y))
+	for n, eye := range []int{-1, 1} {
+		src, err := r.renderEye(sub, eye)
+		if err != nil {
+			return nil, err
+		}
+		draw.Draw(img, src.Bounds().Add(image.Pt(n*size.X, 0)), src, image.Point{}, draw.Src)
+	}
+	return img, nil
+}
+
+// renderEye draws the Text and Image children of a Subtitle event for the left (-1) or right (1)
+// eye, or for a single view (0). Depth is taken at the first frame of the event.
+func (r *renderer) renderEye(sub *Subtitle, eye int) (*image.RGBA, error) {
+	size := r.opts.Container
+	img := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	if err := r.drawContent(img, sub.Text, sub.Image, sub.Font, r.styles[sub], eye); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// drawContent draws Text and Image elements with the given font attributes, followed by the
+// content of each Font child with its attributes applied.
+func (r *renderer) drawContent(dst *image.RGBA, texts []*Text, images []*Image, fonts []*NestedFont, style *NestedFont, eye int) error {
+	size := r.opts.Container
+	for _, t := range texts {
+		dx := parallax(depthAt(t.Zposition, t.VariableZ, r.curves, 0), eye, size.X)
+		if err := r.drawText(dst, t, mergeFont(style, t.firstFont()), dx); err != nil {
+			return err
+		}
+	}
+	for _, i := range images {
+		dx := parallax(depthAt(i.Zposition, i.VariableZ, r.curves, 0), eye, size.X)
+		if err := r.drawImage(dst, i, dx); err != nil {
+			return err
+		}
+	}
+	for _, f := range fonts {
+		if f == nil {
+			continue
+		}
+		if err := r.drawContent(dst, f.Texts, f.Image, f.Font, mergeFont(style, f), eye); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parallax returns the horizontal offset in pixels of an element at depth z for the given eye.
+// Each eye carries half the parallax, so elements in front of the screen, with a negative z,
+// move right in the left eye and left in the right eye.
+func parallax(z float64, eye, width int) int {
+	return int(math.Round(float64(eye) * z * float64(width) / 200))
+}
+
+// drawText draws a Text element using the given effective font attributes, offset
+// horizontally by dx pixels.
+func (r *renderer) drawText(dst *image.RGBA, t *Text, style *NestedFont, dx int) error {
+	text := strings.TrimSpace(t.Content())
+	if text == "" {
+		return nil
+	}
+	face, err := r.face(style, text)
+	if err != nil {
+		return err
+	}
+	defer face.Close()
+
+	lines := strings.Split(text, "\n")
+	m := face.Metrics()
+	lineHeight := m.Height.Ceil()
+	blockHeight := lineHeight * len(lines)
+	ascent := m.Ascent.Ceil()
+
+	size := dst.Bounds().Size()
+	top := vertical(t.Valign, t.Vposition, size.Y, blockHeight, ascent, len(lines), lineHeight)
+	fill := image.NewUniform(parseColor(style.Color, color.White))
+	effect := image.NewUniform(parseColor(style.EffectColor, color.Black))
+	effectSize := int(getFloat(style.EffectSize))
+	if effectSize == 0 {
+		effectSize = 1
+	}
+	effectSize = effectSize * size.Y / Container2K.Y
+
+	for n, line := range lines {
+		d := &font.Drawer{Dst: dst, Face: face}
+		width := d.MeasureString(line).Ceil()
+		x := horizontal(t.Halign, t.Hposition, size.X, width) + dx
+		y := top + ascent + n*lineHeight
+		switch strings.ToLower(style.Effect) {
+		case "border":
+			d.Src = effect
+			for dy := -effectSize; dy <= effectSize; dy++ {
+				for dx := -effectSize; dx <= effectSize; dx++ {
+					if dx == 0 && dy == 0 {
+						continue
+					}
+					d.Dot = fixed.P(x+dx, y+dy)
+					d.DrawString(line)
+				}
+			}
+		case "shadow":
+			d.Src = effect
+			d.Dot = fixed.P(x+effectSize, y+effectSize)
+			d.DrawString(line)
+		}
+		d.Src = fill
+		d.Dot = fixed.P(x, y)
+		d.DrawString(line)
+	}
+	return nil
+}
+
+// drawImage draws an Image element referenced by URN from the resource directory, offset
+// horizontally by dx pixels.
+func (r *renderer) drawImage(dst *image.RGBA, i *Image, dx int) error {
+	f, err := os.Open(resourcePath(r.opts.Resources, i.Image))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	src, err := png.Decode(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", i.Image, err)
+	}
+	size := dst.Bounds().Size()
+	b := src.Bounds()
+	// image subtitles are authored against a 2K container.
+	w := b.Dx() * size.X / Container2K.X
+	h := b.Dy() * size.Y / Container2K.Y
+	x := horizontal(i.Halign, i.Hposition, size.X, w) + dx
+	y := vertical(i.Valign, i.Vposition, size.Y, h, h, 1, h)
+	rect := image.Rect(x, y, x+w, y+h)
+	if w == b.Dx() && h == b.Dy() {
+		draw.Draw(dst, rect, src, b.Min, draw.Over)
+		return nil
+	}
+	// nearest neighbour scaling keeps the renderer dependency free.
+	for py := 0; py < h; py++ {
+		for px := 0; px < w; px++ {
+			c := src.At(b.Min.X+px*b.Dx()/w, b.Min.Y+py*b.Dy()/h)
+			dst.Set(x+px, y+py, blendOver(dst.At(x+px, y+py), c))
+		}
+	}
+	return nil
+}
+
+// face returns a font face for the effective font attributes. The Go fonts are used when
+// the document font cannot be resolved or does not cover the given text.
+func (r *renderer) face(style *NestedFont, text string) (font.Face, error) {
+	f := r.fonts[style.ID]
+	if f == nil && len(r.fonts) == 1 {
+		for _, v := range r.fonts {
+			f = v
+		}
+	}
+	if f == nil || missingGlyphs(f, text) != "" {
+		var err error
+		if f, err = opentype.Parse(fallbackFont(style)); err != nil {
+			return nil, err
+		}
+	}
+	size := getFloat(style.Size)
+	if size == 0 {
+		size = defaultFontSize
+	}
+	// font size is expressed in points against a screen height of 11 inches.
+	px := size * float64(r.opts.Container.Y) / (72 * 11)
+	return opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    px,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+}
+
+// fallbackFont returns the Go font matching the Weight and Italic attributes.
+func fallbackFont(style *NestedFont) []byte {
+	bold := strings.EqualFold(style.Weight, "bold")
+	italic := strings.EqualFold(style.Italic, "yes")
+	switch {
+	case bold && italic:
+		return gobolditalic.TTF
+	case bold:
+		return gobold.TTF
+	case italic:
+		return goitalic.TTF
+	}
+	return goregular.TTF
+}
+
+// horizontal returns the left edge of an element of the given width for Halign and Hposition.
+func horizontal(halign, hposition string, width, w int) int {
+	offset := int(getFloat(hposition) * float64(width) / 100)
+	switch strings.ToLower(halign) {
+	case "left":
+		return offset
+	case "right":
+		return width - offset - w
+	}
+	return (width-w)/2 + offset
+}
+
+// vertical returns the top edge of an element of the given height for Valign and Vposition.
+// Bottom aligned text is positioned by the baseline of its last line.
+func vertical(valign, vposition string, height, h, ascent, lines, lineHeight int) int {
+	offset := int(getFloat(vposition) * float64(height) / 100)
+	switch strings.ToLower(valign) {
+	case "top":
+		return offset
+	case "bottom":
+		return height - offset - ascent - (lines-1)*lineHeight
+	}
+	return (height-h)/2 + offset
+}
+
+// fontAttributes returns the attributes of a Font element as type NestedFont.
+func fontAttributes(f *Font) *NestedFont {
+	return &NestedFont{
+		ID:           f.ID,
+		Weight:       f.Weight,
+		Size:         f.Size,
+		Color:        f.Color,
+		Effect:       f.Effect,
+		EffectColor:  f.EffectColor,
+		EffectSize:   f.EffectSize,
+		Italic:       f.Italic,
+		Underline:    f.Underline,
+		AspectAdjust: f.AspectAdjust,
+		Spacing:      f.Spacing,
+		Feather:      f.Feather,
+	}
+}
+
+// mergeFont returns the attributes of parent overridden by any attribute set on child.
+func mergeFont(parent, child *NestedFont) *NestedFont {
+	m := &NestedFont{}
+	if parent != nil {
+		*m = *parent
+	}
+	if child == nil {
+		return m
+	}
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&m.ID, child.ID)
+	set(&m.Weight, child.Weight)
+	set(&m.Size, child.Size)
+	set(&m.Color, child.Color)
+	set(&m.Effect, child.Effect)
+	set(&m.EffectColor, child.EffectColor)
+	set(&m.EffectSize, child.EffectSize)
+	set(&m.Italic, child.Italic)
+	set(&m.Underline, child.Underline)
+	set(&m.AspectAdjust, child.AspectAdjust)
+	set(&m.Spacing, child.Spacing)
+	set(&m.Feather, child.Feather)
+	return m
+}
+
+// parseColor returns the color of an ST 428-7 AARRGGBB value, or def when it cannot be parsed.
+func parseColor(s string, def color.Color) color.Color {
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || len(s) != 8 {
+		return def
+	}
+	return color.NRGBA{A: uint8(v >> 24), R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v)}
+}
+
+// blendOver composites src over dst.
+func blendOver(dst, src color.Color) color.Color {
+	sr, sg, sb, sa := src.RGBA()
+	dr, dg, db, da := dst.RGBA()
+	inv := 0xffff - sa
+	return color.RGBA64{
+		R: uint16(sr + dr*inv/0xffff),
+		G: uint16(sg + dg*inv/0xffff),
+		B: uint16(sb + db*inv/0xffff),
+		A: uint16(sa + da*inv/0xffff),
+	}
+}
+
+// resourcePath returns the file path of a resource referenced by URN in dir.
+func resourcePath(dir, ref string) string {
+	return filepath.Join(dir, strings.TrimPrefix(strings.TrimSpace(ref), urn))
+}
+
+var previewTemplate = template.Must(template.New(previewIndex).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Reel.ContentTitleText}} - Reel {{.Reel.ReelNumber}}</title>
+<style>
+body { font-family: sans-serif; background: #222; color: #ddd; }
+table { border-collapse: collapse; width: 100%; }
+td, th { border-bottom: 1px solid #444; padding: 4px 8px; text-align: left; }
+.timeline { position: relative; height: 24px; background: #333; margin: 16px 0; }
+.timeline a { position: absolute; top: 0; height: 100%; min-width: 2px; background: #6a6; }
+img { max-width: 480px; background: #000; }
+</style>
+</head>
+<body>
+<h1>{{.Reel.ContentTitleText}}</h1>
+<p>{{.Reel.ID}}<br>Reel {{.Reel.ReelNumber}} &middot; {{.Reel.Language}} &middot; {{.Reel.EditRate}} &middot; {{.Reel.DisplayType}}</p>
+<div class="timeline">{{range .Events}}<a href="#e{{.Index}}" title="{{.TimeIn}} - {{.TimeOut}}" style="left: {{printf "%.3f" .Left}}%; width: {{printf "%.3f" .Width}}%"></a>{{end}}</div>
+<table>
+<tr><th>#</th><th>Spot</th><th>TimeIn</th><th>TimeOut</th><th>Content</th><th>Z</th>{{if .Events}}{{if (index .Events 0).Frame}}<th>Frame</th>{{end}}{{end}}</tr>
+{{range .Events}}<tr id="e{{.Index}}"><td>{{.Index}}</td><td>{{.SpotNumber}}</td><td>{{.TimeIn}}</td><td>{{.TimeOut}}</td><td>{{.Content}}</td><td>{{.Depth}}</td>{{if .Frame}}<td><a href="{{.Frame}}"><img src="{{.Frame}}"></a></td>{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,172 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreview(t *testing.T) {
+	s := diffReel(t, "en", diffSub("1", "00:00:01:00", "00:00:02:00", "One")+
+		diffSub("2", "00:00:03:00", "00:00:05:00", "Two &amp; <Font Italic=\"yes\">three</Font>"))
+	dir := t.TempDir()
+	if err := Preview(s, PreviewOptions{Output: dir, Frames: true}); err != nil {
+		t.Fatal(err)
+	}
+	html, err := ioutil.ReadFile(filepath.Join(dir, previewIndex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"One", "Two &amp; three", `src="0001.png"`, `src="0002.png"`, "00:00:05:00"} {
+		if !strings.Contains(string(html), want) {
+			t.Errorf("%s lacks %s", previewIndex, want)
+		}
+	}
+	for _, name := range []string{"0001.png", "0002.png"} {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Bounds().Size() != Container2K {
+			t.Errorf("%s: got size %v, want %v", name, img.Bounds().Size(), Container2K)
+		}
+		if alphaBounds(img).Empty() {
+			t.Errorf("%s: no text rendered", name)
+		}
+	}
+}
+
+func TestRenderFrameText(t *testing.T) {
+	s := diffReel(t, "en", `<Subtitle TimeIn="00:00:01:00" TimeOut="00:00:02:00"><Font Color="FFFF0000">`+
+		`<Text Halign="left" Hposition="10" Valign="bottom" Vposition="10">Red</Text></Font></Subtitle>`)
+	sub := subtitles(s)[0]
+	for _, container := range []image.Point{Container2K, Container4K} {
+		img, err := RenderFrame(s, sub, PreviewOptions{Container: container})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Bounds().Size() != container {
+			t.Fatalf("got size %v, want %v", img.Bounds().Size(), container)
+		}
+		// the text starts at 10% of the width and its baseline sits at 10% of the height.
+		b := alphaBounds(img)
+		left, baseline := container.X/10, container.Y*9/10
+		if b.Min.X < left || b.Min.X > left+container.X/100 || b.Max.Y < baseline-2 || b.Max.Y > baseline+container.Y/50 {
+			t.Errorf("%v: text drawn at %v, want from x %d above y %d", container, b, left, baseline)
+		}
+		var red bool
+		for y := b.Min.Y; y < b.Max.Y && !red; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if c := img.RGBAAt(x, y); c.R == 0xFF && c.G == 0 && c.B == 0 && c.A == 0xFF {
+					red = true
+					break
+				}
+			}
+		}
+		if !red {
+			t.Errorf("%v: no opaque red text pixel", container)
+		}
+	}
+}
+
+func TestRenderFrameImage(t *testing.T) {
+	dir := t.TempDir()
+	const id = "0b1c2d3e-4f5a-4b6c-9d7e-8f9a0b1c2d3e"
+	src := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	for i := range src.Pix {
+		src.Pix[i] = 0xFF
+	}
+	f, err := os.Create(filepath.Join(dir, id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, src); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	s := diffReel(t, "en", `<Subtitle TimeIn="00:00:01:00" TimeOut="00:00:02:00">`+
+		`<Image Halign="right" Hposition="0" Valign="top" Vposition="10">urn:uuid:`+id+`</Image></Subtitle>`)
+	sub := subtitles(s)[0]
+	// image subtitles are authored for 2K and scaled to the container.
+	for container, want := range map[image.Point]image.Rectangle{
+		Container2K: image.Rect(2028, 108, 2048, 118),
+		Container4K: image.Rect(4056, 216, 4096, 236),
+	} {
+		img, err := RenderFrame(s, sub, PreviewOptions{Container: container, Resources: dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := alphaBounds(img); got != want {
+			t.Errorf("%v: image drawn at %v, want %v", container, got, want)
+		}
+	}
+	if _, err := RenderFrame(s, sub, PreviewOptions{Resources: t.TempDir()}); err == nil {
+		t.Error("RenderFrame returned no error for a missing image resource")
+	}
+}
+
+func TestParallax(t *testing.T) {
+	// elements in front of the screen move right in the left eye and left in the right eye.
+	for _, tc := range []struct {
+		z          float64
+		eye, width int
+		want       int
+	}{
+		{-10, -1, 2048, 102},
+		{-10, 1, 2048, -102},
+		{10, -1, 4096, -205},
+		{0, 1, 2048, 0},
+	} {
+		if got := parallax(tc.z, tc.eye, tc.width); got != tc.want {
+			t.Errorf("parallax(%g, %d, %d) = %d, want %d", tc.z, tc.eye, tc.width, got, tc.want)
+		}
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	def := color.White
+	for s, want := range map[string]color.Color{
+		"FFFF0000": color.NRGBA{R: 0xFF, A: 0xFF},
+		"80FFFFFF": color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0x80},
+		"":         def,
+		"FF0000":   def,
+		"GGFFFFFF": def,
+	} {
+		if got := parseColor(s, def); got != want {
+			t.Errorf("parseColor(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
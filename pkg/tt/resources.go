@@ -0,0 +1,71 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// Paths of the bundled resources within Resources.
+const (
+	DefaultFont     = "font/" + fontName
+	DefaultTemplate = "template/minimal.xml"
+)
+
+//go:embed resources
+var embedded embed.FS
+
+// Resources is the file system bundled resources such as the default font and template are
+// resolved from. It defaults to the resources embedded in the package and may be replaced
+// to supply alternate defaults.
+var Resources fs.FS = subFS(embedded, "resources")
+
+// readResource returns the contents of a bundled resource.
+func readResource(name string) ([]byte, error) {
+	return fs.ReadFile(Resources, name)
+}
+
+// openFile opens a file from the local file system, falling back to Resources when the file does not exist.
+func openFile(name string) (fs.File, error) {
+	f, err := os.Open(name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return f, err
+	}
+	if r, rerr := Resources.Open(name); rerr == nil {
+		return r, nil
+	}
+	return nil, err
+}
+
+// subFS returns the sub tree of fsys rooted at dir.
+func subFS(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
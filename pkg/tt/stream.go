@@ -0,0 +1,433 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// fontAttr is an attribute of a Font element and a pointer to its value.
+type fontAttr struct {
+	name  string
+	value *string
+}
+
+// fontAttrs returns the attributes of a Font element in schema order.
+func fontAttrs(f *Font) []fontAttr {
+	return []fontAttr{
+		{"ID", &f.ID}, {"Weight", &f.Weight}, {"Size", &f.Size}, {"Color", &f.Color},
+		{"Effect", &f.Effect}, {"EffectColor", &f.EffectColor}, {"EffectSize", &f.EffectSize},
+		{"Italic", &f.Italic}, {"Underline", &f.Underline}, {"AspectAdjust", &f.AspectAdjust},
+		{"Spacing", &f.Spacing}, {"Feather", &f.Feather},
+	}
+}
+
+// Encoder writes an ST 428-7 document to a stream one Subtitle event at a time, so that the
+// document is never held in memory as a whole.
+type Encoder struct {
+	w      io.Writer
+	e      *xml.Encoder
+	ns2010 bool
+	open   bool
+	fonts  int
+}
+
+// NewEncoder returns an Encoder writing an indented document to w.
+func NewEncoder(w io.Writer) *Encoder {
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	return &Encoder{w: w, e: e}
+}
+
+// EncodeHeader writes the XML declaration, the header elements of a SubtitleReel and opens its
+// SubtitleList. The Font and Subtitle children of s.SubtitleList are not written, see OpenFont
+// and Encode.
+func (enc *Encoder) EncodeHeader(s *SubtitleReel) error {
+	if enc.open {
+		return errors.New("header already written")
+	}
+	ns := namespace(s)
+	enc.ns2010 = xmlNsSubtitle[ns] == dcst2010
+	if enc.ns2010 && len(s.LoadVariableZ) > 0 {
+		return errors.New("not permitted in a 2010 document: LoadVariableZ")
+	}
+	if _, err := io.WriteString(enc.w, xml.Header); err != nil {
+		return err
+	}
+	root := xml.StartElement{Name: xml.Name{Local: "SubtitleReel"}}
+	if ns != "" {
+		root.Attr = append(root.Attr, xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: ns})
+	}
+	if err := enc.e.EncodeToken(root); err != nil {
+		return err
+	}
+	elements := []struct {
+		name  string
+		value interface{}
+		omit  bool
+	}{
+		{"Id", s.ID, false},
+		{"ContentTitleText", s.ContentTitleText, s.ContentTitleText == ""},
+		{"IssueDate", s.IssueDate, false},
+		{"ReelNumber", s.ReelNumber, false},
+		{"Language", s.Language, false},
+		{"EditRate", s.EditRate, false},
+		{"TimeCodeRate", s.TimeCodeRate, false},
+		{"StartTime", s.StartTime, false},
+		{"DisplayType", s.DisplayType, false},
+	}
+	for _, el := range elements {
+		if el.omit {
+			continue
+		}
+		if err := enc.e.EncodeElement(el.value, xml.StartElement{Name: xml.Name{Local: el.name}}); err != nil {
+			return err
+		}
+	}
+	for _, l := range s.LoadFont {
+		if err := enc.e.EncodeElement(l, xml.StartElement{Name: xml.Name{Local: "LoadFont"}}); err != nil {
+			return err
+		}
+	}
+	for _, l := range s.LoadVariableZ {
+		if err := enc.e.EncodeElement(l, xml.StartElement{Name: xml.Name{Local: "LoadVariableZ"}}); err != nil {
+			return err
+		}
+	}
+	if err := enc.e.EncodeToken(xml.StartElement{Name: xml.Name{Local: "SubtitleList"}}); err != nil {
+		return err
+	}
+	enc.open = true
+	return nil
+}
+
+// OpenFont opens a Font element with the attributes of f within the SubtitleList or the Font
+// opened last. The Subtitle and Font children of f are not written.
+func (enc *Encoder) OpenFont(f *Font) error {
+	if !enc.open {
+		return errors.New("header not written")
+	}
+	font := xml.StartElement{Name: xml.Name{Local: "Font"}}
+	for _, a := range fontAttrs(f) {
+		if *a.value != "" {
+			font.Attr = append(font.Attr, xml.Attr{Name: xml.Name{Local: a.name}, Value: *a.value})
+		}
+	}
+	if err := enc.e.EncodeToken(font); err != nil {
+		return err
+	}
+	enc.fonts++
+	return nil
+}
+
+// CloseFont closes the Font element opened last.
+func (enc *Encoder) CloseFont() error {
+	if enc.fonts == 0 {
+		return errors.New("no Font open")
+	}
+	enc.fonts--
+	return enc.e.EncodeToken(xml.EndElement{Name: xml.Name{Local: "Font"}})
+}
+
+// Encode writes a single Subtitle event within the SubtitleList or the Font opened last.
+// ST 428-7:2014 constructs are refused in a 2010 document.
+func (enc *Encoder) Encode(sub *Subtitle) error {
+	if !enc.open {
+		return errors.New("header not written")
+	}
+	if enc.ns2010 {
+		var names []string
+		subtitleConstructs2014(sub, func(name string, used bool) {
+			if used {
+				names = append(names, name)
+			}
+		})
+		if len(names) > 0 {
+			return fmt.Errorf("not permitted in a 2010 document: %s", names[0])
+		}
+	}
+	return enc.e.EncodeElement(sub, xml.StartElement{Name: xml.Name{Local: "Subtitle"}})
+}
+
+// Close closes any open Font, the SubtitleList and SubtitleReel elements and flushes the stream.
+func (enc *Encoder) Close() error {
+	if !enc.open {
+		return errors.New("header not written")
+	}
+	for enc.fonts > 0 {
+		if err := enc.CloseFont(); err != nil {
+			return err
+		}
+	}
+	enc.open = false
+	for _, name := range []string{"SubtitleList", "SubtitleReel"} {
+		if err := enc.e.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}}); err != nil {
+			return err
+		}
+	}
+	return enc.e.Flush()
+}
+
+// EncodeReel writes a SubtitleReel and all of its Font and Subtitle elements to w with an
+// Encoder.
+func EncodeReel(w io.Writer, s *SubtitleReel) error {
+	enc := NewEncoder(w)
+	if err := enc.EncodeHeader(s); err != nil {
+		return err
+	}
+	var encode func(subs []*Subtitle, fonts []*Font) error
+	encode = func(subs []*Subtitle, fonts []*Font) error {
+		for _, sub := range subs {
+			if sub == nil {
+				continue
+			}
+			if err := enc.Encode(sub); err != nil {
+				return err
+			}
+		}
+		for _, f := range fonts {
+			if f == nil {
+				continue
+			}
+			if err := enc.OpenFont(f); err != nil {
+				return err
+			}
+			if err := encode(f.Subtitle, f.Font); err != nil {
+				return err
+			}
+			if err := enc.CloseFont(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if s.SubtitleList != nil {
+		if err := encode(s.SubtitleList.Subtitle, s.SubtitleList.Font); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
+
+// Decoder reads an ST 428-7 document from a stream one Subtitle event at a time.
+//
+//	dec := tt.NewDecoder(r)
+//	for dec.Next() {
+//		sub, fonts := dec.Subtitle(), dec.Fonts()
+//		...
+//	}
+//	if err := dec.Err(); err != nil {
+//		...
+//	}
+type Decoder struct {
+	d      *xml.Decoder
+	header *SubtitleReel
+	sub    *Subtitle
+	fonts  []*Font
+	err    error
+	done   bool
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{d: xml.NewDecoder(r)}
+}
+
+// Header reads the document up to its SubtitleList and returns the header elements as a
+// SubtitleReel with an empty SubtitleList.
+func (dec *Decoder) Header() (*SubtitleReel, error) {
+	if dec.header != nil || dec.err != nil {
+		return dec.header, dec.err
+	}
+	s := &SubtitleReel{SubtitleList: &SubtitleList{}}
+	depth := 0
+	for {
+		tok, err := dec.d.Token()
+		if err == io.EOF {
+			err = errors.New("no SubtitleList found")
+		}
+		if err != nil {
+			dec.err = err
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case depth == 1:
+				if t.Name.Local != "SubtitleReel" {
+					dec.err = fmt.Errorf("not a SubtitleReel: %s", t.Name.Local)
+					return nil, dec.err
+				}
+				s.XMLName = t.Name
+				s.Xmlns = t.Name.Space
+			case depth == 2 && t.Name.Local == "SubtitleList":
+				dec.header = s
+				return s, nil
+			case depth == 2:
+				if err := dec.decodeHeader(s, &t); err != nil {
+					dec.err = err
+					return nil, err
+				}
+				depth--
+			default:
+				if err := dec.d.Skip(); err != nil {
+					dec.err = err
+					return nil, err
+				}
+				depth--
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+// decodeHeader decodes a single header element of a SubtitleReel.
+func (dec *Decoder) decodeHeader(s *SubtitleReel, start *xml.StartElement) error {
+	var v string
+	switch start.Name.Local {
+	case "LoadFont":
+		l := &LoadFont{}
+		if err := dec.d.DecodeElement(l, start); err != nil {
+			return err
+		}
+		s.LoadFont = append(s.LoadFont, l)
+		return nil
+	case "LoadVariableZ":
+		l := &LoadVariableZ{}
+		if err := dec.d.DecodeElement(l, start); err != nil {
+			return err
+		}
+		s.LoadVariableZ = append(s.LoadVariableZ, l)
+		return nil
+	}
+	if err := dec.d.DecodeElement(&v, start); err != nil {
+		return err
+	}
+	switch start.Name.Local {
+	case "Id":
+		s.ID = v
+	case "ContentTitleText":
+		s.ContentTitleText = v
+	case "IssueDate":
+		s.IssueDate = v
+	case "ReelNumber":
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid ReelNumber: %s", v)
+		}
+		s.ReelNumber = n
+	case "Language":
+		s.Language = v
+	case "EditRate":
+		s.EditRate = v
+	case "TimeCodeRate":
+		s.TimeCodeRate = v
+	case "StartTime":
+		s.StartTime = v
+	case "DisplayType":
+		s.DisplayType = v
+	}
+	return nil
+}
+
+// Next advances to the next Subtitle event of the document, at any depth of Font elements. It
+// returns false at the end of the SubtitleList or when an error occurs, see Err.
+func (dec *Decoder) Next() bool {
+	dec.sub = nil
+	if dec.done {
+		return false
+	}
+	if _, err := dec.Header(); err != nil {
+		return false
+	}
+	for {
+		tok, err := dec.d.Token()
+		if err != nil {
+			dec.err = err
+			dec.done = true
+			return false
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "Font" {
+				f := &Font{}
+				for _, a := range fontAttrs(f) {
+					for _, attr := range t.Attr {
+						if attr.Name.Local == a.name {
+							*a.value = attr.Value
+						}
+					}
+				}
+				dec.fonts = append(dec.fonts, f)
+				continue
+			}
+			if t.Name.Local != "Subtitle" {
+				if err := dec.d.Skip(); err != nil {
+					dec.err = err
+					return false
+				}
+				continue
+			}
+			sub := &Subtitle{}
+			if err := dec.d.DecodeElement(sub, &t); err != nil {
+				dec.err = err
+				dec.done = true
+				return false
+			}
+			dec.sub = sub
+			return true
+		case xml.EndElement:
+			if n := len(dec.fonts); n > 0 {
+				dec.fonts = dec.fonts[:n-1]
+				continue
+			}
+			// the end of the SubtitleList ends the events.
+			dec.done = true
+			return false
+		}
+	}
+}
+
+// Subtitle returns the Subtitle event read by the last call to Next.
+func (dec *Decoder) Subtitle() *Subtitle {
+	return dec.sub
+}
+
+// Fonts returns the Font elements enclosing the Subtitle event read by the last call to Next,
+// outermost first. Only their attributes are set, and events within the same Font element share
+// the same *Font.
+func (dec *Decoder) Fonts() []*Font {
+	return append([]*Font(nil), dec.fonts...)
+}
+
+// Err returns the first error encountered by the Decoder.
+func (dec *Decoder) Err() error {
+	return dec.err
+}
@@ -31,20 +31,21 @@ import (
 
 // SubtitleReel as per http://www.smpte-ra.org/schemas/428-7/2014/DCST
 type SubtitleReel struct {
-	XMLName          xml.Name  `xml:"SubtitleReel"`
-	Xmlns            string    `xml:"xmlns,attr,omitempty"`
-	ID               string    `xml:"Id"`
-	ContentTitleText string    `xml:"ContentTitleText,omitempty"`
-	IssueDate        string    `xml:"IssueDate"`
-	ReelNumber       int       `xml:"ReelNumber"`
-	Language         string    `xml:"Language"`
-	EditRate         string    `xml:"EditRate"`
-	TimeCodeRate     string    `xml:"TimeCodeRate"`
-	StartTime        string    `xml:"StartTime"`
-	DisplayType      string    `xml:"DisplayType"`
-	LoadFont         *LoadFont `xml:"LoadFont,omitempty"`
-	SubtitleList     *Font     `xml:"SubtitleList>Font"`
-	Filename         string    `xml:",omitempty"`
+	XMLName          xml.Name         `xml:"SubtitleReel"`
+	Xmlns            string           `xml:"xmlns,attr,omitempty"`
+	ID               string           `xml:"Id"`
+	ContentTitleText string           `xml:"ContentTitleText,omitempty"`
+	IssueDate        string           `xml:"IssueDate"`
+	ReelNumber       int              `xml:"ReelNumber"`
+	Language         string           `xml:"Language"`
+	EditRate         string           `xml:"EditRate"`
+	TimeCodeRate     string           `xml:"TimeCodeRate"`
+	StartTime        string           `xml:"StartTime"`
+	DisplayType      string           `xml:"DisplayType"`
+	LoadFont         []*LoadFont      `xml:"LoadFont,omitempty"`
+	LoadVariableZ    []*LoadVariableZ `xml:"LoadVariableZ,omitempty"`
+	SubtitleList     *SubtitleList    `xml:"SubtitleList"`
+	Filename         string           `xml:",omitempty"`
 }
 
 // LoadFont as per http://www.smpte-ra.org/schemas/428-7/2014/DCST#LoadFont
@@ -53,6 +54,18 @@ type LoadFont struct {
 	Font string `xml:",chardata"`
 }
 
+// LoadVariableZ as per http://www.smpte-ra.org/schemas/428-7/2014/DCST#LoadVariableZ
+type LoadVariableZ struct {
+	ID        string `xml:"ID,attr"`
+	Positions string `xml:",chardata"`
+}
+
+// SubtitleList as per http://www.smpte-ra.org/schemas/428-7/2014/DCST#SubtitleList
+type SubtitleList struct {
+	Subtitle []*Subtitle `xml:"Subtitle"`
+	Font     []*Font     `xml:"Font"`
+}
+
 // Font as per http://www.smpte-ra.org/schemas/428-7/2014/DCST#Font
 type Font struct {
 	ID           string      `xml:"ID,attr,omitempty"`
@@ -68,49 +81,54 @@ type Font struct {
 	Spacing      string      `xml:"Spacing,attr,omitempty"`
 	Feather      string      `xml:"Feather,attr,omitempty"`
 	Subtitle     []*Subtitle `xml:"Subtitle"`
+	Font         []*Font     `xml:"Font"`
 }
 
 // Subtitle as per http://www.smpte-ra.org/schemas/428-7/2014/DCST#Subtitle
 type Subtitle struct {
-	SpotNumber   string      `xml:"SpotNumber,attr,omitempty"`
-	TimeIn       string      `xml:"TimeIn,attr"`
-	TimeOut      string      `xml:"TimeOut,attr"`
-	FadeUpTime   string      `xml:"FadeUpTime,attr,omitempty"`
-	FadeDownTime string      `xml:"FadeDownTime,attr,omitempty"`
-	Text         []*Text     `xml:"Text,allowempty"`
-	Image        []*Image    `xml:"Image,omitempty"`
-	Font         *NestedFont `xml:"Font,omitempty"`
+	SpotNumber   string        `xml:"SpotNumber,attr,omitempty"`
+	TimeIn       string        `xml:"TimeIn,attr"`
+	TimeOut      string        `xml:"TimeOut,attr"`
+	FadeUpTime   string        `xml:"FadeUpTime,attr,omitempty"`
+	FadeDownTime string        `xml:"FadeDownTime,attr,omitempty"`
+	Text         []*Text       `xml:"Text,allowempty"`
+	Image        []*Image      `xml:"Image,omitempty"`
+	Font         []*NestedFont `xml:"Font,omitempty"`
 }
 
 // NestedFont as per http://www.smpte-ra.org/schemas/428-7/2014/DCST#NestedFont
+// Within a Text element it holds character data, within a Subtitle element it holds Text, Image
+// and further Font elements, see UnmarshalXML.
 type NestedFont struct {
-	ID           string `xml:"ID,attr,omitempty"`
-	Weight       string `xml:"Weight,attr,omitempty"`
-	Size         string `xml:"Size,attr,omitempty"`
-	Color        string `xml:"Color,attr,omitempty"`
-	Effect       string `xml:"Effect,attr,omitempty"`
-	EffectColor  string `xml:"EffectColor,attr,omitempty"`
-	EffectSize   string `xml:"EffectSize,attr,omitempty"`
-	Italic       string `xml:"Italic,attr,omitempty"`
-	Underline    string `xml:"Underline,attr,omitempty"`
-	AspectAdjust string `xml:"AspectAdjust,attr,omitempty"`
-	Spacing      string `xml:"Spacing,attr,omitempty"`
-	Feather      string `xml:"Feather,attr,omitempty"`
-	Text         string `xml:",chardata"`
+	ID           string        `xml:"ID,attr,omitempty"`
+	Weight       string        `xml:"Weight,attr,omitempty"`
+	Size         string        `xml:"Size,attr,omitempty"`
+	Color        string        `xml:"Color,attr,omitempty"`
+	Effect       string        `xml:"Effect,attr,omitempty"`
+	EffectColor  string        `xml:"EffectColor,attr,omitempty"`
+	EffectSize   string        `xml:"EffectSize,attr,omitempty"`
+	Italic       string        `xml:"Italic,attr,omitempty"`
+	Underline    string        `xml:"Underline,attr,omitempty"`
+	AspectAdjust string        `xml:"AspectAdjust,attr,omitempty"`
+	Spacing      string        `xml:"Spacing,attr,omitempty"`
+	Feather      string        `xml:"Feather,attr,omitempty"`
+	Text         string        `xml:",chardata"`
+	Texts        []*Text       `xml:"Text,omitempty"`
+	Image        []*Image      `xml:"Image,omitempty"`
+	Font         []*NestedFont `xml:"Font,omitempty"`
 }
 
 // Text as per http://www.smpte-ra.org/schemas/428-7/2014/DCST#Text
+// Its mixed content is held in document order by Runs, see text.go.
 type Text struct {
-	Text      string      `xml:",chardata"`
-	Halign    string      `xml:"Halign,attr,omitempty"`
-	Hposition string      `xml:"Hposition,attr,omitempty"`
-	Valign    string      `xml:"Valign,attr,omitempty"`
-	Vposition string      `xml:"Vposition,attr,omitempty"`
-	Direction string      `xml:"Direction,attr,omitempty"`
-	Zposition string      `xml:"Zposition,attr,omitempty"`
-	VariableZ string      `xml:"VariableZ,attr,omitempty"`
-	Font      *NestedFont `xml:"Font,omitempty"`
-	Ruby      []*Ruby     `xml:"Ruby,omitempty"`
+	Halign    string `xml:"Halign,attr,omitempty"`
+	Hposition string `xml:"Hposition,attr,omitempty"`
+	Valign    string `xml:"Valign,attr,omitempty"`
+	Vposition string `xml:"Vposition,attr,omitempty"`
+	Direction string `xml:"Direction,attr,omitempty"`
+	Zposition string `xml:"Zposition,attr,omitempty"`
+	VariableZ string `xml:"VariableZ,attr,omitempty"`
+	Runs      []*Run `xml:"-"`
 }
 
 // Image as per http://www.smpte-ra.org/schemas/428-7/2014/DCST#Image
@@ -128,17 +146,34 @@ type Image struct {
 
 // Ruby as per http://www.smpte-ra.org/schemas/428-7/2014/DCST#Ruby
 type Ruby struct {
-	Rb string `xml:"Ruby>Rb,omitempty"`
-	Rt *Rt    `xml:"Ruby>Rt,omitempty"`
+	Rb string `xml:"Rb"`
+	Rt *Rt    `xml:"Rt"`
 }
 
 // Rt as per http://www.smpte-ra.org/schemas/428-7/2014/DCST#Rt
 type Rt struct {
-	Size         string `xml:"Size,attr"`
-	Position     string `xml:"Position,attr"`
-	Offset       string `xml:"Offset,attr"`
-	Spacing      string `xml:"Spacing,attr"`
-	AspectAdjust string `xml:"AspectAdjust,attr"`
+	Text         string `xml:",chardata"`
+	Size         string `xml:"Size,attr,omitempty"`
+	Position     string `xml:"Position,attr,omitempty"`
+	Offset       string `xml:"Offset,attr,omitempty"`
+	Spacing      string `xml:"Spacing,attr,omitempty"`
+	AspectAdjust string `xml:"AspectAdjust,attr,omitempty"`
+}
+
+// Space as per http://www.smpte-ra.org/schemas/428-7/2014/DCST#Space
+type Space struct {
+	Size string `xml:"Size,attr,omitempty"`
+}
+
+// HGroup as per http://www.smpte-ra.org/schemas/428-7/2014/DCST#HGroup
+type HGroup struct {
+	Text string `xml:",chardata"`
+}
+
+// Rotate as per http://www.smpte-ra.org/schemas/428-7/2014/DCST#Rotate
+type Rotate struct {
+	Direction string `xml:"Direction,attr,omitempty"`
+	Text      string `xml:",chardata"`
 }
 
 // END ST 428-7 SUBTITLE STRUCT //
@@ -0,0 +1,251 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// roundTripDocuments are parsed, marshalled and parsed again by the round-trip tests.
+var roundTripDocuments = []string{
+	"testdata/full.xml",
+	"testdata/nested.xml",
+	"../../resources/sample/dcdm/text/1d4fc9bb-beda-4385-bde1-49b15606e723_r1.xml",
+	"../../resources/sample/dcdm/image/e7c646ab-2468-4fc8-8188-ee667aa81967_r1.xml",
+}
+
+func TestRoundTrip(t *testing.T) {
+	golden, _ := filepath.Glob(filepath.Join(goldenDir, "*.xml"))
+	for _, name := range append(roundTripDocuments, golden...) {
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			data, err := ioutil.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var first SubtitleReel
+			if err := xml.Unmarshal(data, &first); err != nil {
+				t.Fatal(err)
+			}
+			enc, err := xml.MarshalIndent(first, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var second SubtitleReel
+			if err := xml.Unmarshal(enc, &second); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(first, second) {
+				t.Errorf("document changed in round trip:\n%s", enc)
+			}
+			again, err := xml.MarshalIndent(second, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(enc, again) {
+				t.Errorf("encoding changed in round trip:\n%s\n%s", enc, again)
+			}
+		})
+	}
+}
+
+func TestRoundTripFullModel(t *testing.T) {
+	s, err := parseXML("testdata/full.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.LoadFont) != 2 || len(s.LoadVariableZ) != 1 {
+		t.Fatalf("got %d LoadFont and %d LoadVariableZ elements", len(s.LoadFont), len(s.LoadVariableZ))
+	}
+	subs := subtitles(s)
+	if len(subs) != 3 {
+		t.Fatalf("got %d Subtitle events, want 3", len(subs))
+	}
+	if got := subs[0].Text[0].Content(); got != "Plain styled text" {
+		t.Errorf("Content = %q, want %q", got, "Plain styled text")
+	}
+	if f := subs[0].Text[0].firstFont(); f == nil || f.Italic != "yes" || f.Feather != "1" {
+		t.Errorf("nested Font = %+v", f)
+	}
+	runs := subs[1].Text[0].Runs
+	if len(runs) != 4 || runs[0].Ruby == nil || runs[1].Space == nil || runs[2].HGroup == nil || runs[3].Rotate == nil {
+		t.Fatalf("got runs %+v, want Ruby, Space, HGroup and Rotate", runs)
+	}
+	if runs[0].Ruby.Rt == nil || runs[0].Ruby.Rt.Position != "before" {
+		t.Errorf("Rt = %+v", runs[0].Ruby.Rt)
+	}
+	if img := subs[2].Image[0]; img.Zposition != "1.0" || img.Halign != "left" {
+		t.Errorf("Image = %+v", img)
+	}
+}
+
+func TestRoundTripNestedFonts(t *testing.T) {
+	s, err := parseXML("testdata/nested.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	walkSubtitles(s, func(sub *Subtitle, fonts []*Font) {
+		var attrs []string
+		for _, f := range fonts {
+			attrs = append(attrs, fontString(f))
+		}
+		got = append(got, sub.SpotNumber+": "+strings.Join(attrs, " > "))
+	})
+	want := []string{
+		"3: ",
+		"1: ID=Font1 Size=42 Color=FFFFFFFF",
+		"2: ID=Font1 Size=42 Color=FFFFFFFF > Color=FFFFFF00",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got Subtitle events %q, want %q", got, want)
+	}
+
+	sub := s.SubtitleList.Subtitle[0]
+	if len(sub.Font) != 1 || sub.Font[0].Italic != "yes" || sub.Font[0].Text != "" {
+		t.Fatalf("Subtitle Font = %+v", sub.Font)
+	}
+	var content []string
+	for _, t := range sub.texts() {
+		content = append(content, t.Content())
+	}
+	if want := []string{"Italic bold text", "Small italic"}; !reflect.DeepEqual(content, want) {
+		t.Errorf("got Text content %q, want %q", content, want)
+	}
+	if n := len(sub.images()); n != 1 {
+		t.Errorf("got %d Image elements, want 1", n)
+	}
+
+	deterministic(t)
+	findings, err := Convert(s, DCST2010)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("got findings %v converting a document without 2014 constructs", findings)
+	}
+	enc, err := xml.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<SubtitleList><Subtitle SpotNumber="3"`,
+		`<Font Color="FFFFFF00"><Subtitle SpotNumber="2"`,
+		`<Font Italic="yes"><Text Valign="bottom" Vposition="10">Italic <Font Weight="bold">bold</Font> text</Text>`,
+		`<Font Size="30"><Text Valign="top" Vposition="20">Small italic</Text></Font>`,
+	} {
+		if !strings.Contains(string(enc), want) {
+			t.Errorf("converted document lacks %s:\n%s", want, enc)
+		}
+	}
+}
+
+func TestEncodeReelMatchesMarshal(t *testing.T) {
+	for _, name := range roundTripDocuments {
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			data, err := ioutil.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var s SubtitleReel
+			if err := xml.Unmarshal(data, &s); err != nil {
+				t.Fatal(err)
+			}
+			enc, err := xml.MarshalIndent(s, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			if err := EncodeReel(&b, &s); err != nil {
+				t.Fatal(err)
+			}
+			if want := xml.Header + string(enc); strings.TrimSpace(b.String()) != want {
+				t.Errorf("EncodeReel output differs from MarshalIndent:\n%s\n%s", b.String(), want)
+			}
+		})
+	}
+}
+
+func TestDecoderRoundTrip(t *testing.T) {
+	for _, name := range []string{"testdata/full.xml", "testdata/nested.xml"} {
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			data, err := ioutil.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want SubtitleReel
+			if err := xml.Unmarshal(data, &want); err != nil {
+				t.Fatal(err)
+			}
+			dec := NewDecoder(bytes.NewReader(data))
+			got, err := dec.Header()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// rebuild the Font tree, adding each Font to its parent when it is first seen.
+			seen := make(map[*Font]bool)
+			for dec.Next() {
+				subs, children := &got.SubtitleList.Subtitle, &got.SubtitleList.Font
+				for _, f := range dec.Fonts() {
+					if !seen[f] {
+						seen[f] = true
+						*children = append(*children, f)
+					}
+					subs, children = &f.Subtitle, &f.Font
+				}
+				*subs = append(*subs, dec.Subtitle())
+			}
+			if err := dec.Err(); err != nil {
+				t.Fatal(err)
+			}
+			gotXML, _ := xml.Marshal(got)
+			wantXML, _ := xml.Marshal(want)
+			if !bytes.Equal(gotXML, wantXML) {
+				t.Errorf("decoded document differs:\n%s\n%s", gotXML, wantXML)
+			}
+		})
+	}
+}
+
+func TestConvertTo2010DropsConstructs(t *testing.T) {
+	deterministic(t)
+	s, findings, err := ConvertFile("testdata/full.xml", DCST2010, TextImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) == 0 {
+		t.Fatal("got no findings for dropped 2014 constructs")
+	}
+	if names := constructs2014(s); len(names) != 0 {
+		t.Errorf("2014 constructs left after conversion: %v", names)
+	}
+	if _, err := xml.Marshal(s); err != nil {
+		t.Errorf("converted document does not marshal: %v", err)
+	}
+}
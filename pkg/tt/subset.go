@@ -0,0 +1,366 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see <http://www.gnu.org/licenses/>.*/
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// composite glyph flags as per the OpenType glyf table.
+const (
+	argsAreWords    = 0x0001
+	weHaveAScale    = 0x0008
+	moreComponents  = 0x0020
+	weHaveAnXYScale = 0x0040
+	weHaveATwoByTwo = 0x0080
+)
+
+// droppedTables lists tables that are invalidated by, or of no use after, subsetting.
+var droppedTables = map[string]bool{
+	"DSIG": true,
+	"hdmx": true,
+	"LTSH": true,
+	"VDMX": true,
+	"EBDT": true,
+	"EBLC": true,
+	"EBSC": true,
+}
+
+// errNotTrueType is returned when subsetting a font without glyf outlines.
+var errNotTrueType = errors.New("font subsetting requires TrueType (glyf) outlines")
+
+// Subset replaces the font resource with a subset containing only the glyphs required to
+// render text. The subset is a new resource and is assigned a new Type-4 UUID, so that it is
+// never confused with the full font of the same name. It returns the size of the resource
+// before and after subsetting.
+func (f *FontResource) Subset(text string) (before, after int, err error) {
+	before = len(f.data)
+	data, err := SubsetFont(f.data, text)
+	if err != nil {
+		return before, before, err
+	}
+	f.data = data
+	f.UUID = uuidType4()
+	return before, len(data), nil
+}
+
+// SubsetFont returns a TrueType font containing only the glyphs mapped from the runes of text,
+// the .notdef glyph and any composite glyph components. Glyph IDs are preserved, unused glyphs
+// are emptied, and the cmap and post tables are rewritten to match.
+func SubsetFont(data []byte, text string) ([]byte, error) {
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid font resource: %w", err)
+	}
+	tables, err := readTables(data)
+	if err != nil {
+		return nil, err
+	}
+	head, loca, glyf := tables["head"], tables["loca"], tables["glyf"]
+	if head == nil || loca == nil || glyf == nil || tables["maxp"] == nil {
+		return nil, errNotTrueType
+	}
+	numGlyphs := int(binary.BigEndian.Uint16(tables["maxp"][4:]))
+	offsets, err := readLoca(loca, int16(binary.BigEndian.Uint16(head[50:])), numGlyphs, len(glyf))
+	if err != nil {
+		return nil, err
+	}
+
+	// map every rune to its glyph.
+	var b sfnt.Buffer
+	cmap := make(map[rune]uint16)
+	keep := map[uint16]bool{0: true}
+	for _, r := range text {
+		if _, ok := cmap[r]; ok {
+			continue
+		}
+		if i, err := f.GlyphIndex(&b, r); err == nil && i != 0 {
+			cmap[r] = uint16(i)
+			keep[uint16(i)] = true
+		}
+	}
+	if i, err := f.GlyphIndex(&b, ' '); err == nil && i != 0 {
+		cmap[' '] = uint16(i)
+		keep[uint16(i)] = true
+	}
+	// add the components of composite glyphs.
+	queue := make([]uint16, 0, len(keep))
+	for g := range keep {
+		queue = append(queue, g)
+	}
+	for len(queue) > 0 {
+		g := queue[0]
+		queue = queue[1:]
+		if int(g) >= numGlyphs {
+			continue
+		}
+		for _, c := range glyphComponents(glyf[offsets[g]:offsets[g+1]]) {
+			if !keep[c] {
+				keep[c] = true
+				queue = append(queue, c)
+			}
+		}
+	}
+
+	// rebuild glyf and a long format loca.
+	var newGlyf []byte
+	newLoca := make([]byte, 4*(numGlyphs+1))
+	for g := 0; g < numGlyphs; g++ {
+		binary.BigEndian.PutUint32(newLoca[4*g:], uint32(len(newGlyf)))
+		if keep[uint16(g)] {
+			newGlyf = append(newGlyf, glyf[offsets[g]:offsets[g+1]]...)
+			for len(newGlyf)%4 != 0 {
+				newGlyf = append(newGlyf, 0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(newLoca[4*numGlyphs:], uint32(len(newGlyf)))
+
+	newHead := append([]byte(nil), head...)
+	binary.BigEndian.PutUint16(newHead[50:], 1)
+	binary.BigEndian.PutUint32(newHead[8:], 0)
+
+	out := make(map[string][]byte)
+	for tag, t := range tables {
+		if !droppedTables[tag] {
+			out[tag] = t
+		}
+	}
+	out["head"] = newHead
+	out["loca"] = newLoca
+	out["glyf"] = newGlyf
+	out["cmap"] = buildCmap(cmap)
+	if post := tables["post"]; len(post) >= 32 {
+		p := append([]byte(nil), post[:32]...)
+		binary.BigEndian.PutUint32(p, 0x00030000)
+		out["post"] = p
+	}
+	return writeTables(out), nil
+}
+
+// readTables returns the tables of an sfnt font keyed by tag.
+func readTables(data []byte) (map[string][]byte, error) {
+	if len(data) < 12 {
+		return nil, errors.New("invalid font resource: truncated header")
+	}
+	if string(data[:4]) == "OTTO" {
+		return nil, errNotTrueType
+	}
+	n := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+16*n {
+		return nil, errors.New("invalid font resource: truncated table directory")
+	}
+	tables := make(map[string][]byte, n)
+	for i := 0; i < n; i++ {
+		rec := data[12+16*i:]
+		tag := string(rec[:4])
+		off := binary.BigEndian.Uint32(rec[8:])
+		length := binary.BigEndian.Uint32(rec[12:])
+		if uint64(off)+uint64(length) > uint64(len(data)) {
+			return nil, fmt.Errorf("invalid font resource: table %q out of range", tag)
+		}
+		tables[tag] = data[off : off+length]
+	}
+	return tables, nil
+}
+
+// readLoca returns the glyf offsets of every glyph, each within a glyf table of glyfLen bytes.
+func readLoca(loca []byte, format int16, numGlyphs, glyfLen int) ([]uint32, error) {
+	offsets := make([]uint32, numGlyphs+1)
+	for i := range offsets {
+		if format == 0 {
+			if len(loca) < 2*(i+1) {
+				return nil, errors.New("invalid font resource: truncated loca table")
+			}
+			offsets[i] = 2 * uint32(binary.BigEndian.Uint16(loca[2*i:]))
+		} else {
+			if len(loca) < 4*(i+1) {
+				return nil, errors.New("invalid font resource: truncated loca table")
+			}
+			offsets[i] = binary.BigEndian.Uint32(loca[4*i:])
+		}
+		if i > 0 && offsets[i] < offsets[i-1] {
+			return nil, errors.New("invalid font resource: unordered loca table")
+		}
+		if uint64(offsets[i]) > uint64(glyfLen) {
+			return nil, errors.New("invalid font resource: loca table out of range")
+		}
+	}
+	return offsets, nil
+}
+
+// glyphComponents returns the glyph IDs referenced by a composite glyph.
+func glyphComponents(g []byte) []uint16 {
+	if len(g) < 10 || int16(binary.BigEndian.Uint16(g)) >= 0 {
+		return nil
+	}
+	var c []uint16
+	p := 10
+	for p+4 <= len(g) {
+		flags := binary.BigEndian.Uint16(g[p:])
+		c = append(c, binary.BigEndian.Uint16(g[p+2:]))
+		p += 4
+		if flags&argsAreWords != 0 {
+			p += 4
+		} else {
+			p += 2
+		}
+		switch {
+		case flags&weHaveAScale != 0:
+			p += 2
+		case flags&weHaveAnXYScale != 0:
+			p += 4
+		case flags&weHaveATwoByTwo != 0:
+			p += 8
+		}
+		if flags&moreComponents == 0 {
+			break
+		}
+	}
+	return c
+}
+
+// buildCmap returns a cmap table with a format 4 subtable for the BMP and a format 12
+// subtable covering every mapped rune.
+func buildCmap(m map[rune]uint16) []byte {
+	runes := make([]rune, 0, len(m))
+	for r := range m {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+
+	// format 4, one segment per rune plus the terminating segment.
+	var bmp []rune
+	for _, r := range runes {
+		if r < 0xFFFF {
+			bmp = append(bmp, r)
+		}
+	}
+	segs := len(bmp) + 1
+	f4 := make([]byte, 16+8*segs)
+	binary.BigEndian.PutUint16(f4[0:], 4)
+	binary.BigEndian.PutUint16(f4[2:], uint16(len(f4)))
+	binary.BigEndian.PutUint16(f4[6:], uint16(2*segs))
+	search := 1
+	entry := 0
+	for search*2 <= segs {
+		search *= 2
+		entry++
+	}
+	binary.BigEndian.PutUint16(f4[8:], uint16(2*search))
+	binary.BigEndian.PutUint16(f4[10:], uint16(entry))
+	binary.BigEndian.PutUint16(f4[12:], uint16(2*segs-2*search))
+	ends := f4[14:]
+	starts := f4[16+2*segs:]
+	deltas := f4[16+4*segs:]
+	for i, r := range bmp {
+		binary.BigEndian.PutUint16(ends[2*i:], uint16(r))
+		binary.BigEndian.PutUint16(starts[2*i:], uint16(r))
+		binary.BigEndian.PutUint16(deltas[2*i:], m[r]-uint16(r))
+	}
+	binary.BigEndian.PutUint16(ends[2*len(bmp):], 0xFFFF)
+	binary.BigEndian.PutUint16(starts[2*len(bmp):], 0xFFFF)
+	binary.BigEndian.PutUint16(deltas[2*len(bmp):], 1)
+
+	// format 12, one group per rune.
+	f12 := make([]byte, 16+12*len(runes))
+	binary.BigEndian.PutUint16(f12[0:], 12)
+	binary.BigEndian.PutUint32(f12[4:], uint32(len(f12)))
+	binary.BigEndian.PutUint32(f12[12:], uint32(len(runes)))
+	for i, r := range runes {
+		g := f12[16+12*i:]
+		binary.BigEndian.PutUint32(g[0:], uint32(r))
+		binary.BigEndian.PutUint32(g[4:], uint32(r))
+		binary.BigEndian.PutUint32(g[8:], uint32(m[r]))
+	}
+
+	cmap := make([]byte, 4+8*2)
+	binary.BigEndian.PutUint16(cmap[2:], 2)
+	binary.BigEndian.PutUint16(cmap[4:], 3)
+	binary.BigEndian.PutUint16(cmap[6:], 1)
+	binary.BigEndian.PutUint32(cmap[8:], uint32(len(cmap)))
+	binary.BigEndian.PutUint16(cmap[12:], 3)
+	binary.BigEndian.PutUint16(cmap[14:], 10)
+	binary.BigEndian.PutUint32(cmap[16:], uint32(len(cmap)+len(f4)))
+	cmap = append(cmap, f4...)
+	return append(cmap, f12...)
+}
+
+// writeTables serialises sfnt tables into a TrueType font and sets the head checksum adjustment.
+func writeTables(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	n := len(tags)
+	search := 1
+	entry := 0
+	for search*2 <= n {
+		search *= 2
+		entry++
+	}
+	out := make([]byte, 12+16*n)
+	binary.BigEndian.PutUint32(out[0:], 0x00010000)
+	binary.BigEndian.PutUint16(out[4:], uint16(n))
+	binary.BigEndian.PutUint16(out[6:], uint16(16*search))
+	binary.BigEndian.PutUint16(out[8:], uint16(entry))
+	binary.BigEndian.PutUint16(out[10:], uint16(16*n-16*search))
+	headOffset := 0
+	for i, tag := range tags {
+		t := tables[tag]
+		rec := out[12+16*i:]
+		copy(rec[0:4], tag)
+		binary.BigEndian.PutUint32(rec[4:], tableChecksum(t))
+		binary.BigEndian.PutUint32(rec[8:], uint32(len(out)))
+		binary.BigEndian.PutUint32(rec[12:], uint32(len(t)))
+		if tag == "head" {
+			headOffset = len(out)
+		}
+		out = append(out, t...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	if _, ok := tables["head"]; ok {
+		binary.BigEndian.PutUint32(out[headOffset+8:], 0xB1B0AFBA-tableChecksum(out))
+	}
+	return out
+}
+
+// tableChecksum returns the sfnt checksum of b.
+func tableChecksum(b []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(b); i += 4 {
+		var v [4]byte
+		copy(v[:], b[i:])
+		sum += binary.BigEndian.Uint32(v[:])
+	}
+	return sum
+}
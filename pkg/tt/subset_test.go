@@ -0,0 +1,151 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+func TestSubsetFont(t *testing.T) {
+	data := goregular.TTF
+	const text = "Hello, Wörld!"
+	sub, err := SubsetFont(data, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sub) >= len(data) {
+		t.Errorf("subset is %d bytes, the font is %d bytes", len(sub), len(data))
+	}
+	if err := ValidateFont(sub, text); err != nil {
+		t.Fatalf("subset does not cover its text: %v", err)
+	}
+	full, _ := sfnt.Parse(data)
+	f, err := sfnt.Parse(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b sfnt.Buffer
+	for _, r := range text {
+		want, _ := full.GlyphIndex(&b, r)
+		got, _ := f.GlyphIndex(&b, r)
+		if got != want {
+			t.Errorf("glyph of %q: got %d, want %d", r, got, want)
+		}
+	}
+	if i, _ := f.GlyphIndex(&b, 'z'); i != 0 {
+		t.Errorf("glyph of 'z' kept in subset: %d", i)
+	}
+
+	// subsetting a subset to the same text is stable.
+	again, err := SubsetFont(sub, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != len(sub) {
+		t.Errorf("subset of subset is %d bytes, want %d", len(again), len(sub))
+	}
+}
+
+func TestFontResourceSubset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goregular.ttf")
+	if err := ioutil.WriteFile(path, goregular.TTF, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fonts, err := LoadFonts([]string{path}, "Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults, err := LoadFonts(nil, " ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range append(fonts, defaults...) {
+		name := f.UUID
+		size := f.Size()
+		before, after, err := f.Subset("Hello")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if before != size || after != f.Size() || after > before {
+			t.Errorf("%s: Subset returned %d, %d for a resource of %d bytes", f.ID, before, after, size)
+		}
+		if f.UUID == name {
+			t.Errorf("%s: subset font kept the UUID %s of the full font", f.ID, name)
+		}
+		if got := f.LoadFont().Font; got != urn+f.UUID {
+			t.Errorf("%s: LoadFont references %s, want %s", f.ID, got, urn+f.UUID)
+		}
+	}
+}
+
+// malformedFonts returns font resources that SubsetFont must reject.
+func malformedFonts(t testing.TB) map[string][]byte {
+	data := goregular.TTF
+	// point every glyph past the end of the glyf table.
+	loca := append([]byte(nil), data...)
+	tables, err := readTables(loca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range tables["loca"] {
+		tables["loca"][i] = 0xFF
+	}
+	return map[string][]byte{
+		"empty":             nil,
+		"OTTO tag":          []byte("OTTO"),
+		"not a font":        []byte("not a font at all"),
+		"offset table":      data[:12],
+		"truncated":         data[:len(data)/2],
+		"loca out of range": loca,
+	}
+}
+
+func TestSubsetFontMalformed(t *testing.T) {
+	for name, in := range malformedFonts(t) {
+		if _, err := SubsetFont(in, "Hello"); err == nil {
+			t.Errorf("%s: SubsetFont accepted %d bytes of malformed input", name, len(in))
+		}
+	}
+}
+
+func FuzzSubsetFont(f *testing.F) {
+	f.Add(goregular.TTF, "Hello")
+	for _, in := range malformedFonts(f) {
+		f.Add(in, "Hello")
+	}
+	f.Fuzz(func(t *testing.T, data []byte, text string) {
+		sub, err := SubsetFont(data, text)
+		if err != nil {
+			return
+		}
+		if _, err := sfnt.Parse(sub); err != nil {
+			t.Errorf("subset font does not parse: %v", err)
+		}
+	})
+}
@@ -0,0 +1,242 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+)
+
+// Run is a single item of the mixed content of a Text element. Exactly one field is set.
+type Run struct {
+	Text   string
+	Font   *NestedFont
+	Ruby   *Ruby
+	Space  *Space
+	HGroup *HGroup
+	Rotate *Rotate
+}
+
+// isText reports whether a Run holds character data.
+func (r *Run) isText() bool {
+	return r.Font == nil && r.Ruby == nil && r.Space == nil && r.HGroup == nil && r.Rotate == nil
+}
+
+// textXML is the encoding of a Text element with its content rendered verbatim, so that
+// indentation is never inserted into the significant whitespace of its runs.
+type textXML struct {
+	Halign    string `xml:"Halign,attr,omitempty"`
+	Hposition string `xml:"Hposition,attr,omitempty"`
+	Valign    string `xml:"Valign,attr,omitempty"`
+	Vposition string `xml:"Vposition,attr,omitempty"`
+	Direction string `xml:"Direction,attr,omitempty"`
+	Zposition string `xml:"Zposition,attr,omitempty"`
+	VariableZ string `xml:"VariableZ,attr,omitempty"`
+	Inner     string `xml:",innerxml"`
+}
+
+// NewText returns a Text element holding s as its character data.
+func NewText(s string) *Text {
+	t := &Text{}
+	if s != "" {
+		t.Runs = []*Run{{Text: s}}
+	}
+	return t
+}
+
+// Content returns the displayed text of a Text element: its character data, Font, HGroup and
+// Rotate runs and Ruby bases in document order. A Space is returned as a single space and Ruby
+// annotations are excluded.
+func (t *Text) Content() string {
+	var b strings.Builder
+	for _, r := range t.Runs {
+		switch {
+		case r.Font != nil:
+			b.WriteString(r.Font.Text)
+		case r.Ruby != nil:
+			b.WriteString(r.Ruby.Rb)
+		case r.Space != nil:
+			b.WriteString(" ")
+		case r.HGroup != nil:
+			b.WriteString(r.HGroup.Text)
+		case r.Rotate != nil:
+			b.WriteString(r.Rotate.Text)
+		default:
+			b.WriteString(r.Text)
+		}
+	}
+	return b.String()
+}
+
+// Annotations returns the text of every Ruby annotation of a Text element.
+func (t *Text) Annotations() string {
+	var b strings.Builder
+	for _, r := range t.Runs {
+		if r.Ruby != nil && r.Ruby.Rt != nil {
+			b.WriteString(r.Ruby.Rt.Text)
+		}
+	}
+	return b.String()
+}
+
+// firstFont returns the first Font run of a Text element.
+func (t *Text) firstFont() *NestedFont {
+	for _, r := range t.Runs {
+		if r.Font != nil {
+			return r.Font
+		}
+	}
+	return nil
+}
+
+// MarshalXML encodes a Text element with its runs in document order.
+func (t *Text) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var b bytes.Buffer
+	inner := xml.NewEncoder(&b)
+	for _, r := range t.Runs {
+		var err error
+		switch {
+		case r.Font != nil:
+			err = inner.EncodeElement(r.Font, xml.StartElement{Name: xml.Name{Local: "Font"}})
+		case r.Ruby != nil:
+			err = inner.EncodeElement(r.Ruby, xml.StartElement{Name: xml.Name{Local: "Ruby"}})
+		case r.Space != nil:
+			err = inner.EncodeElement(r.Space, xml.StartElement{Name: xml.Name{Local: "Space"}})
+		case r.HGroup != nil:
+			err = inner.EncodeElement(r.HGroup, xml.StartElement{Name: xml.Name{Local: "HGroup"}})
+		case r.Rotate != nil:
+			err = inner.EncodeElement(r.Rotate, xml.StartElement{Name: xml.Name{Local: "Rotate"}})
+		default:
+			err = inner.EncodeToken(xml.CharData(r.Text))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := inner.Flush(); err != nil {
+		return err
+	}
+	return e.EncodeElement(textXML{
+		Halign:    t.Halign,
+		Hposition: t.Hposition,
+		Valign:    t.Valign,
+		Vposition: t.Vposition,
+		Direction: t.Direction,
+		Zposition: t.Zposition,
+		VariableZ: t.VariableZ,
+		Inner:     b.String(),
+	}, start)
+}
+
+// UnmarshalXML decodes a Text element, keeping its character data, Font and Ruby children in
+// document order. Unknown children are skipped.
+func (t *Text) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for _, a := range start.Attr {
+		switch a.Name.Local {
+		case "Halign":
+			t.Halign = a.Value
+		case "Hposition":
+			t.Hposition = a.Value
+		case "Valign":
+			t.Valign = a.Value
+		case "Vposition":
+			t.Vposition = a.Value
+		case "Direction":
+			t.Direction = a.Value
+		case "Zposition":
+			t.Zposition = a.Value
+		case "VariableZ":
+			t.VariableZ = a.Value
+		}
+	}
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.CharData:
+			if n := len(t.Runs); n > 0 && t.Runs[n-1].isText() {
+				t.Runs[n-1].Text += string(tok)
+			} else {
+				t.Runs = append(t.Runs, &Run{Text: string(tok)})
+			}
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "Font":
+				f := &NestedFont{}
+				if err := d.DecodeElement(f, &tok); err != nil {
+					return err
+				}
+				t.Runs = append(t.Runs, &Run{Font: f})
+			case "Ruby":
+				r := &Ruby{}
+				if err := d.DecodeElement(r, &tok); err != nil {
+					return err
+				}
+				t.Runs = append(t.Runs, &Run{Ruby: r})
+			case "Space":
+				sp := &Space{}
+				if err := d.DecodeElement(sp, &tok); err != nil {
+					return err
+				}
+				t.Runs = append(t.Runs, &Run{Space: sp})
+			case "HGroup":
+				h := &HGroup{}
+				if err := d.DecodeElement(h, &tok); err != nil {
+					return err
+				}
+				t.Runs = append(t.Runs, &Run{HGroup: h})
+			case "Rotate":
+				r := &Rotate{}
+				if err := d.DecodeElement(r, &tok); err != nil {
+					return err
+				}
+				t.Runs = append(t.Runs, &Run{Rotate: r})
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// UnmarshalXML decodes a NestedFont element. Character data that only indents its Text, Image
+// and Font children is dropped, so that it is not written back as content.
+func (f *NestedFont) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type nestedFont NestedFont
+	var v nestedFont
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	if len(v.Texts)+len(v.Image)+len(v.Font) > 0 && strings.TrimSpace(v.Text) == "" {
+		v.Text = ""
+	}
+	*f = NestedFont(v)
+	return nil
+}
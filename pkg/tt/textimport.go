@@ -0,0 +1,595 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Document formats returned by DetectFormat. All but FormatDCST are accepted by ImportText.
+const (
+	FormatDCST    = "dcst"
+	FormatSRT     = "srt"
+	FormatWebVTT  = "vtt"
+	FormatIMSC    = "imsc"
+	FormatInterop = "interop"
+)
+
+// ttmlNs is the namespace of TTML and IMSC documents.
+const ttmlNs = "http://www.w3.org/ns/ttml"
+
+// TextImportOptions configures ImportText.
+type TextImportOptions struct {
+	// Framerate is the EditRate of the resulting document, e.g. "24" or "24000/1001".
+	Framerate string
+	Reel      int
+	Display   int
+	Title     string
+	// Language is used when the source document does not declare one.
+	Language string
+}
+
+// cueLineSpacing is the Vposition step, in percent, between the lines of an imported cue. It
+// leaves room for a line of the default 42 point font.
+const cueLineSpacing = 6.5
+
+// cue is a single timed event of a source document, timed in milliseconds.
+type cue struct {
+	in, out float64
+	runs    []*Run
+}
+
+var (
+	srtTimingRegexp = regexp.MustCompile(`^\s*(\S+)\s*-->\s*(\S+)`)
+	markupRegexp    = regexp.MustCompile(`<[^>]*>`)
+	spaceRegexp     = regexp.MustCompile(`[ \t\r\n]+`)
+)
+
+// DetectFormat returns the format of a source document from its file name and content: one of
+// the Format constants.
+func DetectFormat(filename string, data []byte) (string, error) {
+	switch strings.ToLower(filename[strings.LastIndex(filename, ".")+1:]) {
+	case "srt":
+		return FormatSRT, nil
+	case "vtt":
+		return FormatWebVTT, nil
+	}
+	if bytes.HasPrefix(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), []byte("WEBVTT")) {
+		return FormatWebVTT, nil
+	}
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return "", fmt.Errorf("unknown document format: %w", err)
+	}
+	switch {
+	case root.XMLName.Local == "tt" && root.XMLName.Space == ttmlNs:
+		return FormatIMSC, nil
+	case root.XMLName.Local == "DCSubtitle":
+		return FormatInterop, nil
+	case root.XMLName.Local == "SubtitleReel":
+		return FormatDCST, nil
+	}
+	return "", fmt.Errorf("unknown document format: %s", root.XMLName.Local)
+}
+
+// ImportText builds a text profile SubtitleReel in the 2014 namespace from an SRT, WebVTT, IMSC
+// or Interop document. Cues are placed bottom center with one Text element per line, and italic
+// markup is kept as italic Font runs. Everything else that cannot be represented is reported as a
+// warning.
+func ImportText(data []byte, format string, opts TextImportOptions) (*SubtitleReel, []Finding, error) {
+	if opts.Framerate == "" {
+		opts.Framerate = "24"
+	}
+	if opts.Reel == 0 {
+		opts.Reel = 1
+	}
+	if format == FormatInterop {
+		return importInterop(data, opts)
+	}
+	var cues []cue
+	var findings []Finding
+	var err error
+	switch format {
+	case FormatSRT:
+		cues, err = readSRT(bytes.NewReader(data))
+	case FormatWebVTT:
+		cues, findings, err = readWebVTT(bytes.NewReader(data))
+	case FormatIMSC:
+		var lang string
+		cues, lang, err = readIMSC(bytes.NewReader(data))
+		if opts.Language == "" {
+			opts.Language = lang
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported format: %s", format)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.Language == "" {
+		opts.Language = "en"
+	}
+	s := newSubtitleReel(opts.Title, opts.Language, opts.Framerate, opts.Reel, opts.Display)
+	s.XMLName.Space = DCST2014
+	s.Xmlns = DCST2014
+	for i, c := range cues {
+		in, err := msToTimecode(c.in, s.EditRate)
+		if err != nil {
+			return nil, nil, err
+		}
+		out, err := msToTimecode(c.out, s.EditRate)
+		if err != nil {
+			return nil, nil, err
+		}
+		if out <= in {
+			findings = append(findings, Finding{
+				Severity: SeverityWarning,
+				Location: "cue " + strconv.Itoa(i+1),
+				Message:  "dropped cue shorter than one frame",
+			})
+			continue
+		}
+		sub := &Subtitle{
+			SpotNumber: strconv.Itoa(len(s.listFont().Subtitle) + 1),
+			TimeIn:     in,
+			TimeOut:    out,
+		}
+		// lines are stacked upwards from the bottom line at captionVposition.
+		lines := cueLines(c.runs)
+		for n, runs := range lines {
+			sub.Text = append(sub.Text, &Text{
+				Halign:    captionHalign,
+				Valign:    captionValign,
+				Vposition: strconv.FormatFloat(getFloat(captionVposition)+float64(len(lines)-1-n)*cueLineSpacing, 'f', -1, 64),
+				Runs:      runs,
+			})
+		}
+		s.listFont().Subtitle = append(s.listFont().Subtitle, sub)
+	}
+	if len(s.listFont().Subtitle) == 0 {
+		return nil, nil, errors.New("no cues found")
+	}
+	return s, findings, nil
+}
+
+// cueLines splits the runs of a cue at its line breaks into the runs of each non-empty line. A
+// cue without text is a single empty line.
+func cueLines(runs []*Run) [][]*Run {
+	var lines [][]*Run
+	var line []*Run
+	for _, r := range runs {
+		text := r.Text
+		if r.Font != nil {
+			text = r.Font.Text
+		}
+		for i, part := range strings.Split(text, "\n") {
+			if i > 0 && len(line) > 0 {
+				lines = append(lines, line)
+				line = nil
+			}
+			if part == "" {
+				continue
+			}
+			if r.Font != nil {
+				f := *r.Font
+				f.Text = part
+				line = append(line, &Run{Font: &f})
+			} else {
+				line = append(line, &Run{Text: part})
+			}
+		}
+	}
+	if len(line) > 0 || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// msToTimecode converts a time in milliseconds to a timecode counting frames of the EditRate at
+// its TimeCodeRate.
+func msToTimecode(ms float64, editRate string) (string, error) {
+	return timecode(int(math.Round(ms*getEditRate(editRate)/1000)), timecodeRate(editRate))
+}
+
+// readSRT reads the cues of a SubRip document.
+func readSRT(r io.Reader) ([]cue, error) {
+	var cues []cue
+	blocks, err := readBlocks(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range blocks {
+		// the counter line is optional in practice.
+		if len(b.lines) > 1 && !strings.Contains(b.lines[0], "-->") {
+			b.lines = b.lines[1:]
+		}
+		c, err := parseCue(b.lines, parseClock)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", b.n, err)
+		}
+		cues = append(cues, c)
+	}
+	return cues, nil
+}
+
+// readWebVTT reads the cues of a WebVTT document. Cue settings are reported and ignored.
+func readWebVTT(r io.Reader) ([]cue, []Finding, error) {
+	var cues []cue
+	var findings []Finding
+	blocks, err := readBlocks(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(blocks) == 0 || !strings.HasPrefix(strings.TrimPrefix(blocks[0].lines[0], "\ufeff"), "WEBVTT") {
+		return nil, nil, errors.New("missing WEBVTT header")
+	}
+	for _, b := range blocks[1:] {
+		first := b.lines[0]
+		if strings.HasPrefix(first, "NOTE") || first == "STYLE" || first == "REGION" {
+			continue
+		}
+		if !strings.Contains(first, "-->") {
+			b.lines = b.lines[1:]
+		}
+		if len(b.lines) == 0 {
+			continue
+		}
+		c, err := parseCue(b.lines, parseClock)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", b.n, err)
+		}
+		if settings := strings.Fields(b.lines[0]); len(settings) > 3 {
+			findings = append(findings, Finding{
+				Severity: SeverityWarning,
+				Location: "line " + strconv.Itoa(b.n),
+				Message:  "ignored cue settings " + strings.Join(settings[3:], " "),
+			})
+		}
+		cues = append(cues, c)
+	}
+	return cues, findings, nil
+}
+
+// block is a run of non-empty lines starting at line n.
+type block struct {
+	n     int
+	lines []string
+}
+
+// readBlocks splits a document into blocks separated by blank lines.
+func readBlocks(r io.Reader) ([]block, error) {
+	var blocks []block
+	var b block
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			if len(b.lines) > 0 {
+				blocks = append(blocks, b)
+			}
+			b = block{}
+			continue
+		}
+		if len(b.lines) == 0 {
+			b.n = n
+		}
+		b.lines = append(b.lines, line)
+	}
+	if len(b.lines) > 0 {
+		blocks = append(blocks, b)
+	}
+	return blocks, sc.Err()
+}
+
+// parseCue parses a timing line followed by the text lines of a cue.
+func parseCue(lines []string, clock func(string) (float64, error)) (cue, error) {
+	var c cue
+	m := srtTimingRegexp.FindStringSubmatch(lines[0])
+	if m == nil {
+		return c, fmt.Errorf("invalid cue timing: %q", lines[0])
+	}
+	var err error
+	if c.in, err = clock(m[1]); err != nil {
+		return c, err
+	}
+	if c.out, err = clock(m[2]); err != nil {
+		return c, err
+	}
+	c.runs = markupRuns(strings.Join(lines[1:], "\n"))
+	return c, nil
+}
+
+// parseClock parses a [HH:]MM:SS.mmm or HH:MM:SS,mmm time into milliseconds.
+func parseClock(s string) (float64, error) {
+	parts := strings.Split(strings.Replace(s, ",", ".", 1), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time: %s", s)
+	}
+	var ms float64
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 || (i < len(parts)-1 && strings.Contains(p, ".")) {
+			return 0, fmt.Errorf("invalid time: %s", s)
+		}
+		ms = ms*60 + v
+	}
+	return ms * 1000, nil
+}
+
+// markupRuns converts the text of an SRT or WebVTT cue to runs, keeping <i> as italic Font runs
+// and removing all other tags.
+func markupRuns(s string) []*Run {
+	var runs []*Run
+	italic := 0
+	add := func(text string) {
+		text = html.UnescapeString(text)
+		if text == "" {
+			return
+		}
+		if italic > 0 {
+			if n := len(runs); n > 0 && runs[n-1].Font != nil {
+				runs[n-1].Font.Text += text
+				return
+			}
+			runs = append(runs, &Run{Font: &NestedFont{Italic: "yes", Text: text}})
+			return
+		}
+		if n := len(runs); n > 0 && runs[n-1].isText() {
+			runs[n-1].Text += text
+			return
+		}
+		runs = append(runs, &Run{Text: text})
+	}
+	last := 0
+	for _, loc := range markupRegexp.FindAllStringIndex(s, -1) {
+		add(s[last:loc[0]])
+		last = loc[1]
+		switch strings.ToLower(strings.Fields(s[loc[0]+1:loc[1]-1] + " ")[0]) {
+		case "i":
+			italic++
+		case "/i":
+			if italic > 0 {
+				italic--
+			}
+		}
+	}
+	add(s[last:])
+	return runs
+}
+
+// readIMSC reads the timed paragraphs of an IMSC or TTML document and returns them with the
+// document's xml:lang.
+func readIMSC(r io.Reader) ([]cue, string, error) {
+	d := xml.NewDecoder(r)
+	var cues []cue
+	var lang string
+	timing := ttmlTiming{frameRate: 30, subFrameRate: 1, tickRate: 1}
+	// begin holds the begin time of every open element, italic its fontStyle.
+	var begin []float64
+	var italic []bool
+	var p *cue
+	add := func(text string, italic bool) {
+		if text == "" {
+			return
+		}
+		if italic {
+			if n := len(p.runs); n > 0 && p.runs[n-1].Font != nil {
+				p.runs[n-1].Font.Text += text
+				return
+			}
+			p.runs = append(p.runs, &Run{Font: &NestedFont{Italic: "yes", Text: text}})
+			return
+		}
+		if n := len(p.runs); n > 0 && p.runs[n-1].isText() {
+			p.runs[n-1].Text += text
+			return
+		}
+		p.runs = append(p.runs, &Run{Text: text})
+	}
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			parentBegin, parentItalic := 0.0, false
+			if n := len(begin); n > 0 {
+				parentBegin, parentItalic = begin[n-1], italic[n-1]
+			}
+			start, end, dur := "", "", ""
+			style := parentItalic
+			for _, a := range t.Attr {
+				switch a.Name.Local {
+				case "begin":
+					start = a.Value
+				case "end":
+					end = a.Value
+				case "dur":
+					dur = a.Value
+				case "fontStyle":
+					style = a.Value == "italic" || a.Value == "oblique"
+				case "lang":
+					if t.Name.Local == "tt" {
+						lang = a.Value
+					}
+				case "frameRate", "frameRateMultiplier", "subFrameRate", "tickRate":
+					if t.Name.Local == "tt" {
+						if err := timing.set(a.Name.Local, a.Value); err != nil {
+							return nil, "", err
+						}
+					}
+				}
+			}
+			b := parentBegin
+			if start != "" {
+				v, err := timing.parse(start)
+				if err != nil {
+					return nil, "", err
+				}
+				b += v
+			}
+			begin = append(begin, b)
+			italic = append(italic, style)
+			switch t.Name.Local {
+			case "p":
+				if end == "" && dur == "" {
+					return nil, "", fmt.Errorf("p element at %s has no end or dur", start)
+				}
+				c := cue{in: b}
+				if end != "" {
+					v, err := timing.parse(end)
+					if err != nil {
+						return nil, "", err
+					}
+					c.out = parentBegin + v
+				} else {
+					v, err := timing.parse(dur)
+					if err != nil {
+						return nil, "", err
+					}
+					c.out = b + v
+				}
+				p = &c
+			case "br":
+				if p != nil {
+					add("\n", false)
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "p" && p != nil {
+				for i, r := range p.runs {
+					if !r.isText() {
+						continue
+					}
+					r.Text = strings.NewReplacer(" \n", "\n", "\n ", "\n").Replace(r.Text)
+					if i == 0 {
+						r.Text = strings.TrimLeft(r.Text, " ")
+					}
+					if i == len(p.runs)-1 {
+						r.Text = strings.TrimRight(r.Text, " ")
+					}
+				}
+				cues = append(cues, *p)
+				p = nil
+			}
+			begin = begin[:len(begin)-1]
+			italic = italic[:len(italic)-1]
+		case xml.CharData:
+			if p != nil {
+				// collapse white space as per xml:space="default".
+				add(spaceRegexp.ReplaceAllString(string(t), " "), italic[len(italic)-1])
+			}
+		}
+	}
+	return cues, lang, nil
+}
+
+// ttmlTiming holds the timing parameters of a TTML document.
+type ttmlTiming struct {
+	frameRate, subFrameRate, tickRate float64
+}
+
+// set sets a ttp timing parameter.
+func (t *ttmlTiming) set(name, value string) error {
+	if name == "frameRateMultiplier" {
+		var num, den float64
+		if n, _ := fmt.Sscanf(value, "%g %g", &num, &den); n != 2 || den == 0 {
+			return fmt.Errorf("invalid frameRateMultiplier: %s", value)
+		}
+		t.frameRate = t.frameRate * num / den
+		return nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || v <= 0 {
+		return fmt.Errorf("invalid %s: %s", name, value)
+	}
+	switch name {
+	case "frameRate":
+		t.frameRate = v
+	case "subFrameRate":
+		t.subFrameRate = v
+	case "tickRate":
+		t.tickRate = v
+	}
+	return nil
+}
+
+// parse parses a TTML clock or offset time expression into milliseconds.
+func (t *ttmlTiming) parse(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if strings.Count(s, ":") >= 2 {
+		parts := strings.Split(s, ":")
+		if len(parts) > 4 {
+			return 0, fmt.Errorf("invalid time expression: %s", s)
+		}
+		var seconds float64
+		for i, p := range parts[:3] {
+			v, err := strconv.ParseFloat(p, 64)
+			if err != nil || (i < 2 && strings.Contains(p, ".")) {
+				return 0, fmt.Errorf("invalid time expression: %s", s)
+			}
+			seconds = seconds*60 + v
+		}
+		if len(parts) == 4 {
+			frames, sub := parts[3], "0"
+			if i := strings.Index(frames, "."); i >= 0 {
+				frames, sub = frames[:i], frames[i+1:]
+			}
+			f, err1 := strconv.ParseFloat(frames, 64)
+			sf, err2 := strconv.ParseFloat(sub, 64)
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid time expression: %s", s)
+			}
+			seconds += (f + sf/t.subFrameRate) / t.frameRate
+		}
+		return seconds * 1000, nil
+	}
+	units := map[string]float64{"h": 3600000, "m": 60000, "s": 1000, "ms": 1, "f": 1000 / t.frameRate, "t": 1000 / t.tickRate}
+	for _, unit := range []string{"ms", "h", "m", "s", "f", "t"} {
+		if strings.HasSuffix(s, unit) {
+			v, err := strconv.ParseFloat(strings.TrimSuffix(s, unit), 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid time expression: %s", s)
+			}
+			return v * units[unit], nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time expression: %s", s)
+}
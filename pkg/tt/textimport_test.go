@@ -0,0 +1,211 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// importedText returns the Text elements of the imported subtitles as encoded XML.
+func importedText(t *testing.T, s *SubtitleReel) [][]string {
+	t.Helper()
+	var subs [][]string
+	for _, sub := range subtitles(s) {
+		var texts []string
+		for _, text := range sub.texts() {
+			var b bytes.Buffer
+			if err := EncodeReel(&b, &SubtitleReel{SubtitleList: &SubtitleList{Font: []*Font{{Subtitle: []*Subtitle{{Text: []*Text{text}}}}}}}); err != nil {
+				t.Fatal(err)
+			}
+			enc := b.String()
+			enc = enc[strings.Index(enc, "<Text"):]
+			texts = append(texts, enc[:strings.Index(enc, "</Text>")+len("</Text>")])
+		}
+		subs = append(subs, texts)
+	}
+	return subs
+}
+
+func TestImportTextLines(t *testing.T) {
+	tests := []struct {
+		format string
+		doc    string
+		want   []string
+	}{
+		{FormatSRT, "1\n00:00:01,000 --> 00:00:02,000\nHello\n", []string{
+			`<Text Halign="center" Valign="bottom" Vposition="10">Hello</Text>`,
+		}},
+		{FormatSRT, "1\n00:00:01,000 --> 00:00:02,000\nFirst line\n<i>second</i> line\n", []string{
+			`<Text Halign="center" Valign="bottom" Vposition="16.5">First line</Text>`,
+			`<Text Halign="center" Valign="bottom" Vposition="10"><Font Italic="yes">second</Font> line</Text>`,
+		}},
+		{FormatWebVTT, "WEBVTT\n\n00:01.000 --> 00:02.000\n<i>one\ntwo</i>\nthree\n", []string{
+			`<Text Halign="center" Valign="bottom" Vposition="23"><Font Italic="yes">one</Font></Text>`,
+			`<Text Halign="center" Valign="bottom" Vposition="16.5"><Font Italic="yes">two</Font></Text>`,
+			`<Text Halign="center" Valign="bottom" Vposition="10">three</Text>`,
+		}},
+		{FormatIMSC, `<tt xmlns="http://www.w3.org/ns/ttml"><body><div><p begin="1s" end="2s">top<br/>bottom</p></div></body></tt>`, []string{
+			`<Text Halign="center" Valign="bottom" Vposition="16.5">top</Text>`,
+			`<Text Halign="center" Valign="bottom" Vposition="10">bottom</Text>`,
+		}},
+	}
+	for _, tc := range tests {
+		s, _, err := ImportText([]byte(tc.doc), tc.format, TextImportOptions{Framerate: "24"})
+		if err != nil {
+			t.Errorf("%s: %v", tc.format, err)
+			continue
+		}
+		got := importedText(t, s)
+		if len(got) != 1 || strings.Join(got[0], "\n") != strings.Join(tc.want, "\n") {
+			t.Errorf("%s: got %q, want %q", tc.format, got, tc.want)
+		}
+	}
+}
+
+func TestImportText(t *testing.T) {
+	srt := "1\n00:00:01,000 --> 00:00:02,500\nOne\n\n2\n00:00:03,000 --> 00:00:03,010\nToo short\n\n3\n00:01:00,000 --> 00:01:01,000\nThree\n"
+	s, findings, err := ImportText([]byte(srt), FormatSRT, TextImportOptions{Framerate: "25", Reel: 2, Title: "Title"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Xmlns != DCST2014 || s.EditRate != "25 1" || s.TimeCodeRate != "25" || s.ReelNumber != 2 || s.Language != "en" || s.ContentTitleText != "Title" {
+		t.Errorf("unexpected header %+v", s)
+	}
+	subs := subtitles(s)
+	if len(subs) != 2 {
+		t.Fatalf("got %d subtitles, want 2", len(subs))
+	}
+	for i, want := range [][3]string{{"1", "00:00:01:00", "00:00:02:13"}, {"2", "00:01:00:00", "00:01:01:00"}} {
+		if got := [3]string{subs[i].SpotNumber, subs[i].TimeIn, subs[i].TimeOut}; got != want {
+			t.Errorf("subtitle %d: got %v, want %v", i+1, got, want)
+		}
+	}
+	if len(findings) != 1 || findings[0].Location != "cue 2" {
+		t.Errorf("findings = %v, want the dropped cue 2", findings)
+	}
+
+	vtt := "WEBVTT\n\n00:01.000 --> 00:02.000 align:start line:0\nHello\n"
+	if _, findings, err := ImportText([]byte(vtt), FormatWebVTT, TextImportOptions{}); err != nil || len(findings) != 1 {
+		t.Errorf("cue settings: findings %v, error %v", findings, err)
+	}
+	imsc := `<tt xmlns="http://www.w3.org/ns/ttml" xml:lang="fr"><body><div><p begin="1s" dur="1s">Bonjour</p></div></body></tt>`
+	if s, _, err := ImportText([]byte(imsc), FormatIMSC, TextImportOptions{}); err != nil || s.Language != "fr" {
+		t.Errorf("IMSC language: %v", err)
+	}
+}
+
+func TestImportTextErrors(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		doc    string
+	}{
+		{FormatSRT, ""},
+		{FormatSRT, "1\nnot a timing\nHello\n"},
+		{FormatWebVTT, "00:01.000 --> 00:02.000\nHello\n"},
+		{FormatIMSC, `<tt xmlns="http://www.w3.org/ns/ttml"><body><p begin="1s">no end</p></body></tt>`},
+		{FormatDCST, "<SubtitleReel/>"},
+		{FormatInterop, "<SubtitleReel/>"},
+		{FormatInterop, "<DCSubtitle/>"},
+		{FormatInterop, `<DCSubtitle><Subtitle TimeIn="later" TimeOut="00:00:02:000"/></DCSubtitle>`},
+	} {
+		if _, _, err := ImportText([]byte(tc.doc), tc.format, TextImportOptions{}); err == nil {
+			t.Errorf("%s %q: no error", tc.format, tc.doc)
+		}
+	}
+}
+
+const interopDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<DCSubtitle Version="1.0">
+  <SubtitleID>a1b2c3d4-e5f6-4a7b-8c9d-0e1f2a3b4c5d</SubtitleID>
+  <MovieTitle>Interop</MovieTitle>
+  <ReelNumber>3</ReelNumber>
+  <Language>de</Language>
+  <LoadFont Id="Font1" URI="font.ttf"/>
+  <Comment>dropped</Comment>
+  <Font Id="Font1" Size="42">
+    <Subtitle SpotNumber="1" TimeIn="00:00:01:125" TimeOut="00:00:03:000" FadeUpTime="20" FadeDownTime="20">
+      <Text VAlign="bottom" VPosition="10" HAlign="center">Hallo</Text>
+    </Subtitle>
+  </Font>
+  <Subtitle SpotNumber="2" TimeIn="00:00:04.500" TimeOut="1250">
+    <Image VAlign="top" VPosition="5">image.png</Image>
+  </Subtitle>
+</DCSubtitle>
+`
+
+func TestImportInterop(t *testing.T) {
+	s, findings, err := ImportText([]byte(interopDocument), FormatInterop, TextImportOptions{Framerate: "24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Xmlns != DCST2014 || s.ContentTitleText != "Interop" || s.ReelNumber != 3 || s.Language != "de" {
+		t.Errorf("unexpected header %+v", s)
+	}
+	if len(s.LoadFont) != 1 || s.LoadFont[0].ID != "Font1" {
+		t.Errorf("LoadFont = %+v", s.LoadFont)
+	}
+	subs := subtitles(s)
+	if len(subs) != 2 {
+		t.Fatalf("got %d subtitles, want 2", len(subs))
+	}
+	// 125 ticks of 4 ms are half a second, 1250 ticks five seconds and 20 ticks two frames.
+	for i, want := range [][2]string{{"00:00:01:12", "00:00:03:00"}, {"00:00:04:12", "00:00:05:00"}} {
+		if got := [2]string{subs[i].TimeIn, subs[i].TimeOut}; got != want {
+			t.Errorf("subtitle %d timing = %v, want %v", i+1, got, want)
+		}
+	}
+	if subs[0].FadeUpTime != "00:00:00:02" {
+		t.Errorf("FadeUpTime = %q", subs[0].FadeUpTime)
+	}
+	texts := subs[0].texts()
+	if len(texts) != 1 || texts[0].Valign != "bottom" || texts[0].Vposition != "10" || texts[0].Halign != "center" || textContent(texts[0]) != "Hallo" {
+		t.Errorf("Text = %+v", texts)
+	}
+	images := subs[1].images()
+	if len(images) != 1 || images[0].Valign != "top" || images[0].Vposition != "5" {
+		t.Errorf("Image = %+v", images)
+	}
+	var messages []string
+	for _, f := range findings {
+		messages = append(messages, f.Message)
+	}
+	for _, want := range []string{"dropped Interop element Comment", "font reference font.ttf", "image reference image.png"} {
+		if !strings.Contains(strings.Join(messages, "\n"), want) {
+			t.Errorf("findings lack %q: %v", want, messages)
+		}
+	}
+}
+
+func TestImportTextStackedCaptions(t *testing.T) {
+	srt := "1\n00:00:01,000 --> 00:00:02,000\n[DOOR SLAMS]\nJOHN: Who's there?\n"
+	s, _, err := ImportText([]byte(srt), FormatSRT, TextImportOptions{Display: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findings := ValidateCaptions(s, DefaultCaptionRules); len(findings) != 0 {
+		t.Errorf("stacked caption lines: %v", findings)
+	}
+}
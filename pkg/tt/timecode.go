@@ -51,7 +51,7 @@ type Timecode struct {
 
 // NewTimecode initialises a new timecode type from a given framerate.
 func NewTimecode(frameRate float64) (*Timecode, error) {
-	if frameRate >= 0 {
+	if frameRate > 0 {
 		tc := &Timecode{
 			frameRate:   frameRate,
 			hours:       0,
@@ -87,7 +87,10 @@ func (tc *Timecode) GetTimeCode() string {
 // SetFrames sets the frame count in type Timecode.
 func (tc *Timecode) SetFrames(frameCount int) {
 	tc.totalFrames = frameCount
-	seconds, frames := divMod(float64(tc.totalFrames), tc.frameRate)
+	// non-drop frame timecode labels whole frames at the nominal rate, e.g. 24 for 23.976,
+	// as GetTimeCode and ParseTimecode do. Dividing by the fractional rate drifts by one
+	// frame every 1000 and gives two frames the same label.
+	seconds, frames := divMod(float64(tc.totalFrames), math.Ceil(tc.frameRate))
 	minutes, seconds := divMod(float64(seconds), 60)
 	hours, minutes := divMod(float64(minutes), 60)
 	tc.frames = int(frames)
@@ -104,3 +107,39 @@ func getFloat(s string) float64 {
 	f, _ := strconv.ParseFloat(s, 64)
 	return float64(f)
 }
+
+// ParseTimecode initialises a new timecode type from a given SMPTE timecode string and framerate.
+func ParseTimecode(s string, frameRate float64) (*Timecode, error) {
+	m := tcRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("invalid timecode: %s", s)
+	}
+	tc, err := NewTimecode(frameRate)
+	if err != nil {
+		return nil, err
+	}
+	h, _ := strconv.Atoi(m[1])
+	mi, _ := strconv.Atoi(m[2])
+	sec, _ := strconv.Atoi(m[3])
+	f, _ := strconv.Atoi(m[4])
+	fr := math.Ceil(frameRate)
+	if f >= int(fr) {
+		return nil, fmt.Errorf("invalid timecode: %s, frame %d out of range at %g fps", s, f, frameRate)
+	}
+	tc.SetFrames((h*3600+mi*60+sec)*int(fr) + f)
+	return tc, nil
+}
+
+// Frames returns the total frame count of type Timecode.
+func (tc *Timecode) Frames() int {
+	return tc.totalFrames
+}
+
+// getEditRate returns a value of type float64 from a given ST 428-7 EditRate value, e.g. "24000 1001".
+func getEditRate(s string) float64 {
+	var num, den float64
+	if n, _ := fmt.Sscanf(s, "%g %g", &num, &den); n < 2 || den == 0 {
+		return getFloat(s)
+	}
+	return num / den
+}
@@ -0,0 +1,152 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import "testing"
+
+func TestTimecode(t *testing.T) {
+	tests := []struct {
+		rate   float64
+		frames int
+		want   string
+	}{
+		{24, 0, "00:00:00:00"},
+		{24, 23, "00:00:00:23"},
+		{24, 24, "00:00:01:00"},
+		{24, 86400, "01:00:00:00"},
+		{25, 25*61 + 3, "00:01:01:03"},
+		{30, 30*3600 + 29, "01:00:00:29"},
+		{48, 47, "00:00:00:47"},
+		{48, 48 * 60, "00:01:00:00"},
+		{50, 50*90 + 10, "00:01:30:10"},
+		{60, 59, "00:00:00:59"},
+		{60, 60 * 3600 * 2, "02:00:00:00"},
+		// 23.976 counts whole frames at the nominal rate of 24.
+		{24000.0 / 1001, 24, "00:00:01:00"},
+		{24000.0 / 1001, 24*60 + 1, "00:01:00:01"},
+		{30000.0 / 1001, 30 * 3600, "01:00:00:00"},
+	}
+	for _, tc := range tests {
+		tcode, err := NewTimecode(tc.rate)
+		if err != nil {
+			t.Fatalf("NewTimecode(%g): %v", tc.rate, err)
+		}
+		tcode.SetFrames(tc.frames)
+		if got := tcode.GetTimeCode(); got != tc.want {
+			t.Errorf("rate %g, %d frames: got %s, want %s", tc.rate, tc.frames, got, tc.want)
+		}
+		if got := tcode.Frames(); got != tc.frames {
+			t.Errorf("rate %g: Frames() = %d, want %d", tc.rate, got, tc.frames)
+		}
+	}
+}
+
+func TestTimecodeFractionalRates(t *testing.T) {
+	for _, rate := range []float64{24000.0 / 1001, 30000.0 / 1001, 60000.0 / 1001} {
+		tc, err := NewTimecode(rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := 0; n < 10000; n++ {
+			tc.SetFrames(n)
+			s := tc.GetTimeCode()
+			got, err := ParseTimecode(s, rate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Frames() != n {
+				t.Fatalf("rate %g: frame %d labelled %s, which parses as frame %d", rate, n, s, got.Frames())
+			}
+		}
+	}
+}
+
+func TestParseTimecode(t *testing.T) {
+	tests := []struct {
+		tc     string
+		rate   float64
+		frames int
+		err    bool
+	}{
+		{"00:00:04:00", 24, 96, false},
+		{"00:00:01:00", 25, 25, false},
+		{"01:00:00:00", 30, 108000, false},
+		{"00:00:10:47", 48, 527, false},
+		{"00:00:01:12", 24000.0 / 1001, 36, false},
+		{"00;00;01:00", 60, 60, false},
+		{"00:00:01:23", 24, 47, false},
+		{"00:00:01:23", 24000.0 / 1001, 47, false},
+		{"00:00:01:24", 24, 0, true},
+		{"00:00:01:30", 24, 0, true},
+		{"00:00:01:24", 24000.0 / 1001, 0, true},
+		{"00:00:01:25", 25, 0, true},
+		{"00:00:01", 24, 0, true},
+		{"invalid", 24, 0, true},
+	}
+	for _, tc := range tests {
+		got, err := ParseTimecode(tc.tc, tc.rate)
+		if (err != nil) != tc.err {
+			t.Errorf("ParseTimecode(%q, %g) error = %v, want error %v", tc.tc, tc.rate, err, tc.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got.Frames() != tc.frames {
+			t.Errorf("ParseTimecode(%q, %g) = %d frames, want %d", tc.tc, tc.rate, got.Frames(), tc.frames)
+		}
+		if s := got.GetTimeCode(); s != tc.tc && tc.tc[2] == ':' {
+			t.Errorf("ParseTimecode(%q, %g) formats as %s", tc.tc, tc.rate, s)
+		}
+	}
+}
+
+func TestNewTimecodeRejectsNegativeRates(t *testing.T) {
+	for _, rate := range []float64{-1, 0} {
+		if _, err := NewTimecode(rate); err == nil {
+			t.Errorf("NewTimecode(%g) returned no error", rate)
+		}
+	}
+}
+
+func TestEditRates(t *testing.T) {
+	tests := []struct {
+		frameRate, editRate string
+		timecodeRate        int
+	}{
+		{"24", "24 1", 24},
+		{"25", "25 1", 25},
+		{"48", "48 1", 48},
+		{"24000/1001", "24000 1001", 24},
+		{"30000/1001", "30000 1001", 30},
+	}
+	for _, tc := range tests {
+		if got := toEditRate(tc.frameRate); got != tc.editRate {
+			t.Errorf("toEditRate(%s) = %s, want %s", tc.frameRate, got, tc.editRate)
+		}
+		if got := timecodeRate(tc.editRate); got != tc.timecodeRate {
+			t.Errorf("timecodeRate(%s) = %d, want %d", tc.editRate, got, tc.timecodeRate)
+		}
+	}
+}
@@ -33,15 +33,13 @@ import (
 	"fmt"
 	"image"
 	"image/png"
-	"io"
 	"io/ioutil"
-	"log"
+	"math"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
-	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	uuid "github.com/satori/go.uuid"
@@ -49,7 +47,6 @@ import (
 
 const (
 	fontName    = "232c45d8-fde8-4e5e-86b9-86e96354daf3"
-	defaultFont = "resources/font/" + fontName
 	startTime   = "00:00:00:00"
 	reelNo      = "_r"
 	xmlFileExt  = ".xml"
@@ -75,6 +72,7 @@ var (
 	// Reel is the reel number and shall be a positive integer reflecting the reel number the XML is to be used for
 	Reel int
 	// Duration is a positive integer value that maps to the ContainerDuration entry of the resulting MXF track file.
+	// Zero derives it from the last TimeOut of the document.
 	Duration int
 	// Display identifies what DisplayType value to be used. 0 = MainSubtitle, >= 1 = ClosedCaption.
 	Display int
@@ -88,180 +86,126 @@ var (
 	Language string
 	// Output is the target output directory.
 	Output string
+	// Fonts is a list of OpenType/TrueType font paths to be used in place of the bundled default font.
+	Fonts []string
+	// Subset signals that font resources are to be reduced to the glyphs used in the document.
+	Subset bool
+	// Captions signals that the closed caption mode is to be used. It implies the text profile and
+	// a ClosedCaption DisplayType, and validates the document with DefaultCaptionRules.
+	Captions bool
+	// Direction is the writing direction of the generated Text element, "ltr", "rtl", "ttb" or "btt".
+	Direction string
+	// RubyText is a ruby annotated base for the generated Text element, given as "base=annotation".
+	RubyText string
+	// Zposition is the depth of the generated Text or Image element in percent of the screen width.
+	Zposition string
+	// VariableZ is a depth curve for the generated Text or Image element. It is written as a
+	// LoadVariableZ element, see ParseVariableZ for its format.
+	VariableZ string
+	// Checksums signals that a JSON and CSV DeliveryManifest is written beside the output of CreateXML.
+	Checksums bool
+	// Archive packages the output of CreateXML into a single "zip" or "tar.gz" archive with a
+	// checksum manifest.
+	Archive string
+	// Composition signals that a CPL, PKL, ASSETMAP and VOLINDEX of the track file are written
+	// beside the output of CreateXML.
+	Composition bool
+	// TrackWrapper is the backend used by CreateMXF to write track files.
+	TrackWrapper Wrapper = &ASDCPWrapper{}
 	// unexported variables
-	docID         string    = uuidType4()
-	mxfID         string    = uuidType4()
-	issueDate     time.Time = time.Now()
-	timecodeRate  string
 	dcst2007      string = "http://www.smpte-ra.org/schemas/428-7/2007/DCST"
 	dcst2010      string = "dcst2010"
 	dcst2014      string = "dcst2014"
-	xmlNs         string = "http://www.smpte-ra.org/schemas/428-7/2014/DCST"
+	xmlNs         string = DCST2014
 	xmlNsSubtitle        = map[string]string{
-		"http://www.smpte-ra.org/schemas/428-7/2010/DCST": dcst2010,
-		"http://www.smpte-ra.org/schemas/428-7/2014/DCST": dcst2014,
+		DCST2010: dcst2010,
+		DCST2014: dcst2014,
 	}
-	fontPath   string = getFont()
-	mxfFileExt string = "_sub.mxf"
-	frameCount int
+	// newUUID and now are the sources of generated UUIDs and the IssueDate. They are only
+	// replaced by the tests, to make the output reproducible.
+	newUUID = func() string { return uuid.NewV4().String() }
+	now     = time.Now
 )
 
 // ================================
 // Begin exported functions
 
-// CreateXML creates a St 428-7 compliant minimal XML document.
+// CreateXML creates a St 428-7 compliant minimal XML document using the package variables.
+// See Job for generating documents concurrently.
 func CreateXML(Txt, Img, Track, Encrypt bool, Reel, Display, Duration int, FrameRate, Language, Title, Template, Output string) error {
-	var subElement *Subtitle
-	fps, _ := strconv.Atoi(Framerate)
-	if Template != "" {
-		s, err := parseXML(Template)
-		if err != nil {
-			fmt.Printf("%s\nunable to use template, running with default values\n", err)
-		} else {
-			xmlNs = s.XMLName.Space
-			Title = s.ContentTitleText
-			Language = s.Language
-			Framerate = s.EditRate[:2]
-			timecodeRate = s.TimeCodeRate
-			if s.DisplayType == "MainSubtitle" {
-				Display = 0
-			}
-			if s.DisplayType == "ClosedCaption" {
-				Display = 1
-				mxfFileExt = "_cap.mxf"
-			}
-		}
-	}
-	dxml := SubtitleReel{
-		Xmlns:            xmlNs,
-		ID:               "urn:uuid:" + docID,
-		ContentTitleText: Title,
-		IssueDate:        issueDate.Format(time.RFC3339)[:19] + "-00:00",
-		ReelNumber:       Reel,
-		Language:         Language,
-		EditRate:         Framerate + " 1",
-		TimeCodeRate:     Framerate,
-		StartTime:        startTime,
-		SubtitleList:     &Font{},
-	}
+	_, err := Job{
+		Text:        Txt,
+		Image:       Img,
+		Track:       Track,
+		Encrypt:     Encrypt,
+		Reel:        Reel,
+		Display:     Display,
+		Duration:    Duration,
+		Framerate:   FrameRate,
+		Language:    Language,
+		Title:       Title,
+		Template:    Template,
+		Output:      Output,
+		Fonts:       Fonts,
+		Subset:      Subset,
+		Captions:    Captions,
+		Direction:   Direction,
+		RubyText:    RubyText,
+		Zposition:   Zposition,
+		VariableZ:   VariableZ,
+		Checksums:   Checksums,
+		Archive:     Archive,
+		Wrapper:     TrackWrapper,
+		Composition: Composition,
+	}.Run()
+	return err
+}
 
-	if Display == 0 {
-		dxml.DisplayType = "MainSubtitle"
-	}
-	if Display >= 1 {
-		dxml.DisplayType = "ClosedCaption"
+// CreateMXF creates a D-Cinema track file using the Wrapper set in TrackWrapper, which defaults
+// to asdcp-wrap at your system's $PATH.
+func CreateMXF(encrypt bool, frameRate, output, filename string, reel, duration int) error {
+	mxfFileExt := "_sub.mxf"
+	if s, err := parseXML(filename); err == nil && s.DisplayType == "ClosedCaption" {
 		mxfFileExt = "_cap.mxf"
 	}
-	if Img {
-		Txt = false
-		imageID := makePNG(Output)
-		subElement = &Subtitle{
-			TimeIn:  getTimeIn(fps),
-			TimeOut: getTimeOut(fps),
-			Image: []*Image{
-				&Image{
-					Image: urn + imageID,
-				},
-			},
-		}
-	}
-	if Txt {
-		dxml.LoadFont = &LoadFont{
-			ID:   "MinRefFont",
-			Font: urn + fontName,
-		}
-		subElement = &Subtitle{
-			TimeIn:  getTimeIn(fps),
-			TimeOut: getTimeOut(fps),
-			Text: []*Text{
-				&Text{
-					Text: "",
-				},
-			},
-		}
-	}
-	dxml.SubtitleList.Subtitle = append(dxml.SubtitleList.Subtitle, subElement)
-
-	// Begin XML Writing
-	filename := docID + reelNo + strconv.Itoa(Reel) + xmlFileExt
-
-	if enc, err := xml.MarshalIndent(dxml, "", "  "); err == nil {
-		enc = []byte(xml.Header + string(enc))
-
-		// Write XML to StdOut
-		if Output == "" {
-			fmt.Printf("%s\n", enc)
-			return nil
-		}
-		if Output != "" {
-			// Write out files to given output path.
-			xmlOutputPath := filepath.Join(Output, filename)
-			f, err := os.Create(xmlOutputPath)
-			if err != nil {
-				fmt.Println(err)
-			}
-			if _, err := f.Write(enc); err != nil {
-				f.Close() // Close file and return error.
-				fmt.Println(err)
-			}
-			if err := f.Close(); err != nil {
-				fmt.Println(err)
-			}
-
-			// Copy Font to output path
-			if Txt {
-				fontFileName, err := filepath.Abs(fontPath)
-				if err != nil {
-					fmt.Println(err)
-					fmt.Println("unable to resolve default font resource")
-				}
-				if err := copy(fontFileName, Output); err != nil {
-					fmt.Println(err)
-				}
-			}
-
-			// Handle Track File writing
-			if Track {
-				if testBinary() {
-					if err := CreateMXF(Encrypt, Framerate, Output, xmlOutputPath, Reel, Duration); err != nil {
-						fmt.Println("error writing MXF")
-						return err
-					}
-					return nil
-				}
-				fmt.Println("asdcp not installed or not available at $PATH")
-			}
-		}
-
+	assetUUID := uuidType4()
+	mxfFilename := assetUUID + reelNo + strconv.Itoa(reel) + mxfFileExt
+	opts, err := wrapTrack(TrackWrapper, encrypt, frameRate, filename, filepath.Join(output, mxfFilename), assetUUID, duration)
+	if err != nil {
+		return err
 	}
-	return nil
-}
-
-// CreateMXF creates a D-Cinema track file using pre-defined asdcp-wrap command line arguments.
-// It expects asdcp-wrap to be present at yout system's $PATH.
-func CreateMXF(encrypt bool, frameRate, output, filename string, reel, duration int) error {
-	mxfFilename := mxfID + reelNo + strconv.Itoa(reel) + mxfFileExt
-	outputPath := filepath.Join(output, mxfFilename)
-	asArgs := []string{}
-	keyID := uuidType4()
-	keyStr := randomHex()
-
 	if encrypt {
-		asArgs = []string{"-L", "-j", keyID, "-k", keyStr, "-a", mxfID, "-d", strconv.Itoa(duration), "-p", frameRate, filename, outputPath}
 		fmt.Printf(`
 Keep the following safe!
 KeyID: %s
 KeyString: %s
-`, keyID, keyStr)
+`, opts.KeyID, opts.Key)
 	}
-	if !encrypt {
-		asArgs = []string{"-L", "-a", mxfID, "-d", strconv.Itoa(duration), "-p", frameRate, filename, outputPath}
+	return nil
+}
+
+// WriteXML writes a SubtitleReel to the output directory as uuid_rN.xml and returns the file path.
+// The document is streamed event by event with an Encoder.
+func WriteXML(s *SubtitleReel, output string) (string, error) {
+	filename := strings.TrimPrefix(s.ID, urn) + reelNo + strconv.Itoa(s.ReelNumber) + xmlFileExt
+	xmlOutputPath := filepath.Join(output, filename)
+	f, err := os.Create(xmlOutputPath)
+	if err != nil {
+		return "", err
 	}
-	cmd := exec.Command(as, asArgs...)
-	if _, err := cmd.Output(); err != nil {
-		return err
+	if err := EncodeReel(f, s); err != nil {
+		f.Close()
+		return "", err
 	}
-	return nil
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return xmlOutputPath, nil
+}
+
+// ParseXML parses a given ST 428-7 XML document into type SubtitleReel.
+func ParseXML(filename string) (*SubtitleReel, error) {
+	return parseXML(filename)
 }
 
 // End exported functions
@@ -270,21 +214,21 @@ KeyString: %s
 // Begin unexported functions
 
 // parseXML parsing a given ST 428-7 XML document to use the the document's global properties in the newly created document.
+// Documents not present on the local file system are resolved from Resources, e.g. DefaultTemplate.
 func parseXML(filename string) (*SubtitleReel, error) {
 	var s *SubtitleReel
-	file, err := filepath.Abs(filename)
-	if err != nil {
-		fmt.Println("given file path is not absolute:", err)
-	}
-	if path.Ext(file) == ".xml" {
-		f, err := os.Open(file)
-		defer f.Close()
+	var err error
+	if path.Ext(filename) == ".xml" {
+		f, err := openFile(filename)
 		if err != nil {
 			fmt.Println(err)
 			return s, err
 		}
+		defer f.Close()
 		bytestream, _ := ioutil.ReadAll(f)
-		xml.Unmarshal(bytestream, &s)
+		if err := xml.Unmarshal(bytestream, &s); err != nil {
+			return s, err
+		}
 		for xmlns := range xmlNsSubtitle {
 			if xmlns == s.XMLName.Space {
 				return s, nil
@@ -300,8 +244,73 @@ func parseXML(filename string) (*SubtitleReel, error) {
 	return s, err
 }
 
-// makePNG ...
-func makePNG(output string) string {
+// newSubtitleReel returns a SubtitleReel with its global properties set and an empty SubtitleList.
+// The frame rate is either a whole number, e.g. "24", or a rational, e.g. "24000/1001".
+func newSubtitleReel(title, language, frameRate string, reel, display int) *SubtitleReel {
+	editRate := toEditRate(frameRate)
+	s := &SubtitleReel{
+		Xmlns:            xmlNs,
+		ID:               urn + uuidType4(),
+		ContentTitleText: title,
+		IssueDate:        issueDate(),
+		ReelNumber:       reel,
+		Language:         language,
+		EditRate:         editRate,
+		TimeCodeRate:     strconv.Itoa(timecodeRate(editRate)),
+		StartTime:        startTime,
+		DisplayType:      "MainSubtitle",
+		SubtitleList:     &SubtitleList{},
+	}
+	if display >= 1 {
+		s.DisplayType = "ClosedCaption"
+	}
+	return s
+}
+
+// listFont returns the first Font of the SubtitleList of a SubtitleReel, to which generated
+// Subtitle events are added. A SubtitleList and Font without attributes are added when missing.
+func (s *SubtitleReel) listFont() *Font {
+	if s.SubtitleList == nil {
+		s.SubtitleList = &SubtitleList{}
+	}
+	if len(s.SubtitleList.Font) == 0 || s.SubtitleList.Font[0] == nil {
+		s.SubtitleList.Font = append([]*Font{{}}, s.SubtitleList.Font...)
+	}
+	return s.SubtitleList.Font[0]
+}
+
+// checkFrameRate returns an error unless a frame rate is a positive whole number, e.g. "24", or
+// a positive rational, e.g. "24000/1001".
+func checkFrameRate(frameRate string) error {
+	num, den := frameRate, "1"
+	if i := strings.Index(frameRate, "/"); i >= 0 {
+		num, den = frameRate[:i], frameRate[i+1:]
+	}
+	n, errNum := strconv.Atoi(num)
+	d, errDen := strconv.Atoi(den)
+	if errNum != nil || errDen != nil || n <= 0 || d <= 0 {
+		return fmt.Errorf("invalid frame rate %q", frameRate)
+	}
+	return nil
+}
+
+// toEditRate returns the EditRate of a frame rate given as a whole number, e.g. "24", or a
+// rational, e.g. "24000/1001".
+func toEditRate(frameRate string) string {
+	if i := strings.Index(frameRate, "/"); i > 0 {
+		return frameRate[:i] + " " + frameRate[i+1:]
+	}
+	return frameRate + " 1"
+}
+
+// timecodeRate returns the TimeCodeRate of an EditRate, its frame rate rounded up.
+func timecodeRate(editRate string) int {
+	return int(math.Ceil(getEditRate(editRate)))
+}
+
+// makePNG writes a transparent PNG image named by a new UUID to output and returns the UUID.
+// Nothing is written when output is empty.
+func makePNG(output string) (string, error) {
 	const width, height = 128, 128
 	ID := uuidType4()
 
@@ -317,25 +326,29 @@ func makePNG(output string) string {
 		filename := filepath.Join(output, ID)
 		f, err := os.Create(filename)
 		if err != nil {
-			log.Fatal(err)
+			return "", err
 		}
 
 		if err := png.Encode(f, img); err != nil {
 			f.Close()
-			log.Fatal(err)
+			return "", err
 		}
 
 		if err := f.Close(); err != nil {
-			log.Fatal(err)
+			return "", err
 		}
 	}
-	return ID
+	return ID, nil
 }
 
 // uuidType4 generates a canonical string representation of a Type-4 UUID.
 func uuidType4() string {
-	u := uuid.NewV4()
-	return u.String()
+	return newUUID()
+}
+
+// issueDate returns the IssueDate of a generated document.
+func issueDate() string {
+	return now().Format(time.RFC3339)[:19] + "-00:00"
 }
 
 // randomHex returns a random 16 byte hex string.
@@ -345,96 +358,4 @@ func randomHex() string {
 	return hex.EncodeToString(bytes)
 }
 
-// getFont returns the default Font resource
-func getFont() string {
-	exe, _ := os.Executable()
-	dir, _ := filepath.Split(filepath.Dir(exe))
-	return filepath.Join(dir, defaultFont)
-}
-
-// copy facilitates a standard OS copy function
-func copy(file, dst string) error {
-	// stat the file
-	stat, err := os.Stat(file)
-	if err != nil {
-		fmt.Println("unable to stat file: ", file)
-		return err
-	}
-
-	// confirm file is a regular file
-	if !stat.Mode().IsRegular() {
-		fmt.Println("not regular file:", file)
-		return nil
-	}
-
-	// read the file
-	f, err := os.Open(file)
-	if err != nil {
-		fmt.Println("unable to read file: ", stat.Name())
-		return err
-	}
-	defer f.Close()
-	// create the destination
-	filepath := filepath.Join(dst, stat.Name())
-	target, err := os.Create(filepath)
-	if err != nil {
-		fmt.Println("unable to read destination: ", dst)
-		return err
-	}
-	defer target.Close()
-
-	// write the file to destination
-	if _, err := io.Copy(target, f); err != nil {
-		fmt.Println("copy process interupted: ", err)
-		return err
-	}
-	return nil
-}
-
-// testBinary performs a boolean assesment of the asdcp-wrap binary at $PATH
-func testBinary() bool {
-	args := []string{as}
-
-	if runtime.GOOS == "windows" {
-		_, err := exec.Command("where", args...).Output()
-		if err != nil {
-			return false
-		}
-		return true
-	}
-
-	if runtime.GOOS == "darwin" || runtime.GOOS == "linux" {
-		_, err := exec.Command("which", args...).Output()
-		if err != nil {
-			return false
-		}
-		return true
-	}
-
-	return false
-}
-
-// getTimeIn generates a compliant Subtitle TimeIn attribute value.
-func getTimeIn(fps int) string {
-	if Reel == 1 {
-		frameCount = r1TimeIn * fps
-	} else {
-		frameCount = defTimeIn * fps
-	}
-	return getTimecode(frameCount)
-}
-
-// getTimeOut generates a compliant Subtitle TimeOut attribute value.
-func getTimeOut(fps int) string {
-	frameCount = frameCount + minDuration
-	return getTimecode(frameCount)
-}
-
-// getTimecode sets parameters to generate acompliant timecode.
-func getTimecode(frameCount int) string {
-	tc, _ := NewTimecode(getFloat(Framerate))
-	tc.SetFrames(frameCount)
-	return tc.GetTimeCode()
-}
-
 // End unexported functions
@@ -0,0 +1,181 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// goldenDir holds the expected output of CreateXML.
+const goldenDir = "../../resources/sample/golden"
+
+// deterministic replaces newUUID with a counter and now with a fixed time for the duration of a
+// test. Tests calling it must not run in parallel.
+func deterministic(t *testing.T) {
+	t.Helper()
+	savedUUID, savedNow := newUUID, now
+	n := 0
+	newUUID = func() string {
+		n++
+		return fmt.Sprintf("00000000-0000-4000-8000-%012d", n)
+	}
+	now = func() time.Time {
+		return time.Date(2020, 11, 3, 11, 7, 39, 0, time.UTC)
+	}
+	t.Cleanup(func() { newUUID, now = savedUUID, savedNow })
+}
+
+func TestCreateXML(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    bool
+		image   bool
+		display int
+		reel    int
+		timeIn  string
+	}{
+		{"text_main_r1", true, false, 0, 1, "00:00:04:00"},
+		{"text_main_r2", true, false, 0, 2, "00:00:01:00"},
+		{"text_cc_r1", true, false, 1, 1, "00:00:04:00"},
+		{"text_cc_r2", true, false, 1, 2, "00:00:01:00"},
+		{"image_main_r1", false, true, 0, 1, "00:00:04:00"},
+		{"image_main_r2", false, true, 0, 2, "00:00:01:00"},
+		{"image_cc_r1", false, true, 1, 1, "00:00:04:00"},
+		{"image_cc_r2", false, true, 1, 2, "00:00:01:00"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			deterministic(t)
+			output := t.TempDir()
+			if err := CreateXML(tc.text, tc.image, false, false, tc.reel, tc.display, 24, "24", "en", "MyTitle", "", output); err != nil {
+				t.Fatal(err)
+			}
+			files, err := filepath.Glob(filepath.Join(output, "*.xml"))
+			if err != nil || len(files) != 1 {
+				t.Fatalf("got XML documents %v, %v, want 1", files, err)
+			}
+			got, err := ioutil.ReadFile(files[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join(goldenDir, tc.name+".xml")
+			if *update {
+				if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("output differs from %s:\n%s", golden, got)
+			}
+
+			var s SubtitleReel
+			if err := xml.Unmarshal(got, &s); err != nil {
+				t.Fatal(err)
+			}
+			wantDisplay := []string{"MainSubtitle", "ClosedCaption"}[tc.display]
+			if s.DisplayType != wantDisplay {
+				t.Errorf("DisplayType = %s, want %s", s.DisplayType, wantDisplay)
+			}
+			if s.ReelNumber != tc.reel {
+				t.Errorf("ReelNumber = %d, want %d", s.ReelNumber, tc.reel)
+			}
+			subs := subtitles(&s)
+			if len(subs) != 1 {
+				t.Fatalf("got %d Subtitle events, want 1", len(subs))
+			}
+			if subs[0].TimeIn != tc.timeIn {
+				t.Errorf("TimeIn = %s, want %s", subs[0].TimeIn, tc.timeIn)
+			}
+			if tc.text != (len(subs[0].Text) == 1) || tc.image != (len(subs[0].Image) == 1) {
+				t.Errorf("got %d Text and %d Image elements", len(subs[0].Text), len(subs[0].Image))
+			}
+		})
+	}
+}
+
+func TestCreateXMLDeterministic(t *testing.T) {
+	var outputs [2][]byte
+	for i := range outputs {
+		deterministic(t)
+		output := t.TempDir()
+		if err := CreateXML(true, false, false, false, 1, 0, 24, "24", "en", "MyTitle", "", output); err != nil {
+			t.Fatal(err)
+		}
+		files, _ := filepath.Glob(filepath.Join(output, "*.xml"))
+		if len(files) != 1 {
+			t.Fatalf("got XML documents %v, want 1", files)
+		}
+		outputs[i], _ = ioutil.ReadFile(files[0])
+	}
+	if !bytes.Equal(outputs[0], outputs[1]) {
+		t.Errorf("output differs between runs:\n%s\n%s", outputs[0], outputs[1])
+	}
+}
+
+func TestMakePNGReturnsErrors(t *testing.T) {
+	if _, err := makePNG(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("makePNG wrote to a missing directory")
+	}
+	output := t.TempDir()
+	ID, err := makePNG(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findings := CheckImage(filepath.Join(output, ID), ImageCheckOptions{}); HasErrors(findings) {
+		t.Error(findings)
+	}
+}
+
+func TestRunContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	output := t.TempDir()
+	j := Job{Text: true, Track: true, Reel: 1, Framerate: "24", Language: "en", Title: "No Title",
+		Output: output, Wrapper: &FakeWrapper{}, Log: ioutil.Discard}
+	for _, archive := range []string{"", ArchiveZip} {
+		j.Archive = archive
+		if _, err := j.RunContext(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("archive %q: RunContext() = %v, want %v", archive, err, context.Canceled)
+		}
+	}
+	if files, _ := ioutil.ReadDir(output); len(files) != 0 {
+		t.Errorf("cancelled job wrote %d files", len(files))
+	}
+}
@@ -0,0 +1,123 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Finding severities.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Finding is a single issue reported by a validation check.
+type Finding struct {
+	Severity string `json:"severity"`
+	Location string `json:"location"`
+	Message  string `json:"message"`
+}
+
+// String returns a human readable representation of the finding.
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.Location, f.Message)
+}
+
+// findingList collects the findings of a check.
+type findingList []Finding
+
+// add appends a finding with a formatted message.
+func (l *findingList) add(severity, location, format string, a ...interface{}) {
+	*l = append(*l, Finding{
+		Severity: severity,
+		Location: location,
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+// at returns a function adding findings at the given location.
+func (l *findingList) at(location string) func(severity, format string, a ...interface{}) {
+	return func(severity, format string, a ...interface{}) {
+		l.add(severity, location, format, a...)
+	}
+}
+
+// subtitleLocation returns the location of the i-th Subtitle event of a document: its SpotNumber,
+// or its position counted from 1 when it has none.
+func subtitleLocation(i int, sub *Subtitle) string {
+	if sub.SpotNumber == "" {
+		return "Subtitle #" + strconv.Itoa(i+1)
+	}
+	return "Subtitle " + sub.SpotNumber
+}
+
+// HasErrors reports whether any finding is of SeverityError.
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate runs the document checks that need no external resources: the namespace and the
+// 2014 constructs a 2010 document may not hold, ValidateText, ValidateDepth with
+// DefaultDepthLimits and, for a ClosedCaption document, ValidateCaptions with DefaultCaptionRules.
+func Validate(s *SubtitleReel) []Finding {
+	var findings []Finding
+	ns := namespace(s)
+	if _, ok := xmlNsSubtitle[ns]; !ok {
+		findings = append(findings, Finding{
+			Severity: SeverityError,
+			Location: "SubtitleReel",
+			Message:  "unsupported namespace: " + ns,
+		})
+	}
+	if xmlNsSubtitle[ns] == dcst2010 {
+		var names []string
+		for _, name := range constructs2014(s) {
+			// Ruby is reported by ValidateText.
+			if name != "Ruby" {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			findings = append(findings, Finding{
+				Severity: SeverityError,
+				Location: "SubtitleReel",
+				Message:  "not permitted in a 2010 document: " + strings.Join(names, ", "),
+			})
+		}
+	}
+	findings = append(findings, ValidateText(s)...)
+	findings = append(findings, ValidateDepth(s, DefaultDepthLimits)...)
+	if s.DisplayType == "ClosedCaption" {
+		findings = append(findings, ValidateCaptions(s, DefaultCaptionRules)...)
+	}
+	return findings
+}
@@ -0,0 +1,177 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/jack-watts/empty-tt/pkg/mxf"
+)
+
+// VerifyOptions configures VerifyTrackFile.
+type VerifyOptions struct {
+	// Source is the XML document the track file was wrapped from.
+	Source string
+	// Resources is the directory holding the source font and image resources. Defaults to the
+	// directory of Source.
+	Resources string
+	// AssetUUID is the expected track file AssetUUID. Empty skips the check.
+	AssetUUID string
+	// Duration is the expected ContainerDuration. Zero skips the check.
+	Duration int
+	// Key is the AES-128 key of an encrypted track file.
+	Key []byte
+}
+
+// VerifyTrackFile confirms that a timed text track file matches the document it was wrapped from.
+// The embedded XML is compared byte for byte, falling back to a semantic comparison, every
+// LoadFont and Image resource is checked against the generic stream partitions, and the
+// ContainerDuration and AssetUUID are checked against the expected values and the last TimeOut.
+func VerifyTrackFile(filename string, opts VerifyOptions) []Finding {
+	var findings []Finding
+	report := func(severity, format string, a ...interface{}) {
+		findings = append(findings, Finding{
+			Severity: severity,
+			Location: filename,
+			Message:  fmt.Sprintf(format, a...),
+		})
+	}
+	if opts.Resources == "" {
+		opts.Resources = filepath.Dir(opts.Source)
+	}
+
+	t, err := mxf.Open(filename, opts.Key)
+	if err != nil {
+		report(SeverityError, "unable to read track file: %s", err)
+		return findings
+	}
+	if t.Encrypted {
+		report(SeverityError, "track file is encrypted, a key is required")
+		return findings
+	}
+	src, err := ioutil.ReadFile(opts.Source)
+	if err != nil {
+		report(SeverityError, "unable to read source document: %s", err)
+		return findings
+	}
+	var s *SubtitleReel
+	if err := xml.Unmarshal(src, &s); err != nil {
+		report(SeverityError, "invalid source document: %s", err)
+		return findings
+	}
+	var embedded *SubtitleReel
+	if err := xml.Unmarshal(t.XML, &embedded); err != nil {
+		report(SeverityError, "invalid embedded document: %s", err)
+		return findings
+	}
+
+	// embedded document.
+	if !bytes.Equal(t.XML, src) {
+		if d := Diff(s, embedded, 0); !d.Empty() {
+			report(SeverityError, "embedded document differs from %s:\n%s", opts.Source, strings.TrimRight(d.String(), "\n"))
+		} else {
+			report(SeverityWarning, "embedded document is not byte identical to %s but is semantically equal", opts.Source)
+		}
+	}
+	if id := strings.TrimPrefix(embedded.ID, urn); id != t.Descriptor.ResourceID {
+		report(SeverityError, "ResourceID %s does not match the document Id %s", t.Descriptor.ResourceID, id)
+	}
+	if ns := embedded.XMLName.Space; ns != t.Descriptor.NamespaceURI {
+		report(SeverityError, "NamespaceURI %s does not match the document namespace %s", t.Descriptor.NamespaceURI, ns)
+	}
+
+	// ancillary resources.
+	resources := make(map[string]*mxf.AncillaryResource)
+	for _, r := range t.Resources {
+		resources[r.ID] = r
+	}
+	referenced := make(map[string]bool)
+	check := func(ref string) {
+		ID := strings.TrimPrefix(strings.TrimSpace(ref), urn)
+		if referenced[ID] {
+			return
+		}
+		referenced[ID] = true
+		r, ok := resources[ID]
+		if !ok {
+			report(SeverityError, "resource %s is not carried in a generic stream partition", ID)
+			return
+		}
+		data, err := ioutil.ReadFile(resourcePath(opts.Resources, ID))
+		if err != nil {
+			report(SeverityWarning, "unable to read source resource: %s", err)
+			return
+		}
+		if !bytes.Equal(data, r.Data) {
+			report(SeverityError, "resource %s differs from %s", ID, resourcePath(opts.Resources, ID))
+		}
+	}
+	for _, l := range embedded.LoadFont {
+		check(l.Font)
+	}
+	for _, sub := range subtitles(embedded) {
+		for _, i := range sub.images() {
+			check(imageContent(i))
+		}
+	}
+	for _, r := range t.Resources {
+		if !referenced[r.ID] {
+			report(SeverityWarning, "resource %s is not referenced by the document", r.ID)
+		}
+	}
+
+	// duration and identity.
+	duration := t.Descriptor.ContainerDuration
+	if opts.Duration > 0 && duration != int64(opts.Duration) {
+		report(SeverityError, "ContainerDuration %d does not match Duration %d", duration, opts.Duration)
+	}
+	last := lastTimeOut(embedded)
+	switch {
+	case int64(last) > duration:
+		report(SeverityError, "last TimeOut at frame %d exceeds ContainerDuration %d", last, duration)
+	case int64(last) < duration:
+		report(SeverityWarning, "last TimeOut at frame %d ends before ContainerDuration %d", last, duration)
+	}
+	if opts.AssetUUID != "" && !strings.EqualFold(opts.AssetUUID, t.AssetUUID) {
+		report(SeverityError, "AssetUUID %s does not match %s", t.AssetUUID, opts.AssetUUID)
+	}
+	return findings
+}
+
+// lastTimeOut returns the frame count of the latest TimeOut of a document.
+func lastTimeOut(s *SubtitleReel) int {
+	rate := getEditRate(s.EditRate)
+	last := 0
+	for _, sub := range subtitles(s) {
+		if tc, err := ParseTimecode(sub.TimeOut, rate); err == nil && tc.Frames() > last {
+			last = tc.Frames()
+		}
+	}
+	return last
+}
@@ -0,0 +1,52 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestRunThenVerify(t *testing.T) {
+	for _, j := range []Job{
+		{Text: true, Reel: 1, Framerate: "24"},
+		{Text: true, Reel: 2, Framerate: "48", Captions: true},
+		{Image: true, Reel: 1, Framerate: "24000/1001"},
+	} {
+		j.Track = true
+		j.Language = "en"
+		j.Title = "No Title"
+		j.Output = t.TempDir()
+		j.Wrapper = NativeWrapper{}
+		j.Log = ioutil.Discard
+		res, err := j.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		findings := VerifyTrackFile(res.MXF, VerifyOptions{Source: res.XML, AssetUUID: res.AssetUUID})
+		if len(findings) != 0 {
+			t.Errorf("%s reel %d: %v", j.Framerate, j.Reel, findings)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// MarshalXML encodes a SubtitleReel, refusing to write ST 428-7:2014 constructs into a document
+// with the 2010 namespace.
+func (s SubtitleReel) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if xmlNsSubtitle[namespace(&s)] == dcst2010 {
+		if c := constructs2014(&s); len(c) > 0 {
+			return fmt.Errorf("not permitted in a 2010 document: %s", strings.Join(c, ", "))
+		}
+	}
+	type subtitleReel SubtitleReel
+	return e.EncodeElement(subtitleReel(s), start)
+}
+
+// constructs2014 returns the names of the ST 428-7:2014 only elements and attributes used in a
+// SubtitleReel, in order of first use.
+func constructs2014(s *SubtitleReel) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string, used bool) {
+		if used && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	add("LoadVariableZ", len(s.LoadVariableZ) > 0)
+	for _, sub := range subtitles(s) {
+		subtitleConstructs2014(sub, add)
+	}
+	return names
+}
+
+// subtitleConstructs2014 calls add with the name of every ST 428-7:2014 only element and
+// attribute of a Subtitle, and whether it is used.
+func subtitleConstructs2014(sub *Subtitle, add func(name string, used bool)) {
+	for _, t := range sub.texts() {
+		add("Zposition", t.Zposition != "")
+		add("VariableZ", t.VariableZ != "")
+		for _, r := range t.Runs {
+			add("Ruby", r.Ruby != nil)
+			add("Space", r.Space != nil)
+			add("HGroup", r.HGroup != nil)
+			add("Rotate", r.Rotate != nil)
+		}
+	}
+	for _, i := range sub.images() {
+		add("Zposition", i.Zposition != "")
+		add("VariableZ", i.VariableZ != "")
+	}
+}
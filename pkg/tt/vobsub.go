@@ -0,0 +1,423 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see <http://www.gnu.org/licenses/>.*/
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MPEG program stream start codes.
+const (
+	mpegPackHeader    = 0xBA
+	mpegPrivateStream = 0xBD
+	mpegEndCode       = 0xB9
+	vobSubStreamBase  = 0x20
+)
+
+// SPU control commands.
+const (
+	spuForcedStart = 0x00
+	spuStart       = 0x01
+	spuStop        = 0x02
+	spuPalette     = 0x03
+	spuAlpha       = 0x04
+	spuCoordinates = 0x05
+	spuRLEOffsets  = 0x06
+	spuEnd         = 0xFF
+)
+
+// spuDelayUnit is the duration of an SPU control sequence delay tick in 90 kHz units.
+const spuDelayUnit = 1024
+
+// VobSubIndex holds the contents of a VobSub .idx file.
+type VobSubIndex struct {
+	Size    image.Point
+	Palette [16]color.NRGBA
+	Streams []VobSubStream
+}
+
+// VobSubStream is a single language stream of a VobSub .idx file.
+type VobSubStream struct {
+	Language string
+	Index    int
+	Entries  []VobSubEntry
+}
+
+// VobSubEntry locates a subpicture within the .sub file.
+type VobSubEntry struct {
+	// PTS is the presentation timestamp in 90 kHz units.
+	PTS     int64
+	FilePos int64
+}
+
+// subpicture is a decoded SPU.
+type subpicture struct {
+	img         *image.NRGBA
+	at          image.Point
+	start, stop int64
+	hasStop     bool
+}
+
+// ImportVobSub decodes a VobSub (.idx/.sub) subtitle stream into an image profile SubtitleReel.
+// The .sub file is expected beside the .idx file. Every subpicture is written as a UUID named
+// RGBA PNG to the output directory and its position is mapped onto the target DCI container.
+func ImportVobSub(idx string, opts BitmapImportOptions) (*SubtitleReel, error) {
+	if err := checkFrameRate(opts.Framerate); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(idx)
+	if err != nil {
+		return nil, err
+	}
+	index, err := ReadVobSubIndex(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", idx, err)
+	}
+	if opts.Stream < 0 || opts.Stream >= len(index.Streams) {
+		return nil, fmt.Errorf("%s: stream %d not present", idx, opts.Stream)
+	}
+	sub, err := ioutil.ReadFile(strings.TrimSuffix(idx, ".idx") + ".sub")
+	if err != nil {
+		return nil, err
+	}
+
+	stream := index.Streams[opts.Stream]
+	placer, err := newBitmapPlacer(opts, index.Size)
+	if err != nil {
+		return nil, err
+	}
+	var pics []*subpicture
+	for _, e := range stream.Entries {
+		spu, err := readSPUPacket(sub, e.FilePos, vobSubStreamBase+stream.Index)
+		if err != nil {
+			return nil, fmt.Errorf("subpicture at %d: %w", e.FilePos, err)
+		}
+		pic, err := decodeSPU(spu, index.Palette)
+		if err != nil {
+			return nil, fmt.Errorf("subpicture at %d: %w", e.FilePos, err)
+		}
+		pic.start += e.PTS
+		pic.stop += e.PTS
+		pics = append(pics, pic)
+	}
+	for i, pic := range pics {
+		// subpictures without a stop command end at the next subpicture.
+		if !pic.hasStop {
+			if i+1 >= len(pics) {
+				return nil, fmt.Errorf("subpicture at %s has no stop time", placer.timecode(pic.start))
+			}
+			pic.stop = pics[i+1].start
+		}
+		img, err := placer.image(pic.img, pic.at)
+		if err != nil {
+			return nil, err
+		}
+		if img != nil {
+			placer.add(pic.start, pic.stop, []*Image{img})
+		}
+	}
+	return placer.reel, nil
+}
+
+// ReadVobSubIndex reads a VobSub .idx file.
+func ReadVobSubIndex(r io.Reader) (*VobSubIndex, error) {
+	index := &VobSubIndex{}
+	var stream *VobSubStream
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value := line, ""
+		if i := strings.Index(line, ":"); i > 0 {
+			key, value = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		switch key {
+		case "size":
+			if _, err := fmt.Sscanf(value, "%dx%d", &index.Size.X, &index.Size.Y); err != nil {
+				return nil, fmt.Errorf("invalid size: %s", value)
+			}
+		case "palette":
+			for i, c := range strings.Split(value, ",") {
+				if i >= len(index.Palette) {
+					break
+				}
+				v, err := strconv.ParseUint(strings.TrimSpace(c), 16, 32)
+				if err != nil {
+					return nil, fmt.Errorf("invalid palette entry: %s", c)
+				}
+				index.Palette[i] = color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xFF}
+			}
+		case "id":
+			// id: en, index: 0
+			s := VobSubStream{}
+			parts := strings.Split(value, ",")
+			s.Language = strings.TrimSpace(parts[0])
+			if len(parts) > 1 {
+				if _, err := fmt.Sscanf(strings.TrimSpace(parts[1]), "index: %d", &s.Index); err != nil {
+					return nil, fmt.Errorf("invalid stream id: %s", value)
+				}
+			}
+			index.Streams = append(index.Streams, s)
+			stream = &index.Streams[len(index.Streams)-1]
+		case "timestamp":
+			// timestamp: 00:00:01:234, filepos: 000000000
+			if stream == nil {
+				return nil, errors.New("timestamp before stream id")
+			}
+			var h, m, s, ms int64
+			var pos string
+			if _, err := fmt.Sscanf(value, "%d:%d:%d:%d, filepos: %s", &h, &m, &s, &ms, &pos); err != nil {
+				return nil, fmt.Errorf("invalid timestamp: %s", value)
+			}
+			filePos, err := strconv.ParseInt(pos, 16, 64)
+			if err != nil || filePos < 0 {
+				return nil, fmt.Errorf("invalid filepos: %s", pos)
+			}
+			stream.Entries = append(stream.Entries, VobSubEntry{
+				PTS:     ((h*3600+m*60+s)*1000 + ms) * pts90kHz / 1000,
+				FilePos: filePos,
+			})
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if index.Size.X == 0 || index.Size.Y == 0 {
+		return nil, errors.New("missing size")
+	}
+	if index.Size.X < 0 || index.Size.Y < 0 || index.Size.X > Container4K.X || index.Size.Y > Container4K.Y {
+		return nil, fmt.Errorf("invalid size: %dx%d", index.Size.X, index.Size.Y)
+	}
+	if len(index.Streams) == 0 {
+		return nil, errors.New("no subtitle streams")
+	}
+	return index, nil
+}
+
+// readSPUPacket assembles a complete SPU for the given substream from the MPEG program stream
+// packs starting at pos.
+func readSPUPacket(data []byte, pos int64, substream int) ([]byte, error) {
+	var spu []byte
+	size := -1
+	if pos < 0 || pos > int64(len(data)) {
+		return nil, errors.New("filepos beyond the end of the .sub file")
+	}
+	p := int(pos)
+	for size < 0 || len(spu) < size {
+		if p+4 > len(data) || data[p] != 0 || data[p+1] != 0 || data[p+2] != 1 {
+			return nil, errors.New("invalid MPEG start code")
+		}
+		code := data[p+3]
+		switch {
+		case code == mpegPackHeader:
+			if p+14 > len(data) {
+				return nil, errors.New("truncated pack header")
+			}
+			if data[p+4]&0xC0 == 0x40 {
+				// MPEG-2 pack header with stuffing.
+				p += 14 + int(data[p+13]&0x07)
+			} else {
+				p += 12
+			}
+		case code == mpegEndCode:
+			return nil, errors.New("program stream ends before SPU is complete")
+		default:
+			if p+6 > len(data) {
+				return nil, errors.New("truncated PES packet")
+			}
+			length := int(binary.BigEndian.Uint16(data[p+4:]))
+			end := p + 6 + length
+			if end > len(data) {
+				return nil, errors.New("truncated PES packet")
+			}
+			if code == mpegPrivateStream {
+				payload := data[p+6 : end]
+				if len(payload) < 3 || len(payload) < 3+int(payload[2]) {
+					return nil, errors.New("truncated PES header")
+				}
+				payload = payload[3+int(payload[2]):]
+				if len(payload) > 0 && int(payload[0]) == substream {
+					spu = append(spu, payload[1:]...)
+					if size < 0 && len(spu) >= 2 {
+						size = int(binary.BigEndian.Uint16(spu))
+					}
+				}
+			}
+			p = end
+		}
+	}
+	return spu[:size], nil
+}
+
+// decodeSPU decodes the control sequences and interlaced run-length encoded bitmap of an SPU.
+func decodeSPU(spu []byte, palette [16]color.NRGBA) (*subpicture, error) {
+	if len(spu) < 4 {
+		return nil, errors.New("truncated SPU")
+	}
+	pic := &subpicture{}
+	var (
+		colors  [4]byte
+		alpha   [4]byte
+		rect    image.Rectangle
+		offsets [2]int
+	)
+	ctrl := int(binary.BigEndian.Uint16(spu[2:]))
+	for {
+		if ctrl+4 > len(spu) {
+			return nil, errors.New("truncated SPU control sequence")
+		}
+		delay := int64(binary.BigEndian.Uint16(spu[ctrl:])) * spuDelayUnit
+		next := int(binary.BigEndian.Uint16(spu[ctrl+2:]))
+		p := ctrl + 4
+	commands:
+		for p < len(spu) {
+			cmd := spu[p]
+			p++
+			switch cmd {
+			case spuForcedStart, spuStart:
+				pic.start = delay
+			case spuStop:
+				pic.stop, pic.hasStop = delay, true
+			case spuPalette, spuAlpha:
+				if p+2 > len(spu) {
+					return nil, errors.New("truncated SPU command")
+				}
+				v := [4]byte{spu[p+1] & 0x0F, spu[p+1] >> 4, spu[p] & 0x0F, spu[p] >> 4}
+				if cmd == spuPalette {
+					colors = v
+				} else {
+					alpha = v
+				}
+				p += 2
+			case spuCoordinates:
+				if p+6 > len(spu) {
+					return nil, errors.New("truncated SPU command")
+				}
+				x1 := int(spu[p])<<4 | int(spu[p+1])>>4
+				x2 := int(spu[p+1]&0x0F)<<8 | int(spu[p+2])
+				y1 := int(spu[p+3])<<4 | int(spu[p+4])>>4
+				y2 := int(spu[p+4]&0x0F)<<8 | int(spu[p+5])
+				rect = image.Rect(x1, y1, x2+1, y2+1)
+				p += 6
+			case spuRLEOffsets:
+				if p+4 > len(spu) {
+					return nil, errors.New("truncated SPU command")
+				}
+				offsets[0] = int(binary.BigEndian.Uint16(spu[p:]))
+				offsets[1] = int(binary.BigEndian.Uint16(spu[p+2:]))
+				p += 4
+			case spuEnd:
+				break commands
+			default:
+				return nil, fmt.Errorf("unknown SPU command 0x%02x", cmd)
+			}
+		}
+		if next == ctrl {
+			break
+		}
+		if next < ctrl {
+			return nil, errors.New("SPU control sequences do not progress")
+		}
+		ctrl = next
+	}
+	if rect.Empty() {
+		return nil, errors.New("SPU has no display area")
+	}
+
+	var pal [4]color.NRGBA
+	for i := range pal {
+		pal[i] = palette[colors[i]]
+		pal[i].A = alpha[i] * 0x11
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	for field := 0; field < 2; field++ {
+		if err := decodeSPUField(spu, offsets[field], img, field, pal); err != nil {
+			return nil, err
+		}
+	}
+	pic.img = img
+	pic.at = rect.Min
+	return pic, nil
+}
+
+// decodeSPUField decodes the run-length encoded lines of a single field into img.
+func decodeSPUField(spu []byte, offset int, img *image.NRGBA, field int, pal [4]color.NRGBA) error {
+	n := offset * 2 // nibble position
+	nibble := func() int {
+		if n/2 >= len(spu) {
+			n++
+			return 0
+		}
+		v := spu[n/2]
+		if n%2 == 0 {
+			v >>= 4
+		}
+		n++
+		return int(v & 0x0F)
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	for y := field; y < h; y += 2 {
+		for x := 0; x < w; {
+			if n/2 >= len(spu) {
+				return errors.New("truncated SPU pixel data")
+			}
+			v := nibble()
+			if v < 0x4 {
+				v = v<<4 | nibble()
+				if v < 0x10 {
+					v = v<<4 | nibble()
+					if v < 0x40 {
+						v = v<<4 | nibble()
+					}
+				}
+			}
+			run, c := v>>2, pal[v&0x03]
+			if run == 0 || x+run > w {
+				run = w - x
+			}
+			for i := 0; i < run; i++ {
+				img.SetNRGBA(x+i, y, c)
+			}
+			x += run
+		}
+		// lines are byte aligned.
+		if n%2 != 0 {
+			n++
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const vobSubTestIndex = `# VobSub index file, v7 (do not modify this line!)
+size: 720x576
+palette: 000000, ffffff, 808080, 000000, 000000, 000000, 000000, 000000, 000000, 000000, 000000, 000000, 000000, 000000, 000000, 000000
+
+id: en, index: 0
+timestamp: 00:00:01:000, filepos: 000000000
+`
+
+// vobSubTestSPU returns an SPU showing a w by h rectangle of colour index 1 at x, y from the
+// start of the subpicture until stop delay ticks.
+func vobSubTestSPU(x, y, w, h int, stop uint16) []byte {
+	spu := []byte{0, 0, 0, 0}
+	// every line fills to its end with colour 1, the field of even lines precedes the odd ones.
+	var fields [2][]byte
+	for line := 0; line < h; line++ {
+		fields[line%2] = append(fields[line%2], 0x00, 0x01)
+	}
+	offsets := [2]int{len(spu), len(spu) + len(fields[0])}
+	spu = append(append(spu, fields[0]...), fields[1]...)
+	x2, y2 := x+w-1, y+h-1
+	first := len(spu)
+	last := first + 4 + 20
+	spu = append(spu, 0, 0, byte(last>>8), byte(last),
+		spuPalette, 0x00, 0x10,
+		spuAlpha, 0x00, 0xF0,
+		spuCoordinates, byte(x>>4), byte(x<<4)|byte(x2>>8), byte(x2), byte(y>>4), byte(y<<4)|byte(y2>>8), byte(y2),
+		spuRLEOffsets, byte(offsets[0]>>8), byte(offsets[0]), byte(offsets[1]>>8), byte(offsets[1]),
+		spuStart, spuEnd)
+	spu = append(spu, byte(stop>>8), byte(stop), byte(last>>8), byte(last), spuStop, spuEnd)
+	binary.BigEndian.PutUint16(spu, uint16(len(spu)))
+	binary.BigEndian.PutUint16(spu[2:], uint16(first))
+	return spu
+}
+
+// vobSubTestPacks wraps an SPU into MPEG-2 program stream packs of the given substream.
+func vobSubTestPacks(spu []byte, substream byte) []byte {
+	var b bytes.Buffer
+	for len(spu) > 0 {
+		n := len(spu)
+		if n > 32 {
+			n = 32
+		}
+		b.Write([]byte{0, 0, 1, mpegPackHeader, 0x44, 0, 4, 0, 4, 1, 0, 0, 3, 0xF8})
+		b.Write([]byte{0, 0, 1, mpegPrivateStream, 0, byte(4 + n), 0x81, 0, 0, substream})
+		b.Write(spu[:n])
+		spu = spu[n:]
+	}
+	b.Write([]byte{0, 0, 1, mpegEndCode})
+	return b.Bytes()
+}
+
+// writeVobSubTest writes a VobSub .idx and .sub pair to dir and returns the path of the .idx file.
+func writeVobSubTest(t *testing.T, dir, index string, sub []byte) string {
+	idx := filepath.Join(dir, "test.idx")
+	if err := ioutil.WriteFile(idx, []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "test.sub"), sub, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return idx
+}
+
+func TestImportVobSub(t *testing.T) {
+	dir := t.TempDir()
+	// 176 ticks of 1024/90000 s end the subpicture 2.0025 s after it starts.
+	sub := vobSubTestPacks(vobSubTestSPU(100, 500, 40, 10, 176), vobSubStreamBase)
+	out := t.TempDir()
+	s, err := ImportVobSub(writeVobSubTest(t, dir, vobSubTestIndex, sub), BitmapImportOptions{Output: out, Framerate: "24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs := subtitles(s)
+	if len(subs) != 1 || len(subs[0].Image) != 1 {
+		t.Fatalf("got %d events, want a single event with one image", len(subs))
+	}
+	if subs[0].TimeIn != "00:00:01:00" || subs[0].TimeOut != "00:00:03:00" {
+		t.Errorf("got %s-%s, want 00:00:01:00-00:00:03:00", subs[0].TimeIn, subs[0].TimeOut)
+	}
+	// the 720x576 video is scaled by 1.875 and centred in the 2048x1080 container.
+	i := subs[0].Image[0]
+	if i.Hposition != "26.2" || i.Vposition != "86.81" {
+		t.Errorf("got image at %s, %s, want 26.2, 86.81", i.Hposition, i.Vposition)
+	}
+	f, err := os.Open(filepath.Join(out, strings.TrimPrefix(i.Image, urn)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != 75 || size.Y != 19 {
+		t.Errorf("got image size %v, want 75x19", size)
+	}
+	if c := color.NRGBAModel.Convert(img.At(10, 10)).(color.NRGBA); c != (color.NRGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("got pixel colour %v, want opaque white", c)
+	}
+}
+
+func TestImportVobSubRejectsInvalidFramerates(t *testing.T) {
+	sub := vobSubTestPacks(vobSubTestSPU(100, 500, 40, 10, 176), vobSubStreamBase)
+	idx := writeVobSubTest(t, t.TempDir(), vobSubTestIndex, sub)
+	for _, rate := range invalidFramerates {
+		if _, err := ImportVobSub(idx, BitmapImportOptions{Output: t.TempDir(), Framerate: rate}); err == nil {
+			t.Errorf("ImportVobSub accepted frame rate %q", rate)
+		}
+	}
+}
+
+func TestReadVobSubIndex(t *testing.T) {
+	index, err := ReadVobSubIndex(strings.NewReader(vobSubTestIndex + "\nid: fr, index: 1\ntimestamp: 00:01:00:500, filepos: 00000a000\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.Size.X != 720 || index.Size.Y != 576 || index.Palette[1] != (color.NRGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("got size %v and palette entry %v", index.Size, index.Palette[1])
+	}
+	if len(index.Streams) != 2 || index.Streams[1].Language != "fr" || index.Streams[1].Index != 1 {
+		t.Fatalf("got streams %+v", index.Streams)
+	}
+	if e := index.Streams[1].Entries; len(e) != 1 || e[0].PTS != 60500*90 || e[0].FilePos != 0xa000 {
+		t.Errorf("got entries %+v", e)
+	}
+	for _, in := range []string{
+		"",
+		"size: 720\nid: en\n",
+		"size: 720x576\n",
+		"size: -720x576\nid: en\n",
+		"size: 720x576\ntimestamp: 00:00:01:000, filepos: 0\n",
+		"size: 720x576\nid: en\ntimestamp: 00:00:01:000, filepos: -10\n",
+		"size: 720x576\npalette: 00000g\nid: en\n",
+	} {
+		if _, err := ReadVobSubIndex(strings.NewReader(in)); err == nil {
+			t.Errorf("ReadVobSubIndex accepted %q", in)
+		}
+	}
+}
+
+// malformedVobSub returns the .sub streams that ImportVobSub must reject.
+func malformedVobSub() map[string][]byte {
+	// a control sequence linking back to an earlier one.
+	spu := vobSubTestSPU(100, 500, 40, 10, 176)
+	first := binary.BigEndian.Uint16(spu[2:])
+	copy(spu[len(spu)-4:], []byte{byte(first >> 8), byte(first)})
+	return map[string][]byte{
+		"empty":                  nil,
+		"end code":               {0, 0, 1, mpegEndCode},
+		"control sequence cycle": vobSubTestPacks(spu, vobSubStreamBase),
+	}
+}
+
+func TestImportVobSubMalformed(t *testing.T) {
+	dir := t.TempDir()
+	for name, in := range malformedVobSub() {
+		idx := writeVobSubTest(t, dir, vobSubTestIndex, in)
+		if _, err := ImportVobSub(idx, BitmapImportOptions{Output: t.TempDir(), Framerate: "24"}); err == nil {
+			t.Errorf("%s: ImportVobSub accepted % x", name, in)
+		}
+	}
+}
+
+func FuzzImportVobSub(f *testing.F) {
+	sub := vobSubTestPacks(vobSubTestSPU(100, 500, 40, 10, 176), vobSubStreamBase)
+	f.Add(vobSubTestIndex, sub)
+	for n := 1; n < len(sub); n += 37 {
+		f.Add(vobSubTestIndex, sub[:n])
+	}
+	for _, in := range malformedVobSub() {
+		f.Add(vobSubTestIndex, in)
+	}
+	f.Add(strings.Replace(vobSubTestIndex, "filepos: 000000000", "filepos: 7fffffffffffffff", 1), sub)
+	f.Fuzz(func(t *testing.T, index string, sub []byte) {
+		idx := writeVobSubTest(t, t.TempDir(), index, sub)
+		ImportVobSub(idx, BitmapImportOptions{Output: t.TempDir(), Framerate: "24"})
+	})
+}
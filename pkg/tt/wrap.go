@@ -0,0 +1,263 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jack-watts/empty-tt/pkg/mxf"
+)
+
+// MIME types of the ancillary resources of a track file.
+const (
+	mimeFont = "application/x-font-opentype"
+	mimePNG  = "image/png"
+)
+
+// versionRegexp matches the version number reported by asdcp-wrap -V.
+var versionRegexp = regexp.MustCompile(`\d+\.\d+\.\d+`)
+
+// WrapOptions configures a single Wrap call.
+type WrapOptions struct {
+	// AssetUUID is the AssetUUID of the resulting track file.
+	AssetUUID string
+	// Duration is the ContainerDuration of the resulting track file.
+	Duration int
+	// FrameRate is the frame rate as given with '-p', e.g. "24" or "24000/1001".
+	FrameRate string
+	// KeyID and Key, a hex encoded AES-128 key, signal that the track file is to be encrypted.
+	KeyID string
+	Key   string
+}
+
+// Wrapper wraps a Subtitle XML document and its ancillary resources into a D-Cinema track file.
+type Wrapper interface {
+	Wrap(source, output string, opts WrapOptions) error
+}
+
+// ASDCPWrapper wraps track files with the asdcp-wrap binary of ASDCPlib.
+type ASDCPWrapper struct {
+	// Path is the asdcp-wrap binary. Defaults to asdcp-wrap at $PATH.
+	Path string
+}
+
+// binary returns the configured asdcp-wrap path.
+func (a *ASDCPWrapper) binary() string {
+	if a.Path == "" {
+		return as
+	}
+	return a.Path
+}
+
+// Version returns the version reported by asdcp-wrap, e.g. "2.10.38".
+func (a *ASDCPWrapper) Version() (string, error) {
+	out, err := a.run("-V")
+	if err != nil {
+		return "", err
+	}
+	v := versionRegexp.FindString(out)
+	if v == "" {
+		return "", fmt.Errorf("%s: unable to determine version from %q", a.binary(), strings.TrimSpace(out))
+	}
+	return v, nil
+}
+
+// Wrap runs asdcp-wrap on the source XML document. Its resources are expected beside the document.
+func (a *ASDCPWrapper) Wrap(source, output string, opts WrapOptions) error {
+	args, err := asdcpArgs(source, output, opts)
+	if err != nil {
+		return err
+	}
+	_, err = a.run(args...)
+	return err
+}
+
+// run executes asdcp-wrap, returning its standard output. Standard error is returned in the error.
+func (a *ASDCPWrapper) run(args ...string) (string, error) {
+	path, err := exec.LookPath(a.binary())
+	if err != nil {
+		return "", fmt.Errorf("%s not installed or not available at $PATH", a.binary())
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", a.binary(), err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", a.binary(), err)
+	}
+	return stdout.String(), nil
+}
+
+// asdcpArgs returns the asdcp-wrap command line arguments of a Wrap call.
+func asdcpArgs(source, output string, opts WrapOptions) ([]string, error) {
+	rate, err := asdcpRate(opts.FrameRate)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"-L"}
+	if opts.Key != "" {
+		args = append(args, "-j", opts.KeyID, "-k", opts.Key)
+	}
+	return append(args, "-a", opts.AssetUUID, "-d", strconv.Itoa(opts.Duration), "-p", rate, source, output), nil
+}
+
+// asdcpRates maps the frame rates supported by asdcp-wrap to their '-p' values. asdcp-wrap
+// selects the fractional 24000/1001 rate with "23" and silently falls back to 24 for any value
+// it does not know, so other rates must not reach it.
+var asdcpRates = map[string]string{
+	"16/1": "16", "18/1": "18", "20/1": "20", "22/1": "22", "24000/1001": "23", "24/1": "24",
+	"25/1": "25", "30/1": "30", "48/1": "48", "50/1": "50", "60/1": "60", "96/1": "96",
+	"100/1": "100", "120/1": "120", "192/1": "192", "200/1": "200", "240/1": "240",
+}
+
+// asdcpRate returns the asdcp-wrap '-p' value of a frame rate given as a whole number or a
+// rational, e.g. "24" or "24000/1001".
+func asdcpRate(frameRate string) (string, error) {
+	r := frameRate
+	if !strings.Contains(r, "/") {
+		r += "/1"
+	}
+	if p, ok := asdcpRates[r]; ok {
+		return p, nil
+	}
+	return "", fmt.Errorf("frame rate %q is not supported by %s", frameRate, as)
+}
+
+// NativeWrapper wraps unencrypted track files with the Go MXF writer of package mxf. The EditRate
+// is taken from the source document.
+type NativeWrapper struct{}
+
+// Wrap writes the source XML document and the fonts and images it references, read from the
+// document's directory, to an ST 429-5 track file.
+func (NativeWrapper) Wrap(source, output string, opts WrapOptions) error {
+	if opts.Key != "" {
+		return errors.New("native wrapper does not support encryption, use asdcp-wrap")
+	}
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	var s *SubtitleReel
+	if err := xml.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+	t := &mxf.TrackFile{
+		AssetUUID: opts.AssetUUID,
+		XML:       data,
+		Descriptor: mxf.TimedTextDescriptor{
+			ResourceID:        strings.TrimPrefix(s.ID, urn),
+			NamespaceURI:      s.XMLName.Space,
+			UCSEncoding:       "UTF-8",
+			ContainerDuration: int64(opts.Duration),
+			EditRate:          s.EditRate,
+		},
+	}
+	seen := make(map[string]bool)
+	addResource := func(ref, mimeType string) error {
+		ID := strings.TrimPrefix(strings.TrimSpace(ref), urn)
+		if seen[ID] {
+			return nil
+		}
+		seen[ID] = true
+		data, err := ioutil.ReadFile(resourcePath(filepath.Dir(source), ID))
+		if err != nil {
+			return err
+		}
+		t.Resources = append(t.Resources, &mxf.AncillaryResource{ID: ID, MIMEType: mimeType, Data: data})
+		return nil
+	}
+	for _, l := range s.LoadFont {
+		if err := addResource(l.Font, mimeFont); err != nil {
+			return err
+		}
+	}
+	for _, sub := range subtitles(s) {
+		for _, i := range sub.images() {
+			if err := addResource(imageContent(i), mimePNG); err != nil {
+				return err
+			}
+		}
+	}
+	return mxf.Create(output, t)
+}
+
+// WrapCall records the arguments of a single FakeWrapper.Wrap call.
+type WrapCall struct {
+	Source  string
+	Output  string
+	Options WrapOptions
+	// Args are the asdcp-wrap arguments the call translates to.
+	Args []string
+}
+
+// FakeWrapper records Wrap calls without writing a track file. It is intended for tests.
+type FakeWrapper struct {
+	Calls []WrapCall
+	// Err is returned by every Wrap call.
+	Err error
+	mu  sync.Mutex
+}
+
+// Wrap records the call and returns f.Err, or the error of a frame rate asdcp-wrap does not
+// support. It is safe for concurrent use.
+func (f *FakeWrapper) Wrap(source, output string, opts WrapOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	args, err := asdcpArgs(source, output, opts)
+	if err != nil {
+		return err
+	}
+	f.Calls = append(f.Calls, WrapCall{
+		Source:  source,
+		Output:  output,
+		Options: opts,
+		Args:    args,
+	})
+	return f.Err
+}
+
+// NewWrapper returns the Wrapper for a backend name, "asdcp" or "native". The path configures the
+// asdcp-wrap binary.
+func NewWrapper(name, path string) (Wrapper, error) {
+	switch name {
+	case "", "asdcp":
+		return &ASDCPWrapper{Path: path}, nil
+	case "native":
+		return NativeWrapper{}, nil
+	}
+	return nil, fmt.Errorf("unknown wrapper %q, expected asdcp or native", name)
+}
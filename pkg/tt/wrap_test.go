@@ -0,0 +1,162 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// stubASDCP writes a shell script named asdcp-wrap to a directory at the front of $PATH. It
+// prints a version for -V, records its arguments in args.txt beside it and fails with the
+// message on standard error when fail is not empty.
+func stubASDCP(t *testing.T, version, fail string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script stub")
+	}
+	dir := t.TempDir()
+	script := `#!/bin/sh
+if [ "$1" = "-V" ]; then
+	echo "` + version + `"
+	exit 0
+fi
+echo "$@" > "` + filepath.Join(dir, "args.txt") + `"
+if [ -n "` + fail + `" ]; then
+	echo "` + fail + `" >&2
+	exit 1
+fi
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, as), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+func TestASDCPWrapperVersion(t *testing.T) {
+	dir := stubASDCP(t, "asdcp-wrap ver. 2.10.38", "")
+	for _, a := range []*ASDCPWrapper{{}, {Path: filepath.Join(dir, as)}} {
+		v, err := a.Version()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != "2.10.38" {
+			t.Errorf("Version() = %q, want 2.10.38", v)
+		}
+	}
+}
+
+func TestASDCPWrapperVersionUnknown(t *testing.T) {
+	stubASDCP(t, "asdcp-wrap", "")
+	if _, err := (&ASDCPWrapper{}).Version(); err == nil {
+		t.Error("Version() accepted output without a version number")
+	}
+}
+
+func TestASDCPWrapperNotInstalled(t *testing.T) {
+	a := &ASDCPWrapper{Path: filepath.Join(t.TempDir(), as)}
+	if err := a.Wrap("in.xml", "out.mxf", WrapOptions{FrameRate: "24"}); err == nil || !strings.Contains(err.Error(), "not installed") {
+		t.Errorf("Wrap() = %v, want not installed", err)
+	}
+}
+
+func TestASDCPWrapperWrap(t *testing.T) {
+	dir := stubASDCP(t, "", "")
+	opts := WrapOptions{AssetUUID: "8c6f3c02-8a1b-4f1e-9c5e-2f0a3b4c5d6e", Duration: 240, FrameRate: "24000/1001"}
+	if err := (&ASDCPWrapper{}).Wrap("in.xml", "out.mxf", opts); err != nil {
+		t.Fatal(err)
+	}
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "-L -a 8c6f3c02-8a1b-4f1e-9c5e-2f0a3b4c5d6e -d 240 -p 23 in.xml out.mxf"
+	if got := strings.TrimSpace(string(args)); got != want {
+		t.Errorf("arguments = %q, want %q", got, want)
+	}
+}
+
+func TestASDCPWrapperStderr(t *testing.T) {
+	stubASDCP(t, "", "invalid key length")
+	err := (&ASDCPWrapper{}).Wrap("in.xml", "out.mxf", WrapOptions{FrameRate: "24"})
+	if err == nil || !strings.Contains(err.Error(), "invalid key length") {
+		t.Errorf("Wrap() = %v, want the standard error of asdcp-wrap", err)
+	}
+}
+
+func TestASDCPArgs(t *testing.T) {
+	tests := []struct {
+		opts WrapOptions
+		want []string
+	}{
+		{WrapOptions{AssetUUID: "a", Duration: 48, FrameRate: "24"}, []string{"-L", "-a", "a", "-d", "48", "-p", "24", "in.xml", "out.mxf"}},
+		{WrapOptions{AssetUUID: "a", Duration: 48, FrameRate: "25/1"}, []string{"-L", "-a", "a", "-d", "48", "-p", "25", "in.xml", "out.mxf"}},
+		{WrapOptions{AssetUUID: "a", Duration: 48, FrameRate: "24000/1001"}, []string{"-L", "-a", "a", "-d", "48", "-p", "23", "in.xml", "out.mxf"}},
+		{WrapOptions{AssetUUID: "a", Duration: 48, FrameRate: "48", KeyID: "k", Key: "00"}, []string{"-L", "-j", "k", "-k", "00", "-a", "a", "-d", "48", "-p", "48", "in.xml", "out.mxf"}},
+	}
+	for _, tc := range tests {
+		got, err := asdcpArgs("in.xml", "out.mxf", tc.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("asdcpArgs(%+v) = %q, want %q", tc.opts, got, tc.want)
+		}
+	}
+}
+
+func TestASDCPArgsUnsupportedRates(t *testing.T) {
+	for _, rate := range []string{"23", "29", "30000/1001", "48000/1001", "60000/1001", "24/2", ""} {
+		if _, err := asdcpArgs("in.xml", "out.mxf", WrapOptions{FrameRate: rate}); err == nil {
+			t.Errorf("asdcpArgs accepted frame rate %q", rate)
+		}
+	}
+}
+
+func TestJobRunWrapperArgs(t *testing.T) {
+	f := &FakeWrapper{}
+	j := Job{Text: true, Track: true, Encrypt: true, Reel: 2, Duration: 480, Framerate: "24000/1001",
+		Language: "en", Title: "No Title", Output: t.TempDir(), Wrapper: f, Log: ioutil.Discard}
+	res, err := j.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Calls) != 1 {
+		t.Fatalf("%d Wrap calls, want 1", len(f.Calls))
+	}
+	c := f.Calls[0]
+	if c.Source != res.XML || c.Output != res.MXF {
+		t.Errorf("Wrap(%s, %s), want Wrap(%s, %s)", c.Source, c.Output, res.XML, res.MXF)
+	}
+	want := []string{"-L", "-j", res.KeyID, "-k", res.Key, "-a", res.AssetUUID, "-d", "480", "-p", "23", res.XML, res.MXF}
+	if !reflect.DeepEqual(c.Args, want) {
+		t.Errorf("arguments = %q, want %q", c.Args, want)
+	}
+}
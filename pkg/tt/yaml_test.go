@@ -0,0 +1,102 @@
+package tt
+
+/*
+
+Written by Jack Watts for SMPTE Standards TC 27C Community.
+
+This program is free software : you can redistribute it and / or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT,
+INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT
+NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF
+THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE
+
+You should have received a copy of the GNU General Public License
+along with this program.If not, see http://www.gnu.org/licenses.*/
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want interface{}
+	}{
+		{"empty", "# only a comment\n---\n", nil},
+		{"scalars", "a: 1\nb: 2.5\nc: true\nd: false\ne: ~\nf: null\ng: text\n", map[string]interface{}{
+			"a": int64(1), "b": 2.5, "c": true, "d": false, "e": nil, "f": nil, "g": "text",
+		}},
+		{"quoting", "a: \"x: # y\"\nb: 'it''s'\nc: \"tab\\tnew\"\nd: '24'\n", map[string]interface{}{
+			"a": "x: # y", "b": "it's", "c": "tab\tnew", "d": "24",
+		}},
+		{"comments", "# header\na: 1 # trailing\nb: x#y\n\n  # indented\nc: 'a # b'\n", map[string]interface{}{
+			"a": int64(1), "b": "x#y", "c": "a # b",
+		}},
+		{"url value", "a: http://example.com:8080/x\n", map[string]interface{}{"a": "http://example.com:8080/x"}},
+		{"flow sequence", "a: [1, 'b', \"c\"]\nb: []\n", map[string]interface{}{
+			"a": []interface{}{int64(1), "b", "c"}, "b": []interface{}{},
+		}},
+		{"nested mapping", "a:\n  b:\n    c: 1\n  d: 2\ne: 3\n", map[string]interface{}{
+			"a": map[string]interface{}{"b": map[string]interface{}{"c": int64(1)}, "d": int64(2)}, "e": int64(3),
+		}},
+		{"empty value", "a:\nb: 1\n", map[string]interface{}{"a": nil, "b": int64(1)}},
+		{"top level sequence", "- 1\n- two\n-\n", []interface{}{int64(1), "two", nil}},
+		{"sequence of mappings", "jobs:\n  - name: a\n    reel: 1\n  - name: b\n", map[string]interface{}{
+			"jobs": []interface{}{
+				map[string]interface{}{"name": "a", "reel": int64(1)},
+				map[string]interface{}{"name": "b"},
+			},
+		}},
+		{"sequence at key indentation", "fonts:\n- a.ttf\n- b.ttf\nworkers: 2\n", map[string]interface{}{
+			"fonts": []interface{}{"a.ttf", "b.ttf"}, "workers": int64(2),
+		}},
+		{"nested sequences", "- - a\n  - b\n- - c\n-\n  - d\n", []interface{}{
+			[]interface{}{"a", "b"}, []interface{}{"c"}, []interface{}{"d"},
+		}},
+		{"sequence in sequence item", "jobs:\n  - name: a\n    fonts:\n      - x.ttf\n      - y.ttf\n", map[string]interface{}{
+			"jobs": []interface{}{
+				map[string]interface{}{"name": "a", "fonts": []interface{}{"x.ttf", "y.ttf"}},
+			},
+		}},
+	}
+	for _, tc := range tests {
+		got, err := parseYAML([]byte(tc.doc))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %#v, want %#v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	for _, doc := range []string{
+		"a: 1\n\tb: 2\n",
+		"a: 1\na: 2\n",
+		"a: 1\n  b: 2\n",
+		"just text\n",
+		"a: \"unterminated\n",
+		"a: 'unterminated\n",
+		"a: [1, 2\n",
+		"a: [\"x]\n",
+		"- a\nb: 1\n",
+	} {
+		if v, err := parseYAML([]byte(doc)); err == nil {
+			t.Errorf("parseYAML(%q) = %#v, want an error", doc, v)
+		}
+	}
+}